@@ -0,0 +1,356 @@
+// Package apiv2 - вторая версия API пользователей и дружбы: ресурсы по числовому ID,
+// ошибки в формате problem+json. Обработчики общие для "Джин" и "Гориллы".
+package apiv2
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"Network-exchange/auth"
+	"Network-exchange/core"
+	"Network-exchange/logging"
+	"Network-exchange/problem"
+	"Network-exchange/secure"
+)
+
+// Prefix - префикс маршрутов второй версии
+const Prefix = "/v2"
+
+// Ограничения постраничного вывода
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// API - обработчики второй версии поверх общего хранилища
+type API struct {
+	Store *core.Store
+	Auth  *auth.Service
+	//Subject - владелец пользователя в правилах доступа и логин его учетной записи
+	//(в "Джин" - имя, в "Горилле" - ID)
+	Subject func(core.User) string
+}
+
+// New создает обработчики второй версии
+func New(store *core.Store, authService *auth.Service, subject func(core.User) string) *API {
+	return &API{Store: store, Auth: authService, Subject: subject}
+}
+
+// UserPage - страница списка пользователей
+type UserPage struct {
+	Items  []core.User `json:"items"`
+	Total  int         `json:"total"`
+	Limit  int         `json:"limit"`
+	Offset int         `json:"offset"`
+}
+
+// NewUser - тело запроса на создание пользователя
+type NewUser struct {
+	Name     string `json:"name"`
+	Age      *int   `json:"age,omitempty"`      //обязателен без даты рождения; с ней не учитывается
+	Password string `json:"password,omitempty"` //без пароля учетная запись не создается
+	core.Profile
+}
+
+// UserPatch - тело запроса на изменение пользователя: поля без значения не меняются,
+// пустая строка очищает поле профиля, null в custom удаляет дополнительное поле
+type UserPatch struct {
+	Age         *int           `json:"age,omitempty"`
+	Email       *string        `json:"email,omitempty"`
+	DisplayName *string        `json:"display_name,omitempty"`
+	Bio         *string        `json:"bio,omitempty"`
+	City        *string        `json:"city,omitempty"`
+	BirthDate   *string        `json:"birth_date,omitempty"`
+	AvatarURL   *string        `json:"avatar_url,omitempty"`
+	Custom      map[string]any `json:"custom,omitempty"`
+}
+
+// profile - изменение профиля из тела запроса; false - профиль не меняется
+func (p UserPatch) profile() (core.ProfilePatch, bool) {
+	out := core.ProfilePatch{Email: p.Email, DisplayName: p.DisplayName, Bio: p.Bio, City: p.City,
+		BirthDate: p.BirthDate, AvatarURL: p.AvatarURL, Custom: p.Custom}
+	return out, p.Email != nil || p.DisplayName != nil || p.Bio != nil || p.City != nil ||
+		p.BirthDate != nil || p.AvatarURL != nil || p.Custom != nil
+}
+
+// ListUsers - GET /v2/users?name=&min_age=&max_age=&limit=&offset=&as_of=
+func (a *API) ListUsers(w http.ResponseWriter, r *http.Request) {
+	src, ok := a.at(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	f := core.Filter{Name: q.Get("name"), Limit: DefaultLimit}
+	f.MinAge, _ = strconv.Atoi(q.Get("min_age"))
+	f.MaxAge, _ = strconv.Atoi(q.Get("max_age"))
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			problem.Write(w, r, http.StatusBadRequest, problem.Validation, "offset - неотрицательное целое число", nil)
+			return
+		}
+		f.Offset = n
+	}
+	if n, err := strconv.Atoi(q.Get("limit")); err == nil && n > 0 {
+		f.Limit = min(n, MaxLimit)
+	}
+	items, total := src.List(r.Context(), f)
+	writeJSON(w, http.StatusOK, UserPage{Items: a.shownAll(r, items), Total: total, Limit: f.Limit, Offset: f.Offset})
+}
+
+// CreateUser - POST /v2/users
+func (a *API) CreateUser(w http.ResponseWriter, r *http.Request) {
+	var req NewUser
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.Age == nil && req.BirthDate == "" {
+		problem.Write(w, r, http.StatusBadRequest, problem.Validation, "нужен возраст (age) или дата рождения (birth_date)", nil)
+		return
+	}
+	account, err := a.account(req.Password)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.Validation, err.Error(), nil)
+		return
+	}
+	age := 0
+	if req.Age != nil {
+		age = *req.Age
+	}
+	user, err := a.Store.CreateWithProfile(r.Context(), req.Name, age, req.Profile, account)
+	if err != nil {
+		a.fail(w, r, err)
+		return
+	}
+	logging.SetUser(r.Context(), strconv.Itoa(user.ID))
+	w.Header().Set("Location", Prefix+"/users/"+strconv.Itoa(user.ID))
+	writeJSON(w, http.StatusCreated, user)
+}
+
+// GetUser - GET /v2/users/{id}?as_of=
+func (a *API) GetUser(w http.ResponseWriter, r *http.Request, id string) {
+	src, ok := a.at(w, r)
+	if !ok {
+		return
+	}
+	if user, ok := a.find(w, r, src, id); ok {
+		writeJSON(w, http.StatusOK, a.shown(r, user))
+	}
+}
+
+// PatchUser - PATCH /v2/users/{id}: возраст и профиль; изменить можно только себя
+// (администратор - любого)
+func (a *API) PatchUser(w http.ResponseWriter, r *http.Request, id string) {
+	user, ok := a.owned(w, r, id, auth.PermUsersWrite)
+	if !ok {
+		return
+	}
+	var patch UserPatch
+	if !decode(w, r, &patch) {
+		return
+	}
+	profile, ok := patch.profile()
+	if !ok && patch.Age == nil {
+		writeJSON(w, http.StatusOK, user) //менять нечего
+		return
+	}
+	//профиль и возраст проверяются вместе: при ошибке не меняется ничего
+	user, err := a.Store.UpdateUser(r.Context(), user.ID, patch.Age, profile)
+	if err != nil {
+		a.fail(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// DeleteUser - DELETE /v2/users/{id}: пользователь помечается удаленным (его можно восстановить
+// в течение срока хранения), учетная запись блокируется
+func (a *API) DeleteUser(w http.ResponseWriter, r *http.Request, id string) {
+	user, ok := a.owned(w, r, id, auth.PermUsersDelete)
+	if !ok {
+		return
+	}
+	if _, err := a.Store.Delete(r.Context(), user.ID); err != nil {
+		a.fail(w, r, err)
+		return
+	}
+	a.Auth.Suspend(a.Subject(user))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListFriends - GET /v2/users/{id}/friends?as_of=
+func (a *API) ListFriends(w http.ResponseWriter, r *http.Request, id string) {
+	src, ok := a.at(w, r)
+	if !ok {
+		return
+	}
+	user, ok := a.find(w, r, src, id)
+	if !ok {
+		return
+	}
+	friends := make([]core.User, 0, len(user.Friends))
+	for _, fid := range user.Friends {
+		if friend, err := src.Get(r.Context(), fid); err == nil {
+			friends = append(friends, a.shown(r, friend))
+		}
+	}
+	writeJSON(w, http.StatusOK, UserPage{Items: friends, Total: len(friends), Limit: len(friends)})
+}
+
+// AddFriend - PUT /v2/users/{id}/friends/{friendId}: дружбу предлагает только сам {id};
+// 201 - дружба создана, 204 - уже были друзьями
+func (a *API) AddFriend(w http.ResponseWriter, r *http.Request, id, friendID string) {
+	user, ok := a.owned(w, r, id, auth.PermUsersWrite)
+	if !ok {
+		return
+	}
+	fid, ok := userID(w, r, friendID)
+	if !ok {
+		return
+	}
+	switch err := a.Store.Befriend(r.Context(), user.ID, fid); {
+	case errors.Is(err, core.ErrAlreadyFriends):
+		w.WriteHeader(http.StatusNoContent)
+	case err != nil:
+		a.fail(w, r, err)
+	default:
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// RemoveFriend - DELETE /v2/users/{id}/friends/{friendId}
+func (a *API) RemoveFriend(w http.ResponseWriter, r *http.Request, id, friendID string) {
+	user, ok := a.owned(w, r, id, auth.PermUsersWrite)
+	if !ok {
+		return
+	}
+	fid, ok := userID(w, r, friendID)
+	if !ok {
+		return
+	}
+	if err := a.Store.Unfriend(r.Context(), user.ID, fid); err != nil {
+		a.fail(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// userID - ID пользователя из пути; не число - ответ 400
+func userID(w http.ResponseWriter, r *http.Request, id string) (int, bool) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.Validation, "ID пользователя - целое число", nil)
+		return 0, false
+	}
+	return n, true
+}
+
+// find - пользователь из пути в состоянии src; при отсутствии отвечает 404
+func (a *API) find(w http.ResponseWriter, r *http.Request, src reader, id string) (core.User, bool) {
+	logging.SetUser(r.Context(), id)
+	n, ok := userID(w, r, id)
+	if !ok {
+		return core.User{}, false
+	}
+	user, err := src.Get(r.Context(), n)
+	if err != nil {
+		a.fail(w, r, err)
+		return core.User{}, false
+	}
+	return user, true
+}
+
+// owned - пользователь из пути, от имени которого вправе действовать клиент
+func (a *API) owned(w http.ResponseWriter, r *http.Request, id string, perm auth.Permission) (core.User, bool) {
+	user, ok := a.find(w, r, a.Store, id)
+	if !ok {
+		return core.User{}, false
+	}
+	if err := auth.Authorize(r.Context(), a.Subject(user), perm); err != nil {
+		deny(w, r, err)
+		return core.User{}, false
+	}
+	return user, true
+}
+
+// shown - u для клиента: email и дата рождения видны только самому пользователю и с правом users:read
+func (a *API) shown(r *http.Request, u core.User) core.User {
+	if auth.Authorize(r.Context(), a.Subject(u), auth.PermUsersRead) != nil {
+		return u.Public()
+	}
+	return u
+}
+
+// shownAll - shown для каждого из users
+func (a *API) shownAll(r *http.Request, users []core.User) []core.User {
+	for i, u := range users {
+		users[i] = a.shown(r, u)
+	}
+	return users
+}
+
+// account - учетная запись нового пользователя с паролем password: занимается при создании
+// пользователя, до записи события (nil - без пароля, без учетной записи)
+func (a *API) account(password string) (core.Reserve, error) {
+	if password == "" {
+		return nil, nil
+	}
+	p, err := a.Auth.Prepare(password, auth.RoleUser)
+	if err != nil {
+		return nil, err
+	}
+	return func(u core.User) error { return p.Register(a.Subject(u)) }, nil
+}
+
+// fail переводит ошибку хранилища в ответ problem+json
+func (a *API) fail(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, core.ErrNotFound), errors.Is(err, core.ErrNotFriends), errors.Is(err, core.ErrNotBlocked),
+		errors.Is(err, core.ErrNotFollowing), errors.Is(err, core.ErrNoRequest):
+		problem.Write(w, r, http.StatusNotFound, problem.NotFound, err.Error(), nil)
+	case errors.Is(err, core.ErrNameTaken), errors.Is(err, core.ErrEmailTaken), errors.Is(err, core.ErrAgeDerived),
+		errors.Is(err, core.ErrAlreadyFriends), errors.Is(err, core.ErrSelfFriend),
+		errors.Is(err, core.ErrSelfBlock), errors.Is(err, core.ErrSelfFollow), errors.Is(err, auth.ErrLoginTaken):
+		problem.Write(w, r, http.StatusConflict, problem.Conflict, err.Error(), nil)
+	case errors.Is(err, core.ErrBlocked):
+		problem.Write(w, r, http.StatusForbidden, problem.Forbidden, err.Error(), nil)
+	case errors.Is(err, core.ErrInvalid), errors.Is(err, core.ErrInvalidFriendship), errors.Is(err, core.ErrInvalidProfile):
+		problem.Write(w, r, http.StatusBadRequest, problem.Validation, err.Error(), nil)
+	default:
+		logging.FromContext(r.Context()).Error("ошибка хранилища", "error", err)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal, "", nil)
+	}
+}
+
+// deny - отказ в доступе в формате problem+json
+func deny(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, auth.ErrUnauthenticated), errors.Is(err, auth.ErrInvalidToken):
+		problem.Write(w, r, http.StatusUnauthorized, problem.Unauthorized, "заголовок Authorization: Bearer", nil)
+	case errors.Is(err, auth.ErrSuspended):
+		problem.Write(w, r, http.StatusForbidden, problem.Forbidden, err.Error(), nil)
+	default:
+		problem.Write(w, r, http.StatusForbidden, problem.Forbidden, "", nil)
+	}
+}
+
+// decode читает тело запроса; при ошибке отвечает 400 или 413
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := secure.DecodeJSON(r, v); err != nil {
+		if secure.TooLarge(err) {
+			problem.Write(w, r, http.StatusRequestEntityTooLarge, problem.TooLarge, "", nil)
+		} else {
+			problem.Write(w, r, http.StatusBadRequest, problem.Validation, "некорректный JSON", nil)
+		}
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
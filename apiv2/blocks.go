@@ -0,0 +1,62 @@
+package apiv2
+
+import (
+	"errors"
+	"net/http"
+
+	"Network-exchange/auth"
+	"Network-exchange/core"
+)
+
+// ListBlocked - GET /v2/users/{id}/blocks: заблокированные пользователем {id}
+// (видны только ему самому и служебным ролям)
+func (a *API) ListBlocked(w http.ResponseWriter, r *http.Request, id string) {
+	user, ok := a.owned(w, r, id, auth.PermUsersRead)
+	if !ok {
+		return
+	}
+	blocked, err := a.Store.Blocked(r.Context(), user.ID)
+	if err != nil {
+		a.fail(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, UserPage{Items: a.shownAll(r, blocked), Total: len(blocked), Limit: len(blocked)})
+}
+
+// Block - PUT /v2/users/{id}/blocks/{blockedId}: дружба прекращается;
+// 201 - заблокирован, 204 - уже был заблокирован
+func (a *API) Block(w http.ResponseWriter, r *http.Request, id, blockedID string) {
+	user, ok := a.owned(w, r, id, auth.PermUsersWrite)
+	if !ok {
+		return
+	}
+	bid, ok := userID(w, r, blockedID)
+	if !ok {
+		return
+	}
+	switch err := a.Store.Block(r.Context(), user.ID, bid); {
+	case errors.Is(err, core.ErrAlreadyBlocked):
+		w.WriteHeader(http.StatusNoContent)
+	case err != nil:
+		a.fail(w, r, err)
+	default:
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// Unblock - DELETE /v2/users/{id}/blocks/{blockedId}
+func (a *API) Unblock(w http.ResponseWriter, r *http.Request, id, blockedID string) {
+	user, ok := a.owned(w, r, id, auth.PermUsersWrite)
+	if !ok {
+		return
+	}
+	bid, ok := userID(w, r, blockedID)
+	if !ok {
+		return
+	}
+	if err := a.Store.Unblock(r.Context(), user.ID, bid); err != nil {
+		a.fail(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
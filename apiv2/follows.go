@@ -0,0 +1,164 @@
+package apiv2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"Network-exchange/auth"
+	"Network-exchange/core"
+)
+
+// FollowSettings - тело PATCH /v2/users/{id}/follows
+type FollowSettings struct {
+	RequiresApproval *bool `json:"requires_approval"`
+}
+
+// GetFollows - GET /v2/users/{id}/follows: число подписчиков и подписок, нужно ли одобрение
+func (a *API) GetFollows(w http.ResponseWriter, r *http.Request, id string) {
+	user, ok := a.find(w, r, a.Store, id)
+	if !ok {
+		return
+	}
+	stats, err := a.Store.FollowStats(r.Context(), user.ID)
+	if err != nil {
+		a.fail(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// PatchFollows - PATCH /v2/users/{id}/follows {"requires_approval":true}; при выключении
+// одобрения ждущие заявки одобряются
+func (a *API) PatchFollows(w http.ResponseWriter, r *http.Request, id string) {
+	user, ok := a.owned(w, r, id, auth.PermUsersWrite)
+	if !ok {
+		return
+	}
+	var patch FollowSettings
+	if !decode(w, r, &patch) {
+		return
+	}
+	var (
+		stats core.FollowStats
+		err   error
+	)
+	if patch.RequiresApproval != nil {
+		stats, err = a.Store.SetFollowApproval(r.Context(), user.ID, *patch.RequiresApproval)
+	} else {
+		stats, err = a.Store.FollowStats(r.Context(), user.ID)
+	}
+	if err != nil {
+		a.fail(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// ListFollowers - GET /v2/users/{id}/followers
+func (a *API) ListFollowers(w http.ResponseWriter, r *http.Request, id string) {
+	a.listRelated(w, r, id, a.Store.Followers)
+}
+
+// ListFollowing - GET /v2/users/{id}/following
+func (a *API) ListFollowing(w http.ResponseWriter, r *http.Request, id string) {
+	a.listRelated(w, r, id, a.Store.Following)
+}
+
+// ListFollowRequests - GET /v2/users/{id}/follow-requests: ждущие одобрения заявки
+// (видны только самому {id} и служебным ролям)
+func (a *API) ListFollowRequests(w http.ResponseWriter, r *http.Request, id string) {
+	if _, ok := a.owned(w, r, id, auth.PermUsersRead); !ok {
+		return
+	}
+	a.listRelated(w, r, id, a.Store.FollowRequests)
+}
+
+func (a *API) listRelated(w http.ResponseWriter, r *http.Request, id string, list func(ctx context.Context, id int) ([]core.User, error)) {
+	user, ok := a.find(w, r, a.Store, id)
+	if !ok {
+		return
+	}
+	users, err := list(r.Context(), user.ID)
+	if err != nil {
+		a.fail(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, UserPage{Items: a.shownAll(r, users), Total: len(users), Limit: len(users)})
+}
+
+// Follow - PUT /v2/users/{id}/following/{targetId}: подписаться может только сам {id};
+// 201 - подписка действует, 202 - заявка ждет одобрения, 204 - подписка уже была
+func (a *API) Follow(w http.ResponseWriter, r *http.Request, id, targetID string) {
+	user, ok := a.owned(w, r, id, auth.PermUsersWrite)
+	if !ok {
+		return
+	}
+	tid, ok := userID(w, r, targetID)
+	if !ok {
+		return
+	}
+	pending, err := a.Store.Follow(r.Context(), user.ID, tid)
+	switch {
+	case errors.Is(err, core.ErrAlreadyFollows):
+		w.WriteHeader(http.StatusNoContent)
+	case err != nil:
+		a.fail(w, r, err)
+	case pending:
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// Unfollow - DELETE /v2/users/{id}/following/{targetId}: отписка или отзыв заявки
+func (a *API) Unfollow(w http.ResponseWriter, r *http.Request, id, targetID string) {
+	user, ok := a.owned(w, r, id, auth.PermUsersWrite)
+	if !ok {
+		return
+	}
+	tid, ok := userID(w, r, targetID)
+	if !ok {
+		return
+	}
+	if err := a.Store.Unfollow(r.Context(), user.ID, tid); err != nil {
+		a.fail(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ApproveFollower - PUT /v2/users/{id}/followers/{followerId}: {id} одобряет заявку
+func (a *API) ApproveFollower(w http.ResponseWriter, r *http.Request, id, followerID string) {
+	user, ok := a.owned(w, r, id, auth.PermUsersWrite)
+	if !ok {
+		return
+	}
+	fid, ok := userID(w, r, followerID)
+	if !ok {
+		return
+	}
+	if err := a.Store.Approve(r.Context(), user.ID, fid); err != nil {
+		a.fail(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// RemoveFollower - DELETE /v2/users/{id}/followers/{followerId}: {id} удаляет подписчика
+// или отклоняет его заявку
+func (a *API) RemoveFollower(w http.ResponseWriter, r *http.Request, id, followerID string) {
+	user, ok := a.owned(w, r, id, auth.PermUsersWrite)
+	if !ok {
+		return
+	}
+	fid, ok := userID(w, r, followerID)
+	if !ok {
+		return
+	}
+	if err := a.Store.Unfollow(r.Context(), fid, user.ID); err != nil {
+		a.fail(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,118 @@
+package apiv2
+
+import (
+	"net/http"
+	"strconv"
+
+	"Network-exchange/auth"
+	"Network-exchange/core"
+)
+
+// FriendshipItem - дружба с данными друга
+type FriendshipItem struct {
+	core.Friendship
+	Friend core.User `json:"friend"`
+}
+
+// FriendshipPage - дружбы пользователя в порядке появления
+type FriendshipPage struct {
+	Items []FriendshipItem `json:"items"`
+	Total int              `json:"total"`
+}
+
+// FriendshipPatch - тело PATCH /v2/users/{id}/friendships/{friendId}
+type FriendshipPatch struct {
+	Labels    []string `json:"labels"` //[] - снять все метки; без поля - не менять
+	Closeness *int     `json:"closeness"`
+}
+
+// ListFriendships - GET /v2/users/{id}/friendships?label=&min_closeness=&max_closeness=&as_of=:
+// дружбы с временем, инициатором, метками и близостью (метки видны только самому {id}
+// и служебным ролям)
+func (a *API) ListFriendships(w http.ResponseWriter, r *http.Request, id string) {
+	if _, ok := a.owned(w, r, id, auth.PermUsersRead); !ok {
+		return
+	}
+	src, ok := a.at(w, r)
+	if !ok {
+		return
+	}
+	user, ok := a.find(w, r, src, id)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	f := core.FriendFilter{Label: q.Get("label")}
+	f.MinCloseness, _ = strconv.Atoi(q.Get("min_closeness"))
+	f.MaxCloseness, _ = strconv.Atoi(q.Get("max_closeness"))
+	ties, err := src.Friendships(r.Context(), user.ID, f)
+	if err != nil {
+		a.fail(w, r, err)
+		return
+	}
+	items := make([]FriendshipItem, 0, len(ties))
+	for _, t := range ties {
+		if friend, err := src.Get(r.Context(), t.FriendID); err == nil {
+			items = append(items, FriendshipItem{Friendship: t, Friend: a.shown(r, friend)})
+		}
+	}
+	writeJSON(w, http.StatusOK, FriendshipPage{Items: items, Total: len(items)})
+}
+
+// GetFriendship - GET /v2/users/{id}/friendships/{friendId}?as_of=
+func (a *API) GetFriendship(w http.ResponseWriter, r *http.Request, id, friendID string) {
+	if _, ok := a.owned(w, r, id, auth.PermUsersRead); !ok {
+		return
+	}
+	src, ok := a.at(w, r)
+	if !ok {
+		return
+	}
+	user, ok := a.find(w, r, src, id)
+	if !ok {
+		return
+	}
+	fid, ok := userID(w, r, friendID)
+	if !ok {
+		return
+	}
+	t, err := src.Friendship(r.Context(), user.ID, fid)
+	if err != nil {
+		a.fail(w, r, err)
+		return
+	}
+	friend, err := src.Get(r.Context(), fid)
+	if err != nil {
+		a.fail(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, FriendshipItem{Friendship: t, Friend: a.shown(r, friend)})
+}
+
+// PatchFriendship - PATCH /v2/users/{id}/friendships/{friendId} {"labels":["family"],"closeness":8}:
+// метки и близость меняет только сам {id}, у друга остаются его собственные
+func (a *API) PatchFriendship(w http.ResponseWriter, r *http.Request, id, friendID string) {
+	user, ok := a.owned(w, r, id, auth.PermUsersWrite)
+	if !ok {
+		return
+	}
+	var patch FriendshipPatch
+	if !decode(w, r, &patch) {
+		return
+	}
+	fid, ok := userID(w, r, friendID)
+	if !ok {
+		return
+	}
+	t, err := a.Store.UpdateFriendship(r.Context(), user.ID, fid, core.FriendshipPatch{Labels: patch.Labels, Closeness: patch.Closeness})
+	if err != nil {
+		a.fail(w, r, err)
+		return
+	}
+	friend, err := a.Store.Get(r.Context(), fid)
+	if err != nil {
+		a.fail(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, FriendshipItem{Friendship: t, Friend: a.shown(r, friend)})
+}
@@ -0,0 +1,52 @@
+package apiv2
+
+import "github.com/gin-gonic/gin"
+
+// Gin регистрирует маршруты второй версии в группе "Джин" (router.Group(apiv2.Prefix))
+func (a *API) Gin(g *gin.RouterGroup) {
+	g.GET("/users", gin.WrapF(a.ListUsers))
+	g.POST("/users", gin.WrapF(a.CreateUser))
+	g.GET("/users/:id", func(c *gin.Context) { a.GetUser(c.Writer, c.Request, c.Param("id")) })
+	g.PATCH("/users/:id", func(c *gin.Context) { a.PatchUser(c.Writer, c.Request, c.Param("id")) })
+	g.DELETE("/users/:id", func(c *gin.Context) { a.DeleteUser(c.Writer, c.Request, c.Param("id")) })
+	g.GET("/users/:id/friends", func(c *gin.Context) { a.ListFriends(c.Writer, c.Request, c.Param("id")) })
+	g.GET("/users/:id/history", func(c *gin.Context) { a.History(c.Writer, c.Request, c.Param("id")) })
+	g.GET("/journal", gin.WrapF(a.Journal))
+	g.PUT("/users/:id/friends/:friendId", func(c *gin.Context) {
+		a.AddFriend(c.Writer, c.Request, c.Param("id"), c.Param("friendId"))
+	})
+	g.DELETE("/users/:id/friends/:friendId", func(c *gin.Context) {
+		a.RemoveFriend(c.Writer, c.Request, c.Param("id"), c.Param("friendId"))
+	})
+	g.GET("/users/:id/friendships", func(c *gin.Context) { a.ListFriendships(c.Writer, c.Request, c.Param("id")) })
+	g.GET("/users/:id/friendships/:friendId", func(c *gin.Context) {
+		a.GetFriendship(c.Writer, c.Request, c.Param("id"), c.Param("friendId"))
+	})
+	g.PATCH("/users/:id/friendships/:friendId", func(c *gin.Context) {
+		a.PatchFriendship(c.Writer, c.Request, c.Param("id"), c.Param("friendId"))
+	})
+	g.GET("/users/:id/follows", func(c *gin.Context) { a.GetFollows(c.Writer, c.Request, c.Param("id")) })
+	g.PATCH("/users/:id/follows", func(c *gin.Context) { a.PatchFollows(c.Writer, c.Request, c.Param("id")) })
+	g.GET("/users/:id/followers", func(c *gin.Context) { a.ListFollowers(c.Writer, c.Request, c.Param("id")) })
+	g.GET("/users/:id/following", func(c *gin.Context) { a.ListFollowing(c.Writer, c.Request, c.Param("id")) })
+	g.GET("/users/:id/follow-requests", func(c *gin.Context) { a.ListFollowRequests(c.Writer, c.Request, c.Param("id")) })
+	g.PUT("/users/:id/following/:targetId", func(c *gin.Context) {
+		a.Follow(c.Writer, c.Request, c.Param("id"), c.Param("targetId"))
+	})
+	g.DELETE("/users/:id/following/:targetId", func(c *gin.Context) {
+		a.Unfollow(c.Writer, c.Request, c.Param("id"), c.Param("targetId"))
+	})
+	g.PUT("/users/:id/followers/:followerId", func(c *gin.Context) {
+		a.ApproveFollower(c.Writer, c.Request, c.Param("id"), c.Param("followerId"))
+	})
+	g.DELETE("/users/:id/followers/:followerId", func(c *gin.Context) {
+		a.RemoveFollower(c.Writer, c.Request, c.Param("id"), c.Param("followerId"))
+	})
+	g.GET("/users/:id/blocks", func(c *gin.Context) { a.ListBlocked(c.Writer, c.Request, c.Param("id")) })
+	g.PUT("/users/:id/blocks/:blockedId", func(c *gin.Context) {
+		a.Block(c.Writer, c.Request, c.Param("id"), c.Param("blockedId"))
+	})
+	g.DELETE("/users/:id/blocks/:blockedId", func(c *gin.Context) {
+		a.Unblock(c.Writer, c.Request, c.Param("id"), c.Param("blockedId"))
+	})
+}
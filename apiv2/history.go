@@ -0,0 +1,94 @@
+package apiv2
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"Network-exchange/auth"
+	"Network-exchange/core"
+	"Network-exchange/problem"
+)
+
+// reader - состояние, из которого читаются пользователи и дружба: текущее (core.Store)
+// или прошлое (core.View)
+type reader interface {
+	Get(ctx context.Context, id int) (core.User, error)
+	List(ctx context.Context, f core.Filter) ([]core.User, int)
+	Friendships(ctx context.Context, id int, f core.FriendFilter) ([]core.Friendship, error)
+	Friendship(ctx context.Context, id, friendID int) (core.Friendship, error)
+}
+
+// at - состояние для чтения: с параметром as_of (RFC 3339) - на тот момент,
+// восстановленное по журналу изменений. as_of - только после входа: сборка прошлого
+// состояния дороже обычного чтения. При ошибке отвечает 400 или 401.
+func (a *API) at(w http.ResponseWriter, r *http.Request) (reader, bool) {
+	v := r.URL.Query().Get("as_of")
+	if v == "" {
+		return a.Store, true
+	}
+	if _, ok := auth.FromContext(r.Context()); !ok {
+		deny(w, r, auth.ErrUnauthenticated)
+		return nil, false
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.Validation, "as_of - время в формате RFC 3339, например 2026-10-01T12:00:00Z", nil)
+		return nil, false
+	}
+	return a.Store.At(r.Context(), t), true
+}
+
+// History - GET /v2/users/{id}/history: изменения с участием пользователя по порядку
+// (в том числе помеченного удаленным; удаленного окончательно и заблокировавшего вошедшего - 404).
+// Изменения с другими заблокировавшими вошедшего пропускаются, как в ленте /events.
+func (a *API) History(w http.ResponseWriter, r *http.Request, id string) {
+	n, ok := userID(w, r, id)
+	if !ok {
+		return
+	}
+	hidden := a.Store.HiddenFrom(r.Context())
+	if hidden[n] {
+		a.fail(w, r, core.ErrNotFound)
+		return
+	}
+	events, err := a.Store.History(r.Context(), n)
+	if err != nil {
+		a.fail(w, r, err)
+		return
+	}
+	if len(events) == 0 {
+		if _, err := a.Store.Get(r.Context(), n); err != nil { //есть только в снимке - история пуста
+			a.fail(w, r, err)
+			return
+		}
+	}
+	shown := events[:0]
+	for _, e := range events {
+		if hidden[e.User.ID] || hidden[e.FriendID] {
+			continue
+		}
+		e.User = a.shown(r, core.Strip(e.User, hidden))
+		shown = append(shown, e)
+	}
+	writeJSON(w, http.StatusOK, shown)
+}
+
+// Journal - GET /v2/journal?after=&limit=: журнал изменений по порядку для построения
+// сторонних моделей чтения; продолжение - after = seq последнего. В журнале есть и изменения
+// Private (блокировки, заявки, метки дружбы) с полными данными пользователей, поэтому право -
+// audit:read, как у журнала аудита, а не users:read.
+func (a *API) Journal(w http.ResponseWriter, r *http.Request) {
+	if err := auth.Check(r.Context(), auth.PermAuditRead); err != nil {
+		deny(w, r, err)
+		return
+	}
+	q := r.URL.Query()
+	after, _ := strconv.ParseUint(q.Get("after"), 10, 64)
+	limit := DefaultLimit
+	if n, err := strconv.Atoi(q.Get("limit")); err == nil && n > 0 {
+		limit = min(n, MaxLimit)
+	}
+	writeJSON(w, http.StatusOK, a.Store.Events(after, limit))
+}
@@ -0,0 +1,91 @@
+package apiv2
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Mux регистрирует маршруты второй версии в подмаршрутизаторе
+// (router.PathPrefix(apiv2.Prefix).Subrouter())
+func (a *API) Mux(r *mux.Router) {
+	r.HandleFunc("/users", a.ListUsers).Methods("GET")
+	r.HandleFunc("/users", a.CreateUser).Methods("POST")
+	r.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		a.GetUser(w, r, mux.Vars(r)["id"])
+	}).Methods("GET")
+	r.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		a.PatchUser(w, r, mux.Vars(r)["id"])
+	}).Methods("PATCH")
+	r.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		a.DeleteUser(w, r, mux.Vars(r)["id"])
+	}).Methods("DELETE")
+	r.HandleFunc("/users/{id}/friends", func(w http.ResponseWriter, r *http.Request) {
+		a.ListFriends(w, r, mux.Vars(r)["id"])
+	}).Methods("GET")
+	r.HandleFunc("/users/{id}/history", func(w http.ResponseWriter, r *http.Request) {
+		a.History(w, r, mux.Vars(r)["id"])
+	}).Methods("GET")
+	r.HandleFunc("/journal", a.Journal).Methods("GET")
+	r.HandleFunc("/users/{id}/friends/{friendId}", func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		a.AddFriend(w, r, vars["id"], vars["friendId"])
+	}).Methods("PUT")
+	r.HandleFunc("/users/{id}/friends/{friendId}", func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		a.RemoveFriend(w, r, vars["id"], vars["friendId"])
+	}).Methods("DELETE")
+	r.HandleFunc("/users/{id}/friendships", func(w http.ResponseWriter, r *http.Request) {
+		a.ListFriendships(w, r, mux.Vars(r)["id"])
+	}).Methods("GET")
+	r.HandleFunc("/users/{id}/friendships/{friendId}", func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		a.GetFriendship(w, r, vars["id"], vars["friendId"])
+	}).Methods("GET")
+	r.HandleFunc("/users/{id}/friendships/{friendId}", func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		a.PatchFriendship(w, r, vars["id"], vars["friendId"])
+	}).Methods("PATCH")
+	r.HandleFunc("/users/{id}/follows", func(w http.ResponseWriter, r *http.Request) {
+		a.GetFollows(w, r, mux.Vars(r)["id"])
+	}).Methods("GET")
+	r.HandleFunc("/users/{id}/follows", func(w http.ResponseWriter, r *http.Request) {
+		a.PatchFollows(w, r, mux.Vars(r)["id"])
+	}).Methods("PATCH")
+	r.HandleFunc("/users/{id}/followers", func(w http.ResponseWriter, r *http.Request) {
+		a.ListFollowers(w, r, mux.Vars(r)["id"])
+	}).Methods("GET")
+	r.HandleFunc("/users/{id}/following", func(w http.ResponseWriter, r *http.Request) {
+		a.ListFollowing(w, r, mux.Vars(r)["id"])
+	}).Methods("GET")
+	r.HandleFunc("/users/{id}/follow-requests", func(w http.ResponseWriter, r *http.Request) {
+		a.ListFollowRequests(w, r, mux.Vars(r)["id"])
+	}).Methods("GET")
+	r.HandleFunc("/users/{id}/following/{targetId}", func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		a.Follow(w, r, vars["id"], vars["targetId"])
+	}).Methods("PUT")
+	r.HandleFunc("/users/{id}/following/{targetId}", func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		a.Unfollow(w, r, vars["id"], vars["targetId"])
+	}).Methods("DELETE")
+	r.HandleFunc("/users/{id}/followers/{followerId}", func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		a.ApproveFollower(w, r, vars["id"], vars["followerId"])
+	}).Methods("PUT")
+	r.HandleFunc("/users/{id}/followers/{followerId}", func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		a.RemoveFollower(w, r, vars["id"], vars["followerId"])
+	}).Methods("DELETE")
+	r.HandleFunc("/users/{id}/blocks", func(w http.ResponseWriter, r *http.Request) {
+		a.ListBlocked(w, r, mux.Vars(r)["id"])
+	}).Methods("GET")
+	r.HandleFunc("/users/{id}/blocks/{blockedId}", func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		a.Block(w, r, vars["id"], vars["blockedId"])
+	}).Methods("PUT")
+	r.HandleFunc("/users/{id}/blocks/{blockedId}", func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		a.Unblock(w, r, vars["id"], vars["blockedId"])
+	}).Methods("DELETE")
+}
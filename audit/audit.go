@@ -0,0 +1,129 @@
+// Package audit - журнал аудита: кто, когда и откуда изменил пользователей и дружбу,
+// со значениями до и после изменения. Записи делаются наблюдателем хранилища core
+// (в порядке изменений) и не меняются; просмотр и выгрузка - в /admin/audit.
+package audit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"Network-exchange/auth"
+	"Network-exchange/core"
+	"Network-exchange/logging"
+)
+
+// Владельцы изменений без входа
+const (
+	Anonymous = "anonymous" //запрос без входа (например, регистрация)
+	System    = "system"    //сам сервис вне запросов (например, очистка удаленных)
+)
+
+// Entry - запись аудита
+type Entry struct {
+	Seq       uint64     `json:"seq"` //номер изменения в журнале хранилища
+	Time      time.Time  `json:"time"`
+	Action    string     `json:"action"` //вид изменения: user.created, friendship.deleted...
+	Actor     string     `json:"actor"`  //владелец запроса (auth.Identity.Subject), Anonymous или System
+	Role      string     `json:"role,omitempty"`
+	RequestID string     `json:"request_id,omitempty"`
+	ClientIP  string     `json:"client_ip,omitempty"`
+	UserID    int        `json:"user_id"`             //измененный пользователь (для дружбы - инициатор)
+	FriendID  int        `json:"friend_id,omitempty"` //второй участник дружбы
+	Before    *core.User `json:"before"`              //null - пользователя еще не было
+	After     *core.User `json:"after"`               //null - пользователь удален
+}
+
+// Filter - отбор записей; нулевые поля не ограничивают
+type Filter struct {
+	UserID int //пользователь - измененный или второй участник дружбы
+	Actor  string
+	Action string
+	From   time.Time //не раньше
+	To     time.Time //раньше
+	Offset int
+	Limit  int //0 - все
+}
+
+func (f Filter) match(e *Entry) bool {
+	switch {
+	case f.UserID != 0 && e.UserID != f.UserID && e.FriendID != f.UserID,
+		f.Actor != "" && e.Actor != f.Actor,
+		f.Action != "" && e.Action != f.Action,
+		!f.From.IsZero() && e.Time.Before(f.From),
+		!f.To.IsZero() && !e.Time.Before(f.To):
+		return false
+	}
+	return true
+}
+
+// Log - журнал аудита в памяти
+type Log struct {
+	mu      sync.RWMutex
+	entries []Entry
+}
+
+// New начинает аудит изменений хранилища
+func New(store *core.Store) *Log {
+	l := &Log{}
+	store.Observe(l.observe)
+	return l
+}
+
+// observe - наблюдатель хранилища: владельца, ID запроса и адрес клиента берет из контекста
+func (l *Log) observe(ctx context.Context, e core.Event, before *core.User) {
+	entry := Entry{Seq: e.Seq, Time: e.Time, Action: string(e.Type), Actor: Anonymous,
+		RequestID: logging.RequestID(ctx), ClientIP: logging.ClientIP(ctx),
+		UserID: e.User.ID, FriendID: e.FriendID, Before: before}
+	if id, ok := auth.FromContext(ctx); ok {
+		entry.Actor, entry.Role = id.Subject, string(id.Role)
+	} else if entry.RequestID == "" {
+		entry.Actor = System
+	}
+	if e.Type != core.UserDeleted && e.Type != core.UserPurged {
+		after := e.User
+		after.Friends = append([]int{}, e.User.Friends...) //запись журнала хранилища не делится
+		entry.After = &after
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+	if e.Type == core.UserPurged {
+		l.redact(e.User.ID)
+	}
+}
+
+// redact оставляет от окончательно удаленного только ID в прежних записях (кто, когда и что
+// делал - остается). Вызывается под l.mu.
+func (l *Log) redact(id int) {
+	for i := range l.entries {
+		en := &l.entries[i]
+		if en.UserID != id {
+			continue
+		}
+		if en.Before != nil {
+			en.Before = &core.User{ID: id, Friends: []int{}}
+		}
+		if en.After != nil {
+			en.After = &core.User{ID: id, Friends: []int{}}
+		}
+	}
+}
+
+// Query - подходящие записи по порядку изменений и общее число подходящих
+func (l *Log) Query(f Filter) ([]Entry, int) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	out := []Entry{}
+	total := 0
+	for i := range l.entries {
+		if !f.match(&l.entries[i]) {
+			continue
+		}
+		if total >= f.Offset && (f.Limit == 0 || len(out) < f.Limit) {
+			out = append(out, l.entries[i])
+		}
+		total++
+	}
+	return out, total
+}
@@ -0,0 +1,91 @@
+package audit
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"Network-exchange/auth"
+)
+
+// Ограничения постраничного вывода
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// Page - страница записей
+type Page struct {
+	Items  []Entry `json:"items"`
+	Total  int     `json:"total"`
+	Limit  int     `json:"limit"`
+	Offset int     `json:"offset"`
+}
+
+// parseFilter читает ?user_id=&actor=&action=&from=&to= (время - RFC 3339)
+func parseFilter(r *http.Request) (Filter, string) {
+	q := r.URL.Query()
+	var f Filter
+	if v := q.Get("user_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			return f, "user_id - ID пользователя"
+		}
+		f.UserID = id
+	}
+	f.Actor, f.Action = q.Get("actor"), q.Get("action")
+	for _, p := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &f.From}, {"to", &f.To}} {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return f, p.name + " - время в формате RFC 3339, например 2026-10-01T00:00:00Z"
+			}
+			*p.t = t
+		}
+	}
+	return f, ""
+}
+
+// Handler - GET /admin/audit?user_id=&actor=&action=&from=&to=&limit=&offset=: записи
+// по порядку изменений
+func (l *Log) Handler(w http.ResponseWriter, r *http.Request) {
+	f, msg := parseFilter(r)
+	if msg != "" {
+		auth.WriteError(w, r, http.StatusBadRequest, msg)
+		return
+	}
+	q := r.URL.Query()
+	f.Limit = DefaultLimit
+	if n, err := strconv.Atoi(q.Get("limit")); err == nil && n > 0 {
+		f.Limit = min(n, MaxLimit)
+	}
+	if n, err := strconv.Atoi(q.Get("offset")); err == nil && n > 0 {
+		f.Offset = n
+	}
+	items, total := l.Query(f)
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(Page{Items: items, Total: total, Limit: f.Limit, Offset: f.Offset})
+}
+
+// ExportHandler - GET /admin/audit/export?user_id=&actor=&action=&from=&to=: все подходящие
+// записи в NDJSON (по записи в строке)
+func (l *Log) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	f, msg := parseFilter(r)
+	if msg != "" {
+		auth.WriteError(w, r, http.StatusBadRequest, msg)
+		return
+	}
+	items, _ := l.Query(f)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit.ndjson"`)
+	enc := json.NewEncoder(w)
+	for _, e := range items {
+		if enc.Encode(e) != nil {
+			return //клиент отключился
+		}
+	}
+}
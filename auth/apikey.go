@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Scope - область действия ключа API
+type Scope string
+
+// Области действия ключей
+const (
+	ScopeRead  Scope = "read"  //только чтение
+	ScopeWrite Scope = "write" //чтение и изменение пользователей и дружбы
+	ScopeAdmin Scope = "admin" //права администратора, кроме управления ключами и ролями
+)
+
+// права каждой области действия. Ключи не выпускают другие ключи и не назначают роли:
+// утекший ключ не должен давать права администратора ни одной учетной записи.
+// Подписки на вебхуки - обычная задача интеграции, они есть у области admin.
+var scopePermissions = map[Scope][]Permission{
+	ScopeRead:  {PermUsersRead},
+	ScopeWrite: {PermUsersRead, PermUsersWrite},
+	ScopeAdmin: {PermUsersRead, PermUsersWrite, PermUsersDelete, PermUsersSuspend, PermHooksManage, PermAuditRead},
+}
+
+// Can - есть ли у области действия право
+func (sc Scope) Can(p Permission) bool {
+	for _, have := range scopePermissions[sc] {
+		if have == p {
+			return true
+		}
+	}
+	return false
+}
+
+// префикс ключа отличает его от JWT в заголовке Authorization
+const apiKeyPrefix = "nxk_"
+
+// ErrKeyNotFound - ключа с таким ID нет
+var ErrKeyNotFound = errors.New("ключ не найден")
+
+// APIKey - ключ API для межсервисных клиентов (сам ключ хранится только в виде хеша)
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scope      Scope      `json:"scope"`
+	Owner      string     `json:"owner"` //кто выпустил ключ
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"` //nil - бессрочный
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	Revoked    bool       `json:"revoked"`
+	hash       string
+}
+
+// subject - владелец запросов, выполненных с ключом
+func (k *APIKey) subject() string {
+	return "apikey:" + k.ID
+}
+
+// CreateKey выпускает ключ; возвращает описание и сам ключ (показывается один раз)
+func (s *Service) CreateKey(name string, scope Scope, owner string, ttl time.Duration) (APIKey, string, error) {
+	if _, ok := scopePermissions[scope]; !ok {
+		return APIKey{}, "", errors.New("неизвестная область действия ключа")
+	}
+	id := make([]byte, 8)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return APIKey{}, "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return APIKey{}, "", err
+	}
+	key := &APIKey{
+		ID:        hex.EncodeToString(id),
+		Name:      name,
+		Scope:     scope,
+		Owner:     owner,
+		CreatedAt: s.now().UTC(),
+	}
+	if ttl > 0 {
+		expires := key.CreatedAt.Add(ttl)
+		key.ExpiresAt = &expires
+	}
+	raw := apiKeyPrefix + key.ID + "_" + hex.EncodeToString(secret)
+	key.hash = hashToken(raw)
+
+	s.mu.Lock()
+	s.keys[key.hash] = key
+	s.mu.Unlock()
+	return *key, raw, nil
+}
+
+// Keys возвращает все ключи по времени выпуска
+func (s *Service) Keys() []APIKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]APIKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		out = append(out, *k)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+// RevokeKey отзывает ключ по ID
+func (s *Service) RevokeKey(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range s.keys {
+		if k.ID == id {
+			k.Revoked = true
+			return nil
+		}
+	}
+	return ErrKeyNotFound
+}
+
+// VerifyKey проверяет ключ и отмечает время использования. Ключ действует, пока его
+// владелец вправе выпускать ключи: понижение роли, блокировка или удаление владельца
+// выключают его ключи.
+func (s *Service) VerifyKey(raw string) (Identity, error) {
+	now := s.now().UTC()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.keys[hashToken(raw)]
+	if !ok || k.Revoked || (k.ExpiresAt != nil && now.After(*k.ExpiresAt)) || !s.ownerCan(k.Owner, PermKeysManage) {
+		return Identity{}, ErrInvalidToken
+	}
+	k.LastUsedAt = &now
+	return Identity{Subject: k.subject(), Scope: k.Scope}, nil
+}
+
+// ownerCan - есть ли сейчас право у владельца ключа: роль из учетной записи (заблокированный -
+// без прав), без нее - роль сертификата клиента (под s.mu)
+func (s *Service) ownerCan(owner string, p Permission) bool {
+	if acc, ok := s.accounts[owner]; ok {
+		return !acc.Suspended && acc.Role.Can(p)
+	}
+	return s.certRoles[owner].Can(p)
+}
+
+// isAPIKey - похож ли токен на ключ API
+func isAPIKey(token string) bool {
+	return strings.HasPrefix(token, apiKeyPrefix)
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestScopePermissions(t *testing.T) {
+	tests := []struct {
+		scope Scope
+		perm  Permission
+		want  bool
+	}{
+		{ScopeRead, PermUsersRead, true},
+		{ScopeRead, PermUsersWrite, false},
+		{ScopeRead, PermAuditRead, false},
+		{ScopeWrite, PermUsersRead, true},
+		{ScopeWrite, PermUsersWrite, true},
+		{ScopeWrite, PermUsersDelete, false},
+		{ScopeAdmin, PermUsersDelete, true},
+		{ScopeAdmin, PermUsersSuspend, true},
+		{ScopeAdmin, PermHooksManage, true},
+		{ScopeAdmin, PermAuditRead, true},
+		{ScopeAdmin, PermKeysManage, false},  //ключи не выпускают ключи
+		{ScopeAdmin, PermRolesManage, false}, //и не назначают роли
+		{Scope("root"), PermUsersRead, false},
+	}
+	for _, tt := range tests {
+		if got := tt.scope.Can(tt.perm); got != tt.want {
+			t.Errorf("%s.Can(%s) = %v, ожидалось %v", tt.scope, tt.perm, got, tt.want)
+		}
+		//у ключа права области, а не роли
+		id := Identity{Subject: "apikey:1", Role: RoleAdmin, Scope: tt.scope}
+		if got := id.Can(tt.perm); got != tt.want {
+			t.Errorf("ключ %s: Can(%s) = %v, ожидалось %v", tt.scope, tt.perm, got, tt.want)
+		}
+	}
+	if _, _, err := NewService([]byte("test-secret"), "test").CreateKey("batch", Scope("root"), "root", 0); err == nil {
+		t.Error("ключ с неизвестной областью выпущен")
+	}
+}
+
+// Ключ действует, пока не отозван, не истек и его владелец вправе выпускать ключи
+func TestVerifyKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		owner  string
+		change func(s *Service, id string, now *time.Time)
+		err    error
+	}{
+		{"действительный", "root", func(*Service, string, *time.Time) {}, nil},
+		{"отозван", "root", func(s *Service, id string, _ *time.Time) { s.RevokeKey(id) }, ErrInvalidToken},
+		{"истек", "root", func(s *Service, _ string, now *time.Time) { *now = now.Add(2 * time.Hour) }, ErrInvalidToken},
+		{"владелец понижен", "root", func(s *Service, _ string, _ *time.Time) { s.SetRole("root", RoleUser) }, ErrInvalidToken},
+		{"владелец заблокирован", "root", func(s *Service, _ string, _ *time.Time) { s.Suspend("root") }, ErrInvalidToken},
+		{"владелец разблокирован", "root", func(s *Service, _ string, _ *time.Time) {
+			s.Suspend("root")
+			s.Reinstate("root")
+		}, nil},
+		{"владелец удален", "root", func(s *Service, _ string, _ *time.Time) { s.Remove("root") }, ErrInvalidToken},
+		{"владелец - сертификат администратора", "ops", func(s *Service, _ string, _ *time.Time) {}, nil},
+		{"роль сертификата понижена", "ops", func(s *Service, _ string, _ *time.Time) {
+			s.SetCertificateRole("ops", RoleAuditor)
+		}, ErrInvalidToken},
+		{"владелец без прав", "alice", func(*Service, string, *time.Time) {}, ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, now := newService(t)
+			if err := s.SetCertificateRole("ops", RoleAdmin); err != nil {
+				t.Fatal(err)
+			}
+			key, raw, err := s.CreateKey("batch", ScopeWrite, tt.owner, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.change(s, key.ID, now)
+			id, err := s.VerifyKey(raw)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ошибка %v, ожидалась %v", err, tt.err)
+			}
+			if err == nil && id != (Identity{Subject: "apikey:" + key.ID, Scope: ScopeWrite}) {
+				t.Errorf("владелец запроса %+v", id)
+			}
+		})
+	}
+}
+
+// Ключ принимается в X-API-Key и в Authorization: Bearer; чужой ключ - 401
+func TestKeyHeaders(t *testing.T) {
+	s, _ := newService(t)
+	_, raw, err := s.CreateKey("batch", ScopeRead, "root", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := s.Mux(Require(PermUsersRead)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	tests := []struct {
+		name, header, value string
+		status              int
+	}{
+		{"X-API-Key", HeaderAPIKey, raw, http.StatusNoContent},
+		{"Bearer", "Authorization", "Bearer " + raw, http.StatusNoContent},
+		{"неизвестный ключ", HeaderAPIKey, apiKeyPrefix + "0000_0000", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/admin/users", nil)
+			r.Header.Set(tt.header, tt.value)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.status {
+				t.Errorf("код %d, ожидался %d", w.Code, tt.status)
+			}
+		})
+	}
+}
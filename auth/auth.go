@@ -0,0 +1,380 @@
+// Package auth — учетные записи пользователей (пароли в bcrypt), выдача JWT
+// с токенами обновления, роли и проверка прав для сервисов на "Джин" и "Горилле".
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Ошибки входа и проверки токенов
+var (
+	ErrBadCredentials = errors.New("неверный логин или пароль")
+	ErrInvalidToken   = errors.New("недействительный токен")
+	ErrLoginTaken     = errors.New("логин уже занят")
+	ErrWeakPassword   = errors.New("пароль короче 8 символов")
+	ErrSuspended      = errors.New("учетная запись заблокирована")
+	ErrUnknownRole    = errors.New("неизвестная роль")
+)
+
+// минимальная длина пароля
+const minPasswordLen = 8
+
+// стоимость bcrypt для новых паролей (тесты берут минимальную)
+var passwordCost = bcrypt.DefaultCost
+
+// AdminLogin - логин учетной записи администратора (создается при заданном ADMIN_PASSWORD)
+const AdminLogin = "admin"
+
+// Account - учетная запись пользователя сервиса.
+// Логин совпадает с идентификатором пользователя: имя ("Джин") или ID ("Горилла").
+type Account struct {
+	Login        string `json:"login"`
+	Role         Role   `json:"role"`
+	Suspended    bool   `json:"suspended"`
+	HasPassword  bool   `json:"has_password"` //без пароля войти нельзя
+	passwordHash []byte
+}
+
+// Tokens - ответ на вход и обновление токенов
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"` //срок жизни access_token в секундах
+}
+
+// refreshToken - выданный токен обновления (храним только хеш)
+type refreshToken struct {
+	login   string
+	expires time.Time
+}
+
+// claims - содержимое JWT
+type claims struct {
+	Role Role `json:"role,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Service хранит учетные записи и выдает токены
+type Service struct {
+	mu        sync.Mutex
+	accounts  map[string]*Account     //по логину
+	refresh   map[string]refreshToken //по sha256 токена обновления
+	keys      map[string]*APIKey      //по sha256 ключа API
+	certRoles map[string]Role         //роли владельцев сертификатов клиентов по CN
+
+	secret     []byte
+	issuer     string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	now        func() time.Time
+}
+
+// NewService создает сервис с ключом подписи HS256
+func NewService(secret []byte, issuer string) *Service {
+	return &Service{
+		accounts:   make(map[string]*Account),
+		refresh:    make(map[string]refreshToken),
+		keys:       make(map[string]*APIKey),
+		certRoles:  make(map[string]Role),
+		secret:     secret,
+		issuer:     issuer,
+		AccessTTL:  15 * time.Minute,
+		RefreshTTL: 7 * 24 * time.Hour,
+		now:        time.Now,
+	}
+}
+
+// FromEnv создает сервис по переменным окружения:
+// JWT_SECRET - ключ подписи (без него - случайный, токены не переживут перезапуск),
+// ADMIN_PASSWORD - пароль учетной записи "admin",
+// TLS_CLIENT_ROLES - роли владельцев сертификатов клиентов: "batch-job=auditor,ops=admin".
+func FromEnv(issuer string) *Service {
+	secret := []byte(os.Getenv("JWT_SECRET"))
+	if len(secret) == 0 {
+		slog.Warn("JWT_SECRET не задан, используется случайный ключ подписи")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(err)
+		}
+	}
+	s := NewService(secret, issuer)
+	if pass := os.Getenv("ADMIN_PASSWORD"); pass != "" {
+		if err := s.Register(AdminLogin, pass, RoleAdmin); err != nil {
+			slog.Error("учетная запись администратора не создана", "error", err)
+		}
+	}
+	if err := s.certRolesFromEnv(); err != nil {
+		slog.Error("роли сертификатов клиентов не назначены", "error", err)
+	}
+	return s
+}
+
+// CheckPassword проверяет требования к паролю
+func CheckPassword(password string) error {
+	if len(password) < minPasswordLen {
+		return ErrWeakPassword
+	}
+	return nil
+}
+
+// Register создает учетную запись с паролем и ролью
+func (s *Service) Register(login, password string, role Role) error {
+	p, err := s.Prepare(password, role)
+	if err != nil {
+		return err
+	}
+	return p.Register(login)
+}
+
+// Pending - учетная запись с проверенным и захешированным паролем, еще без логина.
+// Нужна, чтобы занять логин нового пользователя под блокировкой хранилища:
+// Register не считает bcrypt и не ждет.
+type Pending struct {
+	s    *Service
+	role Role
+	hash []byte
+}
+
+// Prepare проверяет пароль и роль и хеширует пароль (ошибки: ErrWeakPassword, ErrUnknownRole)
+func (s *Service) Prepare(password string, role Role) (Pending, error) {
+	if err := CheckPassword(password); err != nil {
+		return Pending{}, err
+	}
+	if !role.valid() {
+		return Pending{}, ErrUnknownRole
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	if err != nil {
+		return Pending{}, err
+	}
+	return Pending{s: s, role: role, hash: hash}, nil
+}
+
+// Register создает подготовленную учетную запись с логином login (ErrLoginTaken - логин занят).
+// Запись без пароля (роль или блокировка прежнего владельца логина) заменяется:
+// новый пользователь не наследует ни роль, ни блокировку.
+func (p Pending) Register(login string) error {
+	s := p.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if acc, ok := s.accounts[login]; ok && acc.HasPassword {
+		return ErrLoginTaken
+	}
+	s.accounts[login] = &Account{Login: login, Role: p.role, HasPassword: true, passwordHash: p.hash}
+	return nil
+}
+
+// Remove удаляет учетную запись и отзывает ее токены обновления
+func (s *Service) Remove(login string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.accounts, login)
+	s.revokeAll(login)
+}
+
+// Account возвращает копию учетной записи; false - записи нет
+func (s *Service) Account(login string) (Account, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if acc, ok := s.accounts[login]; ok {
+		return *acc, true
+	}
+	return Account{}, false
+}
+
+// Accounts возвращает все учетные записи по возрастанию логина
+func (s *Service) Accounts() []Account {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Account, 0, len(s.accounts))
+	for _, acc := range s.accounts {
+		out = append(out, *acc)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Login < out[j].Login })
+	return out
+}
+
+// account возвращает учетную запись, создавая запись без пароля (под s.mu)
+func (s *Service) account(login string) *Account {
+	acc, ok := s.accounts[login]
+	if !ok {
+		acc = &Account{Login: login, Role: RoleUser}
+		s.accounts[login] = acc
+	}
+	return acc
+}
+
+// CheckRole проверяет, что роль известна
+func CheckRole(role Role) error {
+	if !role.valid() {
+		return ErrUnknownRole
+	}
+	return nil
+}
+
+// SetRole назначает пользователю роль
+func (s *Service) SetRole(login string, role Role) error {
+	if err := CheckRole(role); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.account(login).Role = role
+	return nil
+}
+
+// Suspend блокирует пользователя: вход и выданные токены перестают действовать
+func (s *Service) Suspend(login string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.account(login).Suspended = true
+	s.revokeAll(login)
+}
+
+// Reinstate снимает блокировку пользователя
+func (s *Service) Reinstate(login string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if acc, ok := s.accounts[login]; ok {
+		acc.Suspended = false
+	}
+}
+
+// Suspended - заблокирован ли пользователь
+func (s *Service) Suspended(login string) bool {
+	acc, ok := s.Account(login)
+	return ok && acc.Suspended
+}
+
+// revokeAll отзывает все токены обновления пользователя (под s.mu)
+func (s *Service) revokeAll(login string) {
+	for key, rt := range s.refresh {
+		if rt.login == login {
+			delete(s.refresh, key)
+		}
+	}
+}
+
+// Login проверяет пароль и выдает пару токенов
+func (s *Service) Login(login, password string) (Tokens, error) {
+	acc, ok := s.Account(login)
+	if !ok || !acc.HasPassword {
+		//сравнение с пустым хешем выравнивает время ответа для несуществующего логина
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return Tokens{}, ErrBadCredentials
+	}
+	if bcrypt.CompareHashAndPassword(acc.passwordHash, []byte(password)) != nil {
+		return Tokens{}, ErrBadCredentials
+	}
+	if acc.Suspended {
+		return Tokens{}, ErrSuspended
+	}
+	return s.issue(acc)
+}
+
+// хеш для выравнивания времени проверки несуществующего логина
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+// Refresh обменивает токен обновления на новую пару (старый токен больше не действует)
+func (s *Service) Refresh(token string) (Tokens, error) {
+	key := hashToken(token)
+	s.mu.Lock()
+	rt, ok := s.refresh[key]
+	delete(s.refresh, key)
+	var acc Account
+	if a, found := s.accounts[rt.login]; ok && found {
+		acc = *a
+	} else {
+		ok = false
+	}
+	s.mu.Unlock()
+	if !ok || s.now().After(rt.expires) {
+		return Tokens{}, ErrInvalidToken
+	}
+	if acc.Suspended {
+		return Tokens{}, ErrSuspended
+	}
+	return s.issue(acc)
+}
+
+// Revoke отзывает токен обновления (выход)
+func (s *Service) Revoke(token string) {
+	s.mu.Lock()
+	delete(s.refresh, hashToken(token))
+	s.mu.Unlock()
+}
+
+// Verify проверяет подпись и срок JWT и возвращает владельца.
+// Роль и блокировка берутся из текущей учетной записи, а не из токена.
+func (s *Service) Verify(token string) (Identity, error) {
+	var c claims
+	_, err := jwt.ParseWithClaims(token, &c, func(*jwt.Token) (interface{}, error) {
+		return s.secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(s.now),
+	)
+	if err != nil || c.Subject == "" {
+		return Identity{}, ErrInvalidToken
+	}
+	acc, ok := s.Account(c.Subject)
+	if !ok { //пользователь удален
+		return Identity{}, ErrInvalidToken
+	}
+	if acc.Suspended {
+		return Identity{}, ErrSuspended
+	}
+	return Identity{Subject: acc.Login, Role: acc.Role}, nil
+}
+
+// issue подписывает JWT и выдает новый токен обновления
+func (s *Service) issue(acc Account) (Tokens, error) {
+	now := s.now()
+	access, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims{
+		Role: acc.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   acc.Login,
+			Issuer:    s.issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.AccessTTL)),
+		},
+	}).SignedString(s.secret)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return Tokens{}, err
+	}
+	refresh := hex.EncodeToString(raw)
+	s.mu.Lock()
+	s.refresh[hashToken(refresh)] = refreshToken{login: acc.Login, expires: now.Add(s.RefreshTTL)}
+	s.mu.Unlock()
+
+	return Tokens{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.AccessTTL.Seconds()),
+	}, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
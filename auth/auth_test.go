@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const password = "secret123"
+
+func TestMain(m *testing.M) {
+	passwordCost = bcrypt.MinCost
+	os.Exit(m.Run())
+}
+
+// newService - сервис с управляемыми часами и учетными записями "alice" (user) и "root" (admin)
+func newService(t *testing.T) (*Service, *time.Time) {
+	t.Helper()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := NewService([]byte("test-secret"), "test")
+	s.now = func() time.Time { return now }
+	for login, role := range map[string]Role{"alice": RoleUser, "root": RoleAdmin} {
+		if err := s.Register(login, password, role); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s, &now
+}
+
+func login(t *testing.T, s *Service, name string) Tokens {
+	t.Helper()
+	tokens, err := s.Login(name, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokens
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name  string
+		token func(s *Service, now *time.Time) string
+		want  Identity
+		err   error
+	}{
+		{"действительный", func(s *Service, _ *time.Time) string {
+			return login(t, s, "alice").AccessToken
+		}, Identity{Subject: "alice", Role: RoleUser}, nil},
+		{"роль из учетной записи, а не из токена", func(s *Service, _ *time.Time) string {
+			token := login(t, s, "alice").AccessToken
+			s.SetRole("alice", RoleAuditor)
+			return token
+		}, Identity{Subject: "alice", Role: RoleAuditor}, nil},
+		{"истек", func(s *Service, now *time.Time) string {
+			token := login(t, s, "alice").AccessToken
+			*now = now.Add(s.AccessTTL + time.Second)
+			return token
+		}, Identity{}, ErrInvalidToken},
+		{"заблокирован", func(s *Service, _ *time.Time) string {
+			token := login(t, s, "alice").AccessToken
+			s.Suspend("alice")
+			return token
+		}, Identity{}, ErrSuspended},
+		{"учетная запись удалена", func(s *Service, _ *time.Time) string {
+			token := login(t, s, "alice").AccessToken
+			s.Remove("alice")
+			return token
+		}, Identity{}, ErrInvalidToken},
+		{"чужой издатель", func(s *Service, _ *time.Time) string {
+			other := NewService([]byte("test-secret"), "other") //тот же ключ подписи
+			other.Register("alice", password, RoleAdmin)
+			return login(t, other, "alice").AccessToken
+		}, Identity{}, ErrInvalidToken},
+		{"чужой ключ подписи", func(s *Service, _ *time.Time) string {
+			other := NewService([]byte("other-secret"), "test")
+			other.Register("alice", password, RoleUser)
+			return login(t, other, "alice").AccessToken
+		}, Identity{}, ErrInvalidToken},
+		{"подпись испорчена", func(s *Service, _ *time.Time) string {
+			token := login(t, s, "alice").AccessToken
+			return token[:len(token)-2] + "xx"
+		}, Identity{}, ErrInvalidToken},
+		{"не JWT", func(*Service, *time.Time) string { return "garbage" }, Identity{}, ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, now := newService(t)
+			got, err := s.Verify(tt.token(s, now))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ошибка %v, ожидалась %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("получено %+v, ожидалось %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogin(t *testing.T) {
+	s, _ := newService(t)
+	s.Suspend("root")
+	tests := []struct {
+		login, password string
+		err             error
+	}{
+		{"alice", password, nil},
+		{"alice", "wrong-password", ErrBadCredentials},
+		{"nobody", password, ErrBadCredentials},
+		{"root", password, ErrSuspended},
+	}
+	for _, tt := range tests {
+		if _, err := s.Login(tt.login, tt.password); !errors.Is(err, tt.err) {
+			t.Errorf("%s/%s: ошибка %v, ожидалась %v", tt.login, tt.password, err, tt.err)
+		}
+	}
+}
+
+// Токен обновления меняется при каждом обмене; старый больше не действует
+func TestRefreshRotation(t *testing.T) {
+	s, now := newService(t)
+	first := login(t, s, "alice")
+	second, err := s.Refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("токен обновления не сменился")
+	}
+	if _, err := s.Verify(second.AccessToken); err != nil {
+		t.Errorf("новый токен доступа не действует: %v", err)
+	}
+	if _, err := s.Refresh(first.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("повторный обмен старого токена: ошибка %v, ожидалась %v", err, ErrInvalidToken)
+	}
+	third, err := s.Refresh(second.RefreshToken)
+	if err != nil {
+		t.Fatalf("новый токен обновления не действует: %v", err)
+	}
+
+	s.Revoke(third.RefreshToken) //выход
+	if _, err := s.Refresh(third.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("токен после выхода: ошибка %v, ожидалась %v", err, ErrInvalidToken)
+	}
+
+	expired := login(t, s, "alice")
+	*now = now.Add(s.RefreshTTL + time.Second)
+	if _, err := s.Refresh(expired.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("истекший токен: ошибка %v, ожидалась %v", err, ErrInvalidToken)
+	}
+}
+
+// Блокировка отзывает токены обновления, удаление - тоже
+func TestRefreshRevokedBySuspendAndRemove(t *testing.T) {
+	for name, revoke := range map[string]func(*Service){
+		"блокировка": func(s *Service) { s.Suspend("alice") },
+		"удаление":   func(s *Service) { s.Remove("alice") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			s, _ := newService(t)
+			tokens := login(t, s, "alice")
+			revoke(s)
+			s.Reinstate("alice")
+			if _, err := s.Refresh(tokens.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("ошибка %v, ожидалась %v", err, ErrInvalidToken)
+			}
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	s, _ := newService(t)
+	if err := s.Register("alice", password, RoleUser); !errors.Is(err, ErrLoginTaken) {
+		t.Errorf("логин занят: ошибка %v, ожидалась %v", err, ErrLoginTaken)
+	}
+	//запись без пароля от прежнего владельца логина
+	s.SetRole("bob", RoleAdmin)
+	s.Suspend("bob")
+	if err := s.Register("bob", password, RoleUser); err != nil {
+		t.Fatal(err)
+	}
+	if acc, _ := s.Account("bob"); acc.Role != RoleUser || acc.Suspended || !acc.HasPassword {
+		t.Errorf("учетная запись %+v, ожидалась роль user с паролем, без блокировки", acc)
+	}
+	login(t, s, "bob")
+}
+
+func TestAuthorize(t *testing.T) {
+	user := Identity{Subject: "alice", Role: RoleUser}
+	tests := []struct {
+		name    string
+		id      *Identity //nil - анонимный запрос
+		subject string
+		perm    Permission
+		err     error
+	}{
+		{"анонимный", nil, "alice", PermUsersWrite, ErrUnauthenticated},
+		{"сам пользователь", &user, "alice", PermUsersWrite, nil},
+		{"другой пользователь", &user, "bob", PermUsersWrite, ErrForbidden},
+		{"другой пользователь без права чтения", &user, "bob", PermUsersRead, ErrForbidden},
+		{"аудитор читает", &Identity{Subject: "audit", Role: RoleAuditor}, "bob", PermUsersRead, nil},
+		{"аудитор изменяет", &Identity{Subject: "audit", Role: RoleAuditor}, "bob", PermUsersWrite, ErrForbidden},
+		{"администратор", &Identity{Subject: "root", Role: RoleAdmin}, "bob", PermUsersDelete, nil},
+		{"ключ read читает", &Identity{Subject: "apikey:1", Scope: ScopeRead}, "bob", PermUsersRead, nil},
+		{"ключ read изменяет", &Identity{Subject: "apikey:1", Scope: ScopeRead}, "bob", PermUsersWrite, ErrForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.id != nil {
+				ctx = WithIdentity(ctx, *tt.id)
+			}
+			if err := Authorize(ctx, tt.subject, tt.perm); !errors.Is(err, tt.err) {
+				t.Errorf("ошибка %v, ожидалась %v", err, tt.err)
+			}
+		})
+	}
+}
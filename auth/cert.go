@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Вход по сертификату клиента (mTLS): владелец запроса - CN проверенного сертификата.
+// Роль берется из учетной записи с таким логином, иначе из SetCertificateRole,
+// иначе - обычный пользователь.
+
+// SetCertificateRole назначает роль владельцу сертификата с CN (например, службе без учетной записи)
+func (s *Service) SetCertificateRole(cn string, role Role) error {
+	if !role.valid() {
+		return ErrUnknownRole
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.certRoles[cn] = role
+	return nil
+}
+
+// certRolesFromEnv читает TLS_CLIENT_ROLES="batch-job=auditor,ops=admin"
+func (s *Service) certRolesFromEnv() error {
+	for _, pair := range strings.Split(os.Getenv("TLS_CLIENT_ROLES"), ",") {
+		cn, role, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		if err := s.SetCertificateRole(strings.TrimSpace(cn), Role(strings.TrimSpace(role))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// certIdentity - владелец проверенного сертификата клиента; false - сертификата нет
+func (s *Service) certIdentity(r *http.Request) (Identity, bool, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return Identity{}, false, nil
+	}
+	cn := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	if cn == "" {
+		return Identity{}, false, ErrInvalidToken
+	}
+	if acc, ok := s.Account(cn); ok {
+		if acc.Suspended {
+			return Identity{}, false, ErrSuspended
+		}
+		return Identity{Subject: cn, Role: acc.Role}, true, nil
+	}
+	s.mu.Lock()
+	role, ok := s.certRoles[cn]
+	s.mu.Unlock()
+	if !ok {
+		role = RoleUser
+	}
+	return Identity{Subject: cn, Role: role}, true, nil
+}
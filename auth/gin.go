@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Gin - промежуточный обработчик "Джин": проверяет Bearer-токен и кладет
+// владельца запроса в контекст. Запросы без токена проходят анонимно.
+func (s *Service) Gin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok, err := s.authenticate(c.Request)
+		if err != nil {
+			Deny(c.Writer, c.Request, err)
+			c.Abort()
+			return
+		}
+		if ok {
+			c.Request = c.Request.WithContext(WithIdentity(c.Request.Context(), id))
+		}
+		c.Next()
+	}
+}
+
+// RequireGin пропускает к маршруту "Джин" только владельцев запроса со всеми правами perms
+// (без прав - любого вошедшего пользователя)
+func RequireGin(perms ...Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := Check(c.Request.Context(), perms...); err != nil {
+			Deny(c.Writer, c.Request, err)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
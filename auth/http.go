@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"Network-exchange/logging"
+	"Network-exchange/secure"
+)
+
+// Authorization: Bearer <token>
+const bearerPrefix = "Bearer "
+
+// HeaderAPIKey - заголовок с ключом API (альтернатива Authorization: Bearer <key>)
+const HeaderAPIKey = "X-API-Key"
+
+// bearerToken достает токен из заголовка Authorization ("" - заголовка нет)
+func bearerToken(r *http.Request) string {
+	h := r.Header.Get("Authorization")
+	if len(h) > len(bearerPrefix) && strings.EqualFold(h[:len(bearerPrefix)], bearerPrefix) {
+		return strings.TrimSpace(h[len(bearerPrefix):])
+	}
+	return ""
+}
+
+// authenticate проверяет JWT, ключ API или сертификат клиента (mTLS): анонимный запрос
+// (без них) допустим, недействительный токен или ключ - ошибка
+func (s *Service) authenticate(r *http.Request) (Identity, bool, error) {
+	var (
+		id  Identity
+		err error
+	)
+	if key := r.Header.Get(HeaderAPIKey); key != "" {
+		id, err = s.VerifyKey(key)
+	} else if token := bearerToken(r); isAPIKey(token) {
+		id, err = s.VerifyKey(token)
+	} else if token != "" {
+		id, err = s.Verify(token)
+	} else {
+		return s.certIdentity(r)
+	}
+	if err != nil {
+		return Identity{}, false, err
+	}
+	return id, true, nil
+}
+
+// Identify - владелец запроса для транспортов, которые отвечают на ошибку по-своему (gRPC);
+// false - запрос анонимный
+func (s *Service) Identify(r *http.Request) (Identity, bool, error) {
+	return s.authenticate(r)
+}
+
+// WriteError отвечает ошибкой в формате JSON с ID запроса
+func WriteError(w http.ResponseWriter, r *http.Request, status int, text string) {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="network-exchange"`)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":      text,
+		"request_id": logging.RequestID(r.Context()),
+	})
+}
+
+// Unauthorized - ответ 401 на запрос без действительного токена
+func Unauthorized(w http.ResponseWriter, r *http.Request) {
+	WriteError(w, r, http.StatusUnauthorized, "требуется вход: заголовок Authorization: Bearer")
+}
+
+// Forbidden - ответ 403 на попытку действовать за другого пользователя
+func Forbidden(w http.ResponseWriter, r *http.Request) {
+	WriteError(w, r, http.StatusForbidden, "недостаточно прав")
+}
+
+// LoginHandler - POST /login {"login":"...","password":"..."}
+func (s *Service) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Login    string `json:"login"`
+		Password string `json:"password"`
+	}
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	logging.SetUser(r.Context(), req.Login)
+	tokens, err := s.Login(req.Login, req.Password)
+	if err != nil {
+		Deny(w, r, err)
+		return
+	}
+	writeTokens(w, tokens)
+}
+
+// RefreshHandler - POST /token/refresh {"refresh_token":"..."}
+func (s *Service) RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	tokens, err := s.Refresh(req.RefreshToken)
+	if errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrSuspended) {
+		Deny(w, r, err)
+		return
+	} else if err != nil {
+		WriteError(w, r, http.StatusInternalServerError, "внутренняя ошибка сервера")
+		return
+	}
+	writeTokens(w, tokens)
+}
+
+// LogoutHandler - POST /logout {"refresh_token":"..."}
+func (s *Service) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	s.Revoke(req.RefreshToken)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeTokens(w http.ResponseWriter, tokens Tokens) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Cache-Control", "no-store") //токены не кешируются
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// Deny отвечает на отказ Authorize/Check и ошибку проверки токена:
+// 401 для анонимного запроса и недействительного токена, иначе 403
+func Deny(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrUnauthenticated), errors.Is(err, ErrInvalidToken):
+		Unauthorized(w, r)
+	case errors.Is(err, ErrBadCredentials):
+		WriteError(w, r, http.StatusUnauthorized, err.Error())
+	case errors.Is(err, ErrSuspended):
+		WriteError(w, r, http.StatusForbidden, err.Error())
+	default:
+		Forbidden(w, r)
+	}
+}
+
+// KeysHandler - управление ключами API:
+// GET /admin/apikeys - список ключей,
+// POST /admin/apikeys {"name":"batch","scope":"read","expires_in_days":30} - выпуск ключа
+func (s *Service) KeysHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, s.Keys())
+		return
+	}
+	var req struct {
+		Name          string `json:"name"`
+		Scope         Scope  `json:"scope"`
+		ExpiresInDays int    `json:"expires_in_days"` //0 - бессрочный
+	}
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.Name == "" || req.ExpiresInDays < 0 {
+		WriteError(w, r, http.StatusBadRequest, "неправильный, некорректный запрос")
+		return
+	}
+	owner, _ := FromContext(r.Context())
+	key, raw, err := s.CreateKey(req.Name, req.Scope, owner.Subject, time.Duration(req.ExpiresInDays)*24*time.Hour)
+	if err != nil {
+		WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store") //ключ показывается один раз
+	writeJSON(w, http.StatusCreated, struct {
+		APIKey
+		Key string `json:"key"`
+	}{key, raw})
+}
+
+// ServeRevokeKey - DELETE /admin/apikeys/<id>: отзыв ключа
+func (s *Service) ServeRevokeKey(w http.ResponseWriter, r *http.Request, id string) {
+	if err := s.RevokeKey(id); err != nil {
+		WriteError(w, r, http.StatusNotFound, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeBody читает JSON из тела запроса; при ошибке отвечает 400 или 413
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := secure.DecodeJSON(r, v); err != nil {
+		if secure.TooLarge(err) {
+			secure.WriteTooLarge(w, r)
+		} else {
+			WriteError(w, r, http.StatusBadRequest, "неправильный, некорректный запрос")
+		}
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+// Identity - аутентифицированный владелец запроса
+type Identity struct {
+	Subject string //имя ("Джин") или ID ("Горилла") пользователя, "apikey:<ID>" для ключа API
+	Role    Role   //роль пользователя (вход по JWT)
+	Scope   Scope  //область действия ключа API (вход по ключу)
+}
+
+// Can - есть ли у владельца запроса право
+func (id Identity) Can(p Permission) bool {
+	if id.Scope != "" {
+		return id.Scope.Can(p)
+	}
+	return id.Role.Can(p)
+}
+
+type ctxKey struct{}
+
+// WithIdentity кладет владельца запроса в контекст
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext возвращает владельца запроса; false - запрос анонимный
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(ctxKey{}).(Identity)
+	return id, ok
+}
+
+// Ошибки авторизации
+var (
+	ErrUnauthenticated = errors.New("требуется вход")
+	ErrForbidden       = errors.New("недостаточно прав")
+)
+
+// Authorize проверяет право действовать от имени пользователя subject:
+// сам пользователь может всегда, другой - только при наличии права perm.
+// nil - разрешено, ErrUnauthenticated - запрос анонимный, ErrForbidden - нет прав.
+func Authorize(ctx context.Context, subject string, perm Permission) error {
+	id, ok := FromContext(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+	if id.Subject != subject && !id.Can(perm) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Check проверяет наличие прав у владельца запроса
+func Check(ctx context.Context, perms ...Permission) error {
+	id, ok := FromContext(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+	for _, p := range perms {
+		if !id.Can(p) {
+			return ErrForbidden
+		}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package auth
+
+import "net/http"
+
+// Mux - промежуточный обработчик "Гориллы" (router.Use): проверяет Bearer-токен
+// и кладет владельца запроса в контекст. Запросы без токена проходят анонимно.
+func (s *Service) Mux(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok, err := s.authenticate(r)
+		if err != nil {
+			Deny(w, r, err)
+			return
+		}
+		if ok {
+			r = r.WithContext(WithIdentity(r.Context(), id))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Require пропускает к маршруту "Гориллы" только владельцев запроса со всеми правами perms
+// (без прав - любого вошедшего пользователя): router.Handle(path, auth.Require(perm)(handler))
+func Require(perms ...Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := Check(r.Context(), perms...); err != nil {
+				Deny(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
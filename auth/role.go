@@ -0,0 +1,48 @@
+package auth
+
+// Role - роль пользователя
+type Role string
+
+// Роли
+const (
+	RoleUser    Role = "user"    //обычный пользователь: только свой профиль
+	RoleAuditor Role = "auditor" //только чтение служебных данных
+	RoleAdmin   Role = "admin"   //служба поддержки: любые действия
+)
+
+// Permission - право на действие над любыми пользователями (не только над собой)
+type Permission string
+
+// Права
+const (
+	PermUsersRead    Permission = "users:read"    //список пользователей со статусом учетных записей
+	PermUsersWrite   Permission = "users:write"   //изменение чужих профилей и дружбы от чужого имени
+	PermUsersDelete  Permission = "users:delete"  //удаление любого пользователя
+	PermUsersSuspend Permission = "users:suspend" //блокировка и разблокировка
+	PermRolesManage  Permission = "roles:manage"  //назначение ролей
+	PermKeysManage   Permission = "keys:manage"   //выпуск и отзыв ключей API
+	PermHooksManage  Permission = "hooks:manage"  //подписки на вебхуки
+	PermAuditRead    Permission = "audit:read"    //журнал аудита
+)
+
+// права каждой роли
+var rolePermissions = map[Role][]Permission{
+	RoleUser:    nil,
+	RoleAuditor: {PermUsersRead, PermAuditRead},
+	RoleAdmin:   {PermUsersRead, PermUsersWrite, PermUsersDelete, PermUsersSuspend, PermRolesManage, PermKeysManage, PermHooksManage, PermAuditRead},
+}
+
+func (r Role) valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Can - есть ли у роли право
+func (r Role) Can(p Permission) bool {
+	for _, have := range rolePermissions[r] {
+		if have == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRolePermissions(t *testing.T) {
+	all := []Permission{PermUsersRead, PermUsersWrite, PermUsersDelete, PermUsersSuspend,
+		PermRolesManage, PermKeysManage, PermHooksManage, PermAuditRead}
+	tests := []struct {
+		role Role
+		want []Permission
+	}{
+		{RoleUser, nil},
+		{RoleAuditor, []Permission{PermUsersRead, PermAuditRead}},
+		{RoleAdmin, all},
+		{Role("root"), nil}, //неизвестная роль - без прав
+	}
+	for _, tt := range tests {
+		for _, p := range all {
+			want := false
+			for _, w := range tt.want {
+				want = want || w == p
+			}
+			if got := tt.role.Can(p); got != want {
+				t.Errorf("%s.Can(%s) = %v, ожидалось %v", tt.role, p, got, want)
+			}
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name  string
+		id    *Identity //nil - анонимный запрос
+		perms []Permission
+		err   error
+	}{
+		{"анонимный", nil, nil, ErrUnauthenticated},
+		{"вошедший без прав", &Identity{Subject: "alice", Role: RoleUser}, nil, nil},
+		{"пользователь", &Identity{Subject: "alice", Role: RoleUser}, []Permission{PermUsersRead}, ErrForbidden},
+		{"аудитор", &Identity{Subject: "audit", Role: RoleAuditor}, []Permission{PermUsersRead, PermAuditRead}, nil},
+		{"аудитор, не все права", &Identity{Subject: "audit", Role: RoleAuditor}, []Permission{PermUsersRead, PermUsersWrite}, ErrForbidden},
+		{"администратор", &Identity{Subject: "root", Role: RoleAdmin}, []Permission{PermRolesManage, PermKeysManage}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.id != nil {
+				ctx = WithIdentity(ctx, *tt.id)
+			}
+			if err := Check(ctx, tt.perms...); !errors.Is(err, tt.err) {
+				t.Errorf("ошибка %v, ожидалась %v", err, tt.err)
+			}
+		})
+	}
+}
+
+// Маршрут администратора: без токена - 401, без прав или заблокирован - 403
+func TestRequire(t *testing.T) {
+	s, _ := newService(t)
+	if err := s.Register("mallory", password, RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	tokens := map[string]string{}
+	for _, name := range []string{"alice", "root", "mallory"} {
+		tokens[name] = login(t, s, name).AccessToken
+	}
+	s.Suspend("mallory")
+	h := s.Mux(Require(PermRolesManage)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	tests := []struct {
+		name, auth string
+		status     int
+	}{
+		{"без токена", "", http.StatusUnauthorized},
+		{"недействительный токен", "Bearer garbage", http.StatusUnauthorized},
+		{"пользователь", "Bearer " + tokens["alice"], http.StatusForbidden},
+		{"администратор", "Bearer " + tokens["root"], http.StatusNoContent},
+		{"заблокированный администратор", "Bearer " + tokens["mallory"], http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/admin/users/bob/role", nil)
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.status {
+				t.Errorf("код %d, ожидался %d", w.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestSetRole(t *testing.T) {
+	s, _ := newService(t)
+	if err := s.SetRole("alice", Role("root")); !errors.Is(err, ErrUnknownRole) {
+		t.Errorf("неизвестная роль: ошибка %v, ожидалась %v", err, ErrUnknownRole)
+	}
+	if err := s.SetRole("alice", RoleAuditor); err != nil {
+		t.Fatal(err)
+	}
+	if acc, _ := s.Account("alice"); acc.Role != RoleAuditor || !acc.HasPassword {
+		t.Errorf("учетная запись %+v, ожидалась роль auditor с паролем", acc)
+	}
+}
@@ -0,0 +1,165 @@
+// Package client - клиент Go для API пользователей и дружбы (/v2): типизированные запросы
+// с контекстом, повтор идемпотентных запросов с нарастающей паузой и ошибки problem+json
+// в виде *Error (errors.Is(err, client.ErrNotFound) и т.п.).
+//
+//	c := client.New("http://localhost:8080")
+//	c.Token = tokens.AccessToken //или c.APIKey = "nxk_..."
+//	page, err := c.ListUsers(ctx, client.Filter{MinAge: 18})
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Значения по умолчанию
+const (
+	DefaultRetries = 3                      //повторы идемпотентного запроса (всего попыток - 4)
+	DefaultBackoff = 200 * time.Millisecond //пауза перед первым повтором, далее удваивается
+	MaxBackoff     = 5 * time.Second        //предел паузы; при большем Retry-After ошибка возвращается сразу
+	userAgent      = "network-exchange-client/2"
+)
+
+// Client - клиент API; поля можно менять до первого запроса
+type Client struct {
+	BaseURL string       //адрес сервиса без завершающей "/" ("http://localhost:8080")
+	HTTP    *http.Client //транспорт (http.DefaultClient, если nil)
+	Token   string       //токен доступа: Authorization: Bearer <token>
+	APIKey  string       //ключ API: X-API-Key (если задан, Token не передается)
+	Retries int          //повторы GET, PUT и DELETE при сетевых ошибках, 429 и 502-504
+	Backoff time.Duration
+}
+
+// New создает клиент с повторами по умолчанию
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		HTTP:    http.DefaultClient,
+		Retries: DefaultRetries,
+		Backoff: DefaultBackoff,
+	}
+}
+
+// idempotent - запрос можно повторить без побочных эффектов
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryable - ответ, после которого запрос стоит повторить
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// do выполняет запрос: in - тело (или nil), out - куда декодировать ответ 2xx (или nil);
+// возвращает код ответа
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) (int, error) {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return 0, err
+		}
+	}
+	attempts := 1
+	if idempotent(method) && c.Retries > 0 {
+		attempts += c.Retries
+	}
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, path, body)
+		last := attempt+1 >= attempts
+		if err != nil {
+			if last || ctx.Err() != nil {
+				return 0, err
+			}
+			if err := c.wait(ctx, attempt, 0); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			defer resp.Body.Close()
+			if out != nil && resp.StatusCode != http.StatusNoContent {
+				if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+					return resp.StatusCode, err
+				}
+			}
+			return resp.StatusCode, nil
+		}
+		apiErr := readError(resp)
+		if last || !retryable(resp.StatusCode) || apiErr.RetryAfter > MaxBackoff {
+			return resp.StatusCode, apiErr
+		}
+		if err := c.wait(ctx, attempt, apiErr.RetryAfter); err != nil {
+			return resp.StatusCode, apiErr
+		}
+	}
+}
+
+// send - одна попытка запроса
+func (c *Client) send(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body) //для каждой попытки - новое чтение тела
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json, application/problem+json")
+	req.Header.Set("User-Agent", userAgent)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.APIKey != "" {
+		req.Header.Set("X-API-Key", c.APIKey)
+	} else if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	httpClient := c.HTTP
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return httpClient.Do(req)
+}
+
+// wait - пауза перед повтором: Retry-After сервера или Backoff*2^attempt со случайной добавкой
+func (c *Client) wait(ctx context.Context, attempt int, retryAfter time.Duration) error {
+	d := retryAfter
+	if d <= 0 {
+		d = c.Backoff << attempt
+		if d > 1 {
+			d += rand.N(d / 2) //разносим повторы разных клиентов
+		}
+	}
+	t := time.NewTimer(min(d, MaxBackoff))
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// parseRetryAfter - заголовок Retry-After в секундах (HTTP-дата не поддерживается сервисом)
+func parseRetryAfter(h string) time.Duration {
+	if n, err := strconv.Atoi(h); err == nil && n > 0 {
+		return time.Duration(n) * time.Second
+	}
+	return 0
+}
@@ -0,0 +1,226 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"Network-exchange/apiv2"
+	"Network-exchange/auth"
+	"Network-exchange/client"
+	"Network-exchange/core"
+	"Network-exchange/logging"
+	"Network-exchange/openapi"
+
+	"github.com/gorilla/mux"
+)
+
+const password = "secret123"
+
+func TestMain(m *testing.M) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil))) //журнал запросов не нужен
+	os.Exit(m.Run())
+}
+
+// newRouter - маршруты /login и /v2 как в gorilla_Rest.go: проверка JWT и ключей API,
+// проверка запросов и ответов по описанию API (несоответствие ответа - 500)
+func newRouter(t *testing.T) (*mux.Router, *auth.Service) {
+	t.Helper()
+	store := core.NewStore(false)
+	svc := auth.NewService([]byte("test-secret"), "test")
+	subject := func(u core.User) string { return strconv.Itoa(u.ID) }
+	store.SetViewer(func(ctx context.Context, u core.User) bool {
+		id, ok := auth.FromContext(ctx)
+		return ok && id.Subject == subject(u)
+	})
+	router := mux.NewRouter()
+	router.Use(logging.Mux, svc.Mux, (&openapi.Validator{Doc: openapi.GorillaSpec(), Responses: true}).Mux)
+	router.HandleFunc("/login", svc.LoginHandler).Methods("POST")
+	apiv2.New(store, svc, subject).Mux(router.PathPrefix(apiv2.Prefix).Subrouter())
+	return router, svc
+}
+
+func newServer(t *testing.T, h http.Handler) *client.Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	c := client.New(srv.URL + "/")
+	c.Backoff = time.Millisecond
+	return c
+}
+
+// create создает пользователя с паролем и возвращает клиент, вошедший от его имени
+func create(t *testing.T, c *client.Client, name string, age int) (client.User, *client.Client) {
+	t.Helper()
+	ctx := context.Background()
+	u, err := c.CreateUser(ctx, client.NewUser{Name: name, Age: age, Password: password})
+	if err != nil {
+		t.Fatalf("создание %s: %v", name, err)
+	}
+	own := client.New(c.BaseURL)
+	if _, err := own.Login(ctx, strconv.Itoa(u.ID), password); err != nil {
+		t.Fatalf("вход %s: %v", name, err)
+	}
+	return u, own
+}
+
+func TestUsersAndFriends(t *testing.T) {
+	ctx := context.Background()
+	router, _ := newRouter(t)
+	c := newServer(t, router)
+	alice, asAlice := create(t, c, "Alice", 30)
+	bob, _ := create(t, c, "Bob", 40)
+
+	if alice.ID == 0 || alice.Name != "Alice" || alice.Age != 30 || len(alice.Friends) != 0 {
+		t.Fatalf("создан %+v", alice)
+	}
+	page, err := c.ListUsers(ctx, client.Filter{MinAge: 35})
+	if err != nil || page.Total != 1 || page.Items[0].ID != bob.ID {
+		t.Fatalf("поиск по возрасту: %+v, %v", page, err)
+	}
+	if u, err := asAlice.SetAge(ctx, alice.ID, 31); err != nil || u.Age != 31 {
+		t.Fatalf("изменение возраста: %+v, %v", u, err)
+	}
+
+	created, err := asAlice.AddFriend(ctx, alice.ID, bob.ID)
+	if err != nil || !created {
+		t.Fatalf("дружба: %v, %v", created, err)
+	}
+	if created, err = asAlice.AddFriend(ctx, alice.ID, bob.ID); err != nil || created {
+		t.Fatalf("повторная дружба: %v, %v (ожидалось false без ошибки)", created, err)
+	}
+	friends, err := c.Friends(ctx, bob.ID)
+	if err != nil || len(friends) != 1 || friends[0].ID != alice.ID {
+		t.Fatalf("друзья Bob: %+v, %v", friends, err)
+	}
+	if err := asAlice.RemoveFriend(ctx, alice.ID, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+	if friends, err = c.Friends(ctx, bob.ID); err != nil || len(friends) != 0 {
+		t.Fatalf("друзья после прекращения дружбы: %+v, %v", friends, err)
+	}
+
+	if err := asAlice.DeleteUser(ctx, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetUser(ctx, alice.ID); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("удаленный пользователь: %v, ожидалась ErrNotFound", err)
+	}
+}
+
+func TestAllUsers(t *testing.T) {
+	router, _ := newRouter(t)
+	c := newServer(t, router)
+	for i := range 7 {
+		if _, err := c.CreateUser(context.Background(), client.NewUser{Name: "user" + strconv.Itoa(i), Age: 20 + i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	all, err := c.AllUsers(context.Background(), client.Filter{Name: "user", Limit: 3})
+	if err != nil || len(all) != 7 {
+		t.Fatalf("все пользователи по 3 на странице: %d, %v", len(all), err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	ctx := context.Background()
+	router, _ := newRouter(t)
+	c := newServer(t, router)
+	alice, asAlice := create(t, c, "Alice", 30)
+	bob, asBob := create(t, c, "Bob", 40)
+
+	_, err := c.CreateUser(ctx, client.NewUser{Name: "Nobody", Age: -1})
+	var apiErr *client.Error
+	if !errors.Is(err, client.ErrValidation) || !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest || apiErr.RequestID == "" {
+		t.Fatalf("возраст -1: %v, ожидалась ErrValidation с ID запроса", err)
+	}
+	if len(apiErr.Fields) == 0 || apiErr.Fields[0].Field != "age" {
+		t.Errorf("ошибки полей: %+v, ожидалось поле age", apiErr.Fields)
+	}
+	if _, err := c.SetAge(ctx, alice.ID, 50); !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("без входа: %v, ожидалась ErrUnauthorized", err)
+	}
+	if _, err := asBob.SetAge(ctx, alice.ID, 50); !errors.Is(err, client.ErrForbidden) {
+		t.Errorf("чужой пользователь: %v, ожидалась ErrForbidden", err)
+	}
+	if _, err := asAlice.AddFriend(ctx, alice.ID, alice.ID); !errors.Is(err, client.ErrConflict) {
+		t.Errorf("дружба с собой: %v, ожидалась ErrConflict", err)
+	}
+	if _, err := asAlice.AddFriend(ctx, alice.ID, 999); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("дружба с несуществующим: %v, ожидалась ErrNotFound", err)
+	}
+	if _, err := c.Login(ctx, strconv.Itoa(bob.ID), "wrong-password"); !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("неверный пароль: %v, ожидалась ErrUnauthorized", err)
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	ctx := context.Background()
+	router, svc := newRouter(t)
+	c := newServer(t, router)
+	alice, _ := create(t, c, "Alice", 30)
+	if err := svc.Register(auth.AdminLogin, "adminpass1", auth.RoleAdmin); err != nil { //владелец ключа
+		t.Fatal(err)
+	}
+	_, key, err := svc.CreateKey("batch", auth.ScopeWrite, auth.AdminLogin, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.APIKey = key
+	if u, err := c.SetAge(ctx, alice.ID, 45); err != nil || u.Age != 45 {
+		t.Fatalf("изменение с ключом write: %+v, %v", u, err)
+	}
+	if err := c.DeleteUser(ctx, alice.ID); !errors.Is(err, client.ErrForbidden) {
+		t.Fatalf("удаление с ключом write: %v, ожидалась ErrForbidden", err)
+	}
+}
+
+// flaky отвечает 503 на первые n запросов, остальные передает сервису
+func flaky(next http.Handler, n int32, calls *atomic.Int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= n {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "недоступен", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func TestRetries(t *testing.T) {
+	ctx := context.Background()
+	router, _ := newRouter(t)
+
+	var calls atomic.Int32
+	c := newServer(t, flaky(router, 2, &calls))
+	if _, err := c.ListUsers(ctx, client.Filter{}); err != nil || calls.Load() != 3 {
+		t.Fatalf("GET после двух 503: %v, запросов %d (ожидалось 3)", err, calls.Load())
+	}
+
+	calls.Store(0)
+	c = newServer(t, flaky(router, 1, &calls))
+	if _, err := c.CreateUser(ctx, client.NewUser{Name: "Once", Age: 20}); !errors.Is(err, client.ErrServer) || calls.Load() != 1 {
+		t.Fatalf("POST после 503: %v, запросов %d (POST не повторяется)", err, calls.Load())
+	}
+
+	calls.Store(0)
+	c = newServer(t, flaky(router, 10, &calls))
+	c.Retries = 2
+	if _, err := c.GetUser(ctx, 1); !errors.Is(err, client.ErrServer) || calls.Load() != 3 {
+		t.Fatalf("GET при постоянных 503: %v, запросов %d (ожидалось 3)", err, calls.Load())
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := c.ListUsers(ctx, client.Filter{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("отмененный контекст: %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Ошибки по типу problem+json (или коду ответа): errors.Is(err, client.ErrNotFound)
+var (
+	ErrValidation   = errors.New("запрос не соответствует описанию API")
+	ErrUnauthorized = errors.New("требуется вход")
+	ErrForbidden    = errors.New("недостаточно прав")
+	ErrNotFound     = errors.New("не найдено")
+	ErrConflict     = errors.New("конфликт с текущим состоянием")
+	ErrTooLarge     = errors.New("тело запроса слишком большое")
+	ErrRateLimited  = errors.New("превышен лимит запросов")
+	ErrServer       = errors.New("ошибка сервера")
+)
+
+// типы problem+json сервиса (пакет problem)
+var byType = map[string]error{
+	"/problems/validation":   ErrValidation,
+	"/problems/unauthorized": ErrUnauthorized,
+	"/problems/forbidden":    ErrForbidden,
+	"/problems/not-found":    ErrNotFound,
+	"/problems/conflict":     ErrConflict,
+	"/problems/too-large":    ErrTooLarge,
+	"/problems/internal":     ErrServer,
+}
+
+// коды ответов без problem+json (лимиты, проверка по описанию API и т.п.)
+var byStatus = map[int]error{
+	http.StatusBadRequest:            ErrValidation,
+	http.StatusUnauthorized:          ErrUnauthorized,
+	http.StatusForbidden:             ErrForbidden,
+	http.StatusNotFound:              ErrNotFound,
+	http.StatusConflict:              ErrConflict,
+	http.StatusRequestEntityTooLarge: ErrTooLarge,
+	http.StatusTooManyRequests:       ErrRateLimited,
+}
+
+// FieldError - ошибка отдельного поля запроса
+type FieldError struct {
+	Field   string `json:"поле"`
+	Message string `json:"ошибка"`
+}
+
+// Error - ответ сервиса с ошибкой
+type Error struct {
+	Status     int          //код ответа
+	Type       string       //тип problem+json ("" - ответ в другом формате)
+	Title      string       //краткое описание типа
+	Detail     string       //подробности
+	Instance   string       //путь запроса
+	RequestID  string       //X-Request-ID для поиска в журнале сервиса
+	Fields     []FieldError //ошибки полей при ErrValidation
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	msg := e.Title
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	if msg == "" {
+		msg = http.StatusText(e.Status)
+	}
+	return fmt.Sprintf("%d %s (request_id %s)", e.Status, msg, e.RequestID)
+}
+
+// Unwrap - одна из ошибок ErrValidation ... ErrServer
+func (e *Error) Unwrap() error {
+	if err, ok := byType[e.Type]; ok {
+		return err
+	}
+	if err, ok := byStatus[e.Status]; ok {
+		return err
+	}
+	if e.Status >= 500 {
+		return ErrServer
+	}
+	return nil
+}
+
+// readError читает тело ответа с ошибкой: problem+json или {"error":...,"errors":[...]}
+func readError(resp *http.Response) *Error {
+	defer resp.Body.Close()
+	e := &Error{
+		Status:     resp.StatusCode,
+		RequestID:  resp.Header.Get("X-Request-Id"),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	var body struct {
+		Type      string       `json:"type"`
+		Title     string       `json:"title"`
+		Detail    string       `json:"detail"`
+		Instance  string       `json:"instance"`
+		RequestID string       `json:"request_id"`
+		Errors    []FieldError `json:"errors"`
+		Error     string       `json:"error"`
+	}
+	if json.Unmarshal(data, &body) != nil {
+		e.Detail = strings.TrimSpace(string(data)) //текст вместо JSON
+		return e
+	}
+	e.Type, e.Title, e.Detail, e.Instance, e.Fields = body.Type, body.Title, body.Detail, body.Instance, body.Errors
+	if body.Error != "" {
+		e.Title = body.Error
+	}
+	if body.RequestID != "" {
+		e.RequestID = body.RequestID
+	}
+	return e
+}
@@ -0,0 +1,148 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// User - пользователь (Friends - ID друзей)
+type User struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Age     int    `json:"age"`
+	Friends []int  `json:"friends"`
+}
+
+// Page - страница списка пользователей
+type Page struct {
+	Items  []User `json:"items"`
+	Total  int    `json:"total"` //всего найдено (без учета Limit и Offset)
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+// Filter - условия поиска пользователей (нулевые значения не учитываются)
+type Filter struct {
+	Name   string //часть имени без учета регистра
+	MinAge int
+	MaxAge int
+	Limit  int //размер страницы (по умолчанию - 50, не больше 500)
+	Offset int
+}
+
+// NewUser - данные нового пользователя
+type NewUser struct {
+	Name     string `json:"name"`
+	Age      int    `json:"age"`
+	Password string `json:"password,omitempty"` //без пароля учетная запись не создается
+}
+
+// Tokens - пара токенов после входа
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"` //срок жизни AccessToken в секундах
+}
+
+func userPath(id int) string {
+	return "/v2/users/" + strconv.Itoa(id)
+}
+
+func (f Filter) query() string {
+	q := url.Values{}
+	if f.Name != "" {
+		q.Set("name", f.Name)
+	}
+	for key, n := range map[string]int{"min_age": f.MinAge, "max_age": f.MaxAge, "limit": f.Limit, "offset": f.Offset} {
+		if n > 0 {
+			q.Set(key, strconv.Itoa(n))
+		}
+	}
+	if len(q) == 0 {
+		return ""
+	}
+	return "?" + q.Encode()
+}
+
+// Login - вход (POST /login); при успехе токен доступа запоминается в клиенте
+func (c *Client) Login(ctx context.Context, login, password string) (Tokens, error) {
+	var tokens Tokens
+	_, err := c.do(ctx, http.MethodPost, "/login", map[string]string{"login": login, "password": password}, &tokens)
+	if err == nil {
+		c.Token = tokens.AccessToken
+	}
+	return tokens, err
+}
+
+// ListUsers - поиск пользователей (GET /v2/users)
+func (c *Client) ListUsers(ctx context.Context, f Filter) (Page, error) {
+	var page Page
+	_, err := c.do(ctx, http.MethodGet, "/v2/users"+f.query(), nil, &page)
+	return page, err
+}
+
+// AllUsers - все пользователи, удовлетворяющие f, постранично
+func (c *Client) AllUsers(ctx context.Context, f Filter) ([]User, error) {
+	var all []User
+	for {
+		page, err := c.ListUsers(ctx, f)
+		if err != nil {
+			return all, err
+		}
+		all = append(all, page.Items...)
+		f.Offset += len(page.Items)
+		if len(page.Items) == 0 || f.Offset >= page.Total {
+			return all, nil
+		}
+	}
+}
+
+// CreateUser - новый пользователь (POST /v2/users); запрос не повторяется
+func (c *Client) CreateUser(ctx context.Context, u NewUser) (User, error) {
+	var user User
+	_, err := c.do(ctx, http.MethodPost, "/v2/users", u, &user)
+	return user, err
+}
+
+// GetUser - пользователь по ID (GET /v2/users/{id})
+func (c *Client) GetUser(ctx context.Context, id int) (User, error) {
+	var user User
+	_, err := c.do(ctx, http.MethodGet, userPath(id), nil, &user)
+	return user, err
+}
+
+// SetAge - изменить возраст (PATCH /v2/users/{id}); запрос не повторяется
+func (c *Client) SetAge(ctx context.Context, id, age int) (User, error) {
+	var user User
+	_, err := c.do(ctx, http.MethodPatch, userPath(id), map[string]int{"age": age}, &user)
+	return user, err
+}
+
+// DeleteUser - удалить пользователя вместе с учетной записью (DELETE /v2/users/{id})
+func (c *Client) DeleteUser(ctx context.Context, id int) error {
+	_, err := c.do(ctx, http.MethodDelete, userPath(id), nil, nil)
+	return err
+}
+
+// Friends - друзья пользователя (GET /v2/users/{id}/friends)
+func (c *Client) Friends(ctx context.Context, id int) ([]User, error) {
+	var page Page
+	_, err := c.do(ctx, http.MethodGet, userPath(id)+"/friends", nil, &page)
+	return page.Items, err
+}
+
+// AddFriend - дружба id и friendID (PUT /v2/users/{id}/friends/{friendId});
+// created - false, если они уже были друзьями
+func (c *Client) AddFriend(ctx context.Context, id, friendID int) (created bool, err error) {
+	status, err := c.do(ctx, http.MethodPut, userPath(id)+"/friends/"+strconv.Itoa(friendID), nil, nil)
+	return status == http.StatusCreated, err
+}
+
+// RemoveFriend - прекратить дружбу (DELETE /v2/users/{id}/friends/{friendId})
+func (c *Client) RemoveFriend(ctx context.Context, id, friendID int) error {
+	_, err := c.do(ctx, http.MethodDelete, userPath(id)+"/friends/"+strconv.Itoa(friendID), nil, nil)
+	return err
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"Network-exchange/client"
+	"Network-exchange/core"
+)
+
+// backend - источник данных: работающий сервис (/v2) или файл выгрузки
+type backend interface {
+	list(ctx context.Context, f client.Filter) ([]client.User, int, error) //страница и общее число
+	all(ctx context.Context) ([]client.User, error)
+	get(ctx context.Context, id int) (client.User, error)
+	create(ctx context.Context, u client.NewUser) (client.User, error)
+	remove(ctx context.Context, id int) error
+	befriend(ctx context.Context, id, friendID int) (bool, error)
+	unfriend(ctx context.Context, id, friendID int) error
+	friends(ctx context.Context, id int) ([]client.User, error)
+	save() error //сохранить изменения (для файла)
+}
+
+// remote - сервис по адресу -server
+type remote struct {
+	c *client.Client
+}
+
+func (r remote) list(ctx context.Context, f client.Filter) ([]client.User, int, error) {
+	if f.Limit == 0 { //без -limit - все страницы
+		users, err := r.c.AllUsers(ctx, f)
+		return users, len(users), err
+	}
+	page, err := r.c.ListUsers(ctx, f)
+	return page.Items, page.Total, err
+}
+
+func (r remote) all(ctx context.Context) ([]client.User, error) {
+	return r.c.AllUsers(ctx, client.Filter{Limit: 500})
+}
+
+func (r remote) get(ctx context.Context, id int) (client.User, error) {
+	return r.c.GetUser(ctx, id)
+}
+
+func (r remote) create(ctx context.Context, u client.NewUser) (client.User, error) {
+	return r.c.CreateUser(ctx, u)
+}
+
+func (r remote) remove(ctx context.Context, id int) error {
+	return r.c.DeleteUser(ctx, id)
+}
+
+func (r remote) befriend(ctx context.Context, id, friendID int) (bool, error) {
+	return r.c.AddFriend(ctx, id, friendID)
+}
+
+func (r remote) unfriend(ctx context.Context, id, friendID int) error {
+	return r.c.RemoveFriend(ctx, id, friendID)
+}
+
+func (r remote) friends(ctx context.Context, id int) ([]client.User, error) {
+	return r.c.Friends(ctx, id)
+}
+
+func (remote) save() error { return nil }
+
+// file - файл выгрузки (JSON-массив пользователей) поверх хранилища core
+type file struct {
+	path    string
+	store   *core.Store
+	changed bool
+}
+
+// readUsers читает файл выгрузки; отсутствующий файл - пустой набор
+func readUsers(path string) ([]core.User, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var users []core.User
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// openFile загружает файл в хранилище (файл с нарушениями целостности не открывается)
+func openFile(ctx context.Context, path string, uniqueNames bool) (*file, error) {
+	users, err := readUsers(path)
+	if err != nil {
+		return nil, err
+	}
+	store := core.NewStore(uniqueNames)
+	if err := store.Load(ctx, users); err != nil {
+		return nil, err
+	}
+	return &file{path: path, store: store}, nil
+}
+
+// toClient - пользователь хранилища в виде клиента API (профиль утилите не нужен)
+func toClient(u core.User) client.User {
+	return client.User{ID: u.ID, Name: u.Name, Age: u.Age, Friends: u.Friends}
+}
+
+func fromCore(users []core.User) []client.User {
+	out := make([]client.User, len(users))
+	for i, u := range users {
+		out[i] = toClient(u)
+	}
+	return out
+}
+
+func (f *file) list(ctx context.Context, flt client.Filter) ([]client.User, int, error) {
+	users, total := f.store.List(ctx, core.Filter{Name: flt.Name, MinAge: flt.MinAge, MaxAge: flt.MaxAge, Offset: flt.Offset, Limit: flt.Limit})
+	return fromCore(users), total, nil
+}
+
+func (f *file) all(ctx context.Context) ([]client.User, error) {
+	return fromCore(f.store.All(ctx)), nil
+}
+
+func (f *file) get(ctx context.Context, id int) (client.User, error) {
+	u, err := f.store.Get(ctx, id)
+	return toClient(u), err
+}
+
+func (f *file) create(ctx context.Context, nu client.NewUser) (client.User, error) {
+	u, err := f.store.Create(ctx, nu.Name, nu.Age) //пароль в файле не хранится
+	f.changed = f.changed || err == nil
+	return toClient(u), err
+}
+
+func (f *file) remove(ctx context.Context, id int) error {
+	_, err := f.store.Delete(ctx, id)
+	f.changed = f.changed || err == nil
+	return err
+}
+
+func (f *file) befriend(ctx context.Context, id, friendID int) (bool, error) {
+	err := f.store.Befriend(ctx, id, friendID)
+	if errors.Is(err, core.ErrAlreadyFriends) {
+		return false, nil
+	}
+	f.changed = f.changed || err == nil
+	return err == nil, err
+}
+
+func (f *file) unfriend(ctx context.Context, id, friendID int) error {
+	err := f.store.Unfriend(ctx, id, friendID)
+	f.changed = f.changed || err == nil
+	return err
+}
+
+func (f *file) friends(ctx context.Context, id int) ([]client.User, error) {
+	u, err := f.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]client.User, 0, len(u.Friends))
+	for _, fid := range u.Friends {
+		if friend, err := f.store.Get(ctx, fid); err == nil {
+			out = append(out, toClient(friend))
+		}
+	}
+	return out, nil
+}
+
+// save записывает файл целиком через временный файл (прерванная запись не портит данные)
+func (f *file) save() error {
+	if !f.changed {
+		return nil
+	}
+	data, err := json.MarshalIndent(f.store.All(context.Background()), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), ".netx-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
@@ -0,0 +1,433 @@
+/*
+netx - администрирование пользователей и дружбы из командной строки.
+Работает с запущенным сервисом (-server, через /v2) или напрямую с файлом выгрузки (-file).
+
+	netx -server http://localhost:8080 -login admin -password adminpass1 users list -min-age 18
+	netx -file data.json users create -name Milli -age 33
+	netx -file data.json -o csv export > users.csv
+	netx -server http://localhost:8080 -token $TOKEN import users.csv
+	netx -file data.json check-integrity
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+
+	"Network-exchange/client"
+	"Network-exchange/core"
+)
+
+// command - подкоманда netx
+type command struct {
+	args  string //аргументы для справки
+	about string
+	run   func(ctx context.Context, e *env, args []string) error
+}
+
+// env - общие параметры подкоманд
+type env struct {
+	backend     backend
+	format      string
+	out         io.Writer
+	file        string //путь к файлу выгрузки ("" - работа с сервисом)
+	uniqueNames bool
+}
+
+var commands = map[string]command{
+	"users list":      {"[-name N] [-min-age N] [-max-age N] [-limit N] [-offset N]", "поиск пользователей", usersList},
+	"users get":       {"<id>", "пользователь по ID", usersGet},
+	"users create":    {"-name N [-age N] [-password P]", "новый пользователь", usersCreate},
+	"users delete":    {"<id>", "удалить пользователя", usersDelete},
+	"friends add":     {"<id> <friendId>", "подружить пользователей", friendsAdd},
+	"friends remove":  {"<id> <friendId>", "прекратить дружбу", friendsRemove},
+	"friends list":    {"<id>", "друзья пользователя", friendsList},
+	"import":          {"<file.json|file.csv>", "добавить пользователей и их дружбу из файла (ID назначаются заново)", importUsers},
+	"export":          {"[-out file]", "выгрузить всех пользователей (-o json или csv)", exportUsers},
+	"check-integrity": {"", "проверить взаимность дружбы, ID и имена", checkIntegrity},
+	"stats":           {"", "сводка по пользователям и дружбе", stats},
+}
+
+func main() {
+	flag.Usage = usage
+	server := flag.String("server", os.Getenv("NETX_SERVER"), "адрес сервиса (NETX_SERVER)")
+	path := flag.String("file", os.Getenv("NETX_FILE"), "файл выгрузки вместо сервиса (NETX_FILE)")
+	token := flag.String("token", os.Getenv("NETX_TOKEN"), "токен доступа (NETX_TOKEN)")
+	apiKey := flag.String("api-key", os.Getenv("NETX_API_KEY"), "ключ API (NETX_API_KEY)")
+	login := flag.String("login", os.Getenv("NETX_LOGIN"), "войти перед выполнением команды (NETX_LOGIN)")
+	password := flag.String("password", os.Getenv("NETX_PASSWORD"), "пароль для -login (NETX_PASSWORD)")
+	format := flag.String("o", formatTable, "формат вывода: table, json, csv")
+	unique := flag.Bool("unique-names", false, "имена пользователей в файле не повторяются (как в \"Джин\")")
+	flag.Parse()
+
+	name, cmd, args, ok := lookup(flag.Args())
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+	if *format != formatTable && *format != formatJSON && *format != formatCSV {
+		fail(fmt.Errorf("неизвестный формат %q", *format))
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	e := &env{format: *format, out: os.Stdout, file: *path, uniqueNames: *unique}
+	switch {
+	case *path != "" && *server != "":
+		fail(errors.New("укажите либо -server, либо -file"))
+	case *path != "":
+		if name != "check-integrity" { //файл с нарушениями проверяется без загрузки
+			f, err := openFile(ctx, *path, *unique)
+			if err != nil {
+				fail(err)
+			}
+			e.backend = f
+		}
+	case *server != "":
+		c := client.New(*server)
+		c.Token, c.APIKey = *token, *apiKey
+		if *login != "" {
+			if _, err := c.Login(ctx, *login, *password); err != nil {
+				fail(err)
+			}
+		}
+		e.backend = remote{c}
+	default:
+		fail(errors.New("укажите -server или -file (NETX_SERVER, NETX_FILE)"))
+	}
+
+	if err := cmd.run(ctx, e, args); err != nil {
+		fail(err)
+	}
+	if e.backend != nil {
+		if err := e.backend.save(); err != nil {
+			fail(err)
+		}
+	}
+}
+
+// lookup находит подкоманду из одного ("stats") или двух ("users list") слов
+func lookup(args []string) (string, command, []string, bool) {
+	if len(args) >= 2 {
+		if cmd, ok := commands[args[0]+" "+args[1]]; ok {
+			return args[0] + " " + args[1], cmd, args[2:], true
+		}
+	}
+	if len(args) >= 1 {
+		if cmd, ok := commands[args[0]]; ok {
+			return args[0], cmd, args[1:], true
+		}
+	}
+	return "", command{}, nil, false
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintln(out, "Использование: netx [флаги] <команда> [аргументы]\n\nКоманды:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-16s %-58s %s\n", name, commands[name].args, commands[name].about)
+	}
+	fmt.Fprintln(out, "\nФлаги:")
+	flag.PrintDefaults()
+}
+
+// fail печатает ошибку и завершает работу с кодом 1
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "netx:", err)
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) {
+		for _, f := range apiErr.Fields {
+			fmt.Fprintf(os.Stderr, "  %s: %s\n", f.Field, f.Message)
+		}
+	}
+	os.Exit(1)
+}
+
+// ids разбирает n числовых аргументов
+func ids(args []string, n int) ([]int, error) {
+	if len(args) != n {
+		return nil, fmt.Errorf("ожидается аргументов: %d", n)
+	}
+	out := make([]int, n)
+	for i, a := range args {
+		id, err := strconv.Atoi(a)
+		if err != nil {
+			return nil, fmt.Errorf("ID %q - не число", a)
+		}
+		out[i] = id
+	}
+	return out, nil
+}
+
+// message печатает итог изменения (в табличном формате)
+func (e *env) message(format string, a ...interface{}) {
+	if e.format == formatTable {
+		fmt.Fprintf(e.out, format+"\n", a...)
+	}
+}
+
+func usersList(ctx context.Context, e *env, args []string) error {
+	var f client.Filter
+	fs := flag.NewFlagSet("users list", flag.ContinueOnError)
+	fs.StringVar(&f.Name, "name", "", "часть имени")
+	fs.IntVar(&f.MinAge, "min-age", 0, "возраст от")
+	fs.IntVar(&f.MaxAge, "max-age", 0, "возраст до")
+	fs.IntVar(&f.Limit, "limit", 0, "размер страницы (0 - все)")
+	fs.IntVar(&f.Offset, "offset", 0, "пропустить")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if f.Offset < 0 {
+		return errors.New("-offset не может быть отрицательным")
+	}
+	users, total, err := e.backend.list(ctx, f)
+	if err != nil {
+		return err
+	}
+	if err := writeUsers(e.out, e.format, users); err != nil {
+		return err
+	}
+	if total > len(users) {
+		e.message("показано %d из %d", len(users), total)
+	}
+	return nil
+}
+
+func usersGet(ctx context.Context, e *env, args []string) error {
+	id, err := ids(args, 1)
+	if err != nil {
+		return err
+	}
+	u, err := e.backend.get(ctx, id[0])
+	if err != nil {
+		return err
+	}
+	return writeUsers(e.out, e.format, []client.User{u})
+}
+
+func usersCreate(ctx context.Context, e *env, args []string) error {
+	var nu client.NewUser
+	fs := flag.NewFlagSet("users create", flag.ContinueOnError)
+	fs.StringVar(&nu.Name, "name", "", "имя")
+	fs.IntVar(&nu.Age, "age", 0, "возраст")
+	fs.StringVar(&nu.Password, "password", "", "пароль учетной записи (только для сервиса)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if nu.Name == "" {
+		return errors.New("укажите -name")
+	}
+	u, err := e.backend.create(ctx, nu)
+	if err != nil {
+		return err
+	}
+	return writeUsers(e.out, e.format, []client.User{u})
+}
+
+func usersDelete(ctx context.Context, e *env, args []string) error {
+	id, err := ids(args, 1)
+	if err != nil {
+		return err
+	}
+	if err := e.backend.remove(ctx, id[0]); err != nil {
+		return err
+	}
+	e.message("пользователь %d удален", id[0])
+	return nil
+}
+
+func friendsAdd(ctx context.Context, e *env, args []string) error {
+	id, err := ids(args, 2)
+	if err != nil {
+		return err
+	}
+	created, err := e.backend.befriend(ctx, id[0], id[1])
+	if err != nil {
+		return err
+	}
+	if created {
+		e.message("%d и %d теперь друзья", id[0], id[1])
+	} else {
+		e.message("%d и %d уже были друзьями", id[0], id[1])
+	}
+	return nil
+}
+
+func friendsRemove(ctx context.Context, e *env, args []string) error {
+	id, err := ids(args, 2)
+	if err != nil {
+		return err
+	}
+	if err := e.backend.unfriend(ctx, id[0], id[1]); err != nil {
+		return err
+	}
+	e.message("%d и %d больше не друзья", id[0], id[1])
+	return nil
+}
+
+func friendsList(ctx context.Context, e *env, args []string) error {
+	id, err := ids(args, 1)
+	if err != nil {
+		return err
+	}
+	friends, err := e.backend.friends(ctx, id[0])
+	if err != nil {
+		return err
+	}
+	return writeUsers(e.out, e.format, friends)
+}
+
+// importUsers создает пользователей из файла и восстанавливает дружбу между ними;
+// файл с нарушениями целостности не импортируется
+func importUsers(ctx context.Context, e *env, args []string) error {
+	if len(args) != 1 {
+		return errors.New("укажите файл для импорта")
+	}
+	users, err := readImport(args[0])
+	if err != nil {
+		return err
+	}
+	if problems := core.Check(users, e.uniqueNames); len(problems) > 0 {
+		return fmt.Errorf("файл не импортирован: %s", strings.Join(problems, "; "))
+	}
+	newID := make(map[int]int, len(users))
+	for _, u := range users {
+		created, err := e.backend.create(ctx, client.NewUser{Name: u.Name, Age: u.Age})
+		if err != nil {
+			return fmt.Errorf("пользователь %d (%s): %w", u.ID, u.Name, err)
+		}
+		newID[u.ID] = created.ID
+	}
+	friendships := 0
+	for _, u := range users {
+		for _, f := range u.Friends {
+			if u.ID > f { //каждую дружбу - один раз
+				continue
+			}
+			if _, err := e.backend.befriend(ctx, newID[u.ID], newID[f]); err != nil {
+				return fmt.Errorf("дружба %d и %d: %w", u.ID, f, err)
+			}
+			friendships++
+		}
+	}
+	e.message("импортировано пользователей: %d, дружеских связей: %d", len(users), friendships)
+	return nil
+}
+
+func exportUsers(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	out := fs.String("out", "", "файл (по умолчанию - стандартный вывод)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	users, err := e.backend.all(ctx)
+	if err != nil {
+		return err
+	}
+	format := e.format
+	if format == formatTable { //выгрузка - в машиночитаемом формате
+		format = formatJSON
+	}
+	if *out == "" {
+		return writeUsers(e.out, format, users)
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := writeUsers(f, format, users); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func checkIntegrity(ctx context.Context, e *env, _ []string) error {
+	var users []core.User
+	if e.file != "" {
+		var err error
+		if users, err = readUsers(e.file); err != nil {
+			return err
+		}
+	} else {
+		all, err := e.backend.all(ctx)
+		if err != nil {
+			return err
+		}
+		for _, u := range all {
+			users = append(users, core.User{ID: u.ID, Name: u.Name, Age: u.Age, Friends: u.Friends})
+		}
+	}
+	problems := core.Check(users, e.uniqueNames)
+	for _, p := range problems {
+		fmt.Fprintln(e.out, p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("нарушений целостности: %d", len(problems))
+	}
+	e.message("нарушений нет (пользователей: %d)", len(users))
+	return nil
+}
+
+// Summary - сводка команды stats
+type Summary struct {
+	Users          int          `json:"users"`
+	Friendships    int          `json:"friendships"`
+	WithoutFriends int          `json:"without_friends"`
+	AverageAge     float64      `json:"average_age"`
+	MinAge         int          `json:"min_age"`
+	MaxAge         int          `json:"max_age"`
+	MostFriends    *client.User `json:"most_friends,omitempty"`
+}
+
+func stats(ctx context.Context, e *env, _ []string) error {
+	users, err := e.backend.all(ctx)
+	if err != nil {
+		return err
+	}
+	var s Summary
+	links, ages := 0, 0
+	for i, u := range users {
+		links += len(u.Friends)
+		ages += u.Age
+		if len(u.Friends) == 0 {
+			s.WithoutFriends++
+		}
+		if i == 0 || u.Age < s.MinAge {
+			s.MinAge = u.Age
+		}
+		s.MaxAge = max(s.MaxAge, u.Age)
+		if len(u.Friends) > 0 && (s.MostFriends == nil || len(u.Friends) > len(s.MostFriends.Friends)) {
+			s.MostFriends = &users[i]
+		}
+	}
+	s.Users, s.Friendships = len(users), links/2
+	if len(users) > 0 {
+		s.AverageAge = float64(ages) / float64(len(users))
+	}
+	rows := [][2]string{
+		{"пользователей", strconv.Itoa(s.Users)},
+		{"дружеских связей", strconv.Itoa(s.Friendships)},
+		{"без друзей", strconv.Itoa(s.WithoutFriends)},
+		{"средний возраст", strconv.FormatFloat(s.AverageAge, 'f', 1, 64)},
+		{"возраст от", strconv.Itoa(s.MinAge)},
+		{"возраст до", strconv.Itoa(s.MaxAge)},
+	}
+	if s.MostFriends != nil {
+		rows = append(rows, [2]string{"больше всех друзей", fmt.Sprintf("%s (ID %d): %d", s.MostFriends.Name, s.MostFriends.ID, len(s.MostFriends.Friends))})
+	}
+	return writeValue(e.out, e.format, s, rows)
+}
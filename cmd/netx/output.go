@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"Network-exchange/client"
+	"Network-exchange/core"
+)
+
+// Форматы вывода (-o)
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+)
+
+var csvHeader = []string{"id", "name", "age", "friends"}
+
+func joinIDs(ids []int, sep string) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, sep)
+}
+
+// writeUsers выводит пользователей в выбранном формате
+func writeUsers(w io.Writer, format string, users []client.User) error {
+	switch format {
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if users == nil {
+			users = []client.User{}
+		}
+		return enc.Encode(users)
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		cw.Write(csvHeader)
+		for _, u := range users {
+			cw.Write([]string{strconv.Itoa(u.ID), u.Name, strconv.Itoa(u.Age), joinIDs(u.Friends, ";")})
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tИМЯ\tВОЗРАСТ\tДРУЗЬЯ")
+		for _, u := range users {
+			fmt.Fprintf(tw, "%d\t%s\t%d\t%s\n", u.ID, u.Name, u.Age, joinIDs(u.Friends, ","))
+		}
+		return tw.Flush()
+	}
+}
+
+// writeValue выводит сводку (stats) в выбранном формате; rows - пары "название, значение"
+func writeValue(w io.Writer, format string, v interface{}, rows [][2]string) error {
+	switch format {
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"metric", "value"})
+		for _, r := range rows {
+			cw.Write(r[:])
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, r := range rows {
+			fmt.Fprintf(tw, "%s\t%s\n", r[0], r[1])
+		}
+		return tw.Flush()
+	}
+}
+
+// readImport читает пользователей для импорта: JSON-массив (как у export -o json)
+// или CSV с заголовком id,name,age,friends (друзья через ";")
+func readImport(path string) ([]core.User, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if !strings.EqualFold(filepath.Ext(path), ".csv") {
+		var users []core.User
+		if err := json.NewDecoder(f).Decode(&users); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return users, nil
+	}
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var users []core.User
+	for i, rec := range records {
+		if i == 0 && len(rec) > 0 && rec[0] == csvHeader[0] {
+			continue //заголовок
+		}
+		if len(rec) != len(csvHeader) {
+			return nil, fmt.Errorf("%s:%d: ожидается %d столбца", path, i+1, len(csvHeader))
+		}
+		u := core.User{Name: rec[1], Friends: []int{}}
+		if u.ID, err = strconv.Atoi(rec[0]); err != nil {
+			return nil, fmt.Errorf("%s:%d: id: %w", path, i+1, err)
+		}
+		if u.Age, err = strconv.Atoi(rec[2]); err != nil {
+			return nil, fmt.Errorf("%s:%d: age: %w", path, i+1, err)
+		}
+		for _, s := range strings.Split(rec[3], ";") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			id, err := strconv.Atoi(s)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: friends: %w", path, i+1, err)
+			}
+			u.Friends = append(u.Friends, id)
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
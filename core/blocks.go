@@ -0,0 +1,145 @@
+package core
+
+import (
+	"context"
+	"slices"
+	"sort"
+
+	"Network-exchange/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// SetViewer задает, как узнать владельца запроса среди пользователей: isViewer(ctx, u) - true,
+// если запрос ctx сделан от имени u. Без него блокировки не скрывают пользователей при чтении.
+// Вызывается до начала обслуживания запросов; isViewer не должен обращаться к хранилищу.
+func (s *Store) SetViewer(isViewer func(ctx context.Context, u User) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.isViewer = isViewer
+}
+
+// hiddenFrom - пользователи, заблокировавшие владельца запроса ctx (nil - таких нет).
+// Вызывается под s.mu.
+func (s *Store) hiddenFrom(ctx context.Context) map[int]bool {
+	if s.isViewer == nil {
+		return nil
+	}
+	var hidden map[int]bool
+	for id, blocked := range s.state.blocks {
+		for b := range blocked {
+			if u, ok := s.state.users[b]; ok && s.isViewer(ctx, *u) {
+				if hidden == nil {
+					hidden = make(map[int]bool)
+				}
+				hidden[id] = true
+			}
+		}
+	}
+	return hidden
+}
+
+// HiddenFrom - пользователи, заблокировавшие владельца запроса ctx (nil - таких нет):
+// для тех, кто получает пользователей не чтением из хранилища (лента изменений)
+func (s *Store) HiddenFrom(ctx context.Context) map[int]bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.hiddenFrom(ctx)
+}
+
+// Blocks - заблокировал ли blockerID пользователя blockedID
+func (s *Store) Blocks(blockerID, blockedID int) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state.blocks[blockerID][blockedID]
+}
+
+// Strip - u без скрытых друзей (hidden - из HiddenFrom); сам u не меняется
+func Strip(u User, hidden map[int]bool) User {
+	if len(hidden) == 0 {
+		return u
+	}
+	u.Friends = slices.Clone(u.Friends)
+	return strip(u, hidden)
+}
+
+// strip убирает из друзей u скрытых пользователей
+func strip(u User, hidden map[int]bool) User {
+	if len(hidden) == 0 {
+		return u
+	}
+	friends := u.Friends[:0]
+	for _, f := range u.Friends {
+		if !hidden[f] {
+			friends = append(friends, f)
+		}
+	}
+	u.Friends = friends
+	return u
+}
+
+// Block - blockerID блокирует blockedID: их дружба и подписки прекращаются, заблокированный
+// больше не может предложить дружбу или подписаться и не видит заблокировавшего в поиске,
+// списках друзей и подписчиков
+func (s *Store) Block(ctx context.Context, blockerID, blockedID int) error {
+	_, span := tracing.Store(ctx, "block", attribute.Int("source.id", blockerID), attribute.Int("target.id", blockedID))
+	defer span.End()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	blocker, err := s.pair(blockerID, blockedID)
+	switch {
+	case err == ErrSelfFriend:
+		err = ErrSelfBlock
+	case err != nil:
+	case s.state.blocks[blockerID][blockedID]:
+		err = ErrAlreadyBlocked
+	}
+	if err != nil {
+		tracing.Fail(span, err)
+		return err
+	}
+	if blocker.HasFriend(blockedID) {
+		s.record(ctx, Event{Type: FriendshipEnded, User: User{ID: blockerID}, FriendID: blockedID})
+	}
+	s.unfollow(ctx, blockerID, blockedID) //подписок может и не быть
+	s.unfollow(ctx, blockedID, blockerID)
+	s.record(ctx, Event{Type: UserBlocked, User: User{ID: blockerID}, FriendID: blockedID})
+	return nil
+}
+
+// Unblock снимает блокировку; прежняя дружба не возвращается
+func (s *Store) Unblock(ctx context.Context, blockerID, blockedID int) error {
+	_, span := tracing.Store(ctx, "unblock", attribute.Int("source.id", blockerID), attribute.Int("target.id", blockedID))
+	defer span.End()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.state.users[blockerID]; !ok {
+		tracing.Fail(span, ErrNotFound)
+		return ErrNotFound
+	}
+	if !s.state.blocks[blockerID][blockedID] {
+		tracing.Fail(span, ErrNotBlocked)
+		return ErrNotBlocked
+	}
+	s.record(ctx, Event{Type: UserUnblocked, User: User{ID: blockerID}, FriendID: blockedID})
+	return nil
+}
+
+// Blocked - заблокированные пользователем id по возрастанию ID (удаленные не показываются)
+func (s *Store) Blocked(ctx context.Context, id int) ([]User, error) {
+	_, span := tracing.Store(ctx, "list_blocked", attribute.Int("user.id", id))
+	defer span.End()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.state.users[id]; !ok {
+		return nil, ErrNotFound
+	}
+	out := []User{}
+	for b := range s.state.blocks[id] {
+		if u, ok := s.state.users[b]; ok {
+			out = append(out, u.clone())
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
@@ -0,0 +1,106 @@
+// Package core - общая модель пользователей и дружбы: хранилище, к которому
+// обращаются обработчики всех версий API обоих сервисов
+package core
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Ошибки операций с пользователями
+var (
+	ErrNotFound       = errors.New("пользователь не найден")
+	ErrNameTaken      = errors.New("пользователь с таким именем уже есть")
+	ErrEmailTaken     = errors.New("пользователь с таким email уже есть")
+	ErrInvalidProfile = errors.New("некорректный профиль")
+	ErrAgeDerived     = errors.New("возраст вычисляется по дате рождения")
+	ErrInvalid        = errors.New("некорректные данные пользователя")
+	ErrAlreadyFriends = errors.New("пользователи уже друзья")
+	ErrNotFriends     = errors.New("пользователи не друзья")
+	ErrSelfFriend     = errors.New("нельзя дружить с самим собой")
+	ErrBlocked        = errors.New("пользователь заблокирован")
+	ErrAlreadyBlocked = errors.New("пользователь уже заблокирован")
+	ErrNotBlocked     = errors.New("пользователь не заблокирован")
+	ErrSelfBlock      = errors.New("нельзя заблокировать самого себя")
+	ErrSelfFollow     = errors.New("нельзя подписаться на самого себя")
+	ErrAlreadyFollows = errors.New("подписка уже есть")
+	ErrNotFollowing   = errors.New("подписки нет")
+	ErrNoRequest      = errors.New("заявки на подписку нет")
+	//ErrInvalidFriendship - метки или близость дружбы вне ограничений
+	ErrInvalidFriendship = errors.New("метки дружбы - не больше 10, каждая от 1 до 32 символов; близость - от 0 до 10")
+)
+
+// Removal - пользователь, помеченный удаленным; его можно восстановить до окончательного удаления
+type Removal struct {
+	User      User         `json:"user"` //Friends - друзья на момент удаления
+	DeletedAt time.Time    `json:"deleted_at"`
+	ties      []Friendship //дружба на момент удаления: его записи и записи друзей о нем
+}
+
+// User - пользователь; Friends - ID друзей в порядке появления дружбы
+type User struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Age     int    `json:"age"` //при заданной дате рождения - по ней, на момент чтения
+	Friends []int  `json:"friends"`
+	Profile
+	CreatedAt time.Time `json:"created_at,omitzero"` //нулевые - пользователь из снимка
+	UpdatedAt time.Time `json:"updated_at,omitzero"` //изменение возраста или профиля
+}
+
+// age - возраст на момент t: по дате рождения, если она задана
+func (u *User) age(t time.Time) int {
+	if u.BirthDate != "" {
+		return ageOn(u.BirthDate, t)
+	}
+	return u.Age
+}
+
+// HasFriend сообщает, дружит ли пользователь с id
+func (u User) HasFriend(id int) bool {
+	for _, f := range u.Friends {
+		if f == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Public - пользователь для посторонних: без email и даты рождения (возраст остается)
+func (u User) Public() User {
+	u.Email, u.BirthDate = "", ""
+	return u
+}
+
+// Filter - условия поиска пользователей; нулевые поля не ограничивают выборку
+type Filter struct {
+	Name   string //часть имени без учета регистра
+	MinAge int
+	MaxAge int
+	Offset int
+	Limit  int //0 - все
+}
+
+func (f Filter) match(u *User) bool {
+	if f.Name != "" && !strings.Contains(strings.ToLower(u.Name), strings.ToLower(f.Name)) {
+		return false
+	}
+	age := u.age(time.Now())
+	if f.MinAge > 0 && age < f.MinAge {
+		return false
+	}
+	if f.MaxAge > 0 && age > f.MaxAge {
+		return false
+	}
+	return true
+}
+
+// checkUser - общие правила для данных пользователя. Двоеточия в имени нет: имя бывает
+// владельцем запроса ("Джин"), и пользователь не должен совпасть с ключом API ("apikey:<ID>").
+func checkUser(name string, age int) error {
+	if strings.TrimSpace(name) == "" || strings.Contains(name, ":") || age < 0 {
+		return ErrInvalid
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType - вид изменения в хранилище
+type EventType string
+
+// Виды изменений (значения - прежние названия, их видят клиенты WatchUsers)
+const (
+	UserRegistered    EventType = "user.created"
+	AgeChanged        EventType = "user.updated"
+	ProfileUpdated    EventType = "user.profile_updated" //User.Profile - профиль после изменения
+	UserDeleted       EventType = "user.deleted"         //помечен удаленным: скрыт, дружба прекращена
+	UserRestored      EventType = "user.restored"        //восстановлен без друзей (дружба - следом, FriendshipFormed и FriendshipUpdated)
+	UserPurged        EventType = "user.purged"          //удален окончательно
+	FriendshipFormed  EventType = "friendship.created"
+	FriendshipEnded   EventType = "friendship.deleted"
+	FriendshipUpdated EventType = "friendship.updated" //User изменил свои метки и близость друга FriendID
+	UserBlocked       EventType = "user.blocked"       //User заблокировал FriendID (дружба - прекращена до этого)
+	UserUnblocked     EventType = "user.unblocked"     //User снял блокировку с FriendID
+
+	//подписки: User - подписчик, FriendID - на кого он подписан
+	FollowRequested   EventType = "follow.requested"         //заявка ждет одобрения FriendID
+	FollowDeclined    EventType = "follow.declined"          //заявка отозвана или отклонена
+	Followed          EventType = "follow.created"           //подписка действует
+	Unfollowed        EventType = "follow.deleted"           //подписка прекращена
+	FollowApprovalOn  EventType = "user.follow_approval_on"  //User одобряет подписчиков сам
+	FollowApprovalOff EventType = "user.follow_approval_off" //подписка без одобрения
+)
+
+// Private - изменение видно только самим участникам и служебным маршрутам (журнал, аудит),
+// а не в общих лентах и истории: заблокированный не должен узнавать о блокировке,
+// отклоненный - о судьбе заявки, а друг - о метках, которыми его отметили
+func (t EventType) Private() bool {
+	switch t {
+	case FriendshipUpdated, UserBlocked, UserUnblocked, FollowRequested, FollowDeclined, FollowApprovalOn, FollowApprovalOff:
+		return true
+	}
+	return false
+}
+
+// Event - изменение в хранилище, запись журнала; после записи не меняется, кроме стирания
+// данных окончательно удаленного (Purge). User - пользователь после изменения (для UserDeleted
+// и UserPurged - только ID), для дружбы - инициатор; FriendID - второй участник дружбы.
+// Подписчики получают те же значения, что в журнале, и не должны их менять.
+type Event struct {
+	Seq      uint64    `json:"seq"` //порядковый номер изменения (возрастает без пропусков)
+	Type     EventType `json:"type"`
+	Time     time.Time `json:"time"`
+	User     User      `json:"user"`
+	FriendID int       `json:"friend_id,omitempty"`
+	//Friendship - для FriendshipUpdated: метки и близость после изменения; для FriendshipFormed
+	//при восстановлении удаленного (Restore) - прежние время и инициатор дружбы
+	Friendship *Friendship `json:"friendship,omitempty"`
+}
+
+// DefaultBuffer - очередь подписчика по умолчанию
+const DefaultBuffer = 64
+
+// events - подписчики на изменения хранилища
+type events struct {
+	mu   sync.Mutex
+	seq  uint64
+	subs map[chan Event]struct{}
+}
+
+// Subscribe подписывает на изменения хранилища; buffer - длина очереди подписчика
+// (0 - DefaultBuffer). Канал закрывается вызовом cancel, а также если подписчик не успевает
+// забирать изменения и очередь переполнилась - тогда нужно подписаться заново.
+func (s *Store) Subscribe(buffer int) (<-chan Event, func()) {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	ch := make(chan Event, buffer)
+	s.ev.mu.Lock()
+	if s.ev.subs == nil {
+		s.ev.subs = make(map[chan Event]struct{})
+	}
+	s.ev.subs[ch] = struct{}{}
+	s.ev.mu.Unlock()
+	cancel := func() {
+		s.ev.mu.Lock()
+		defer s.ev.mu.Unlock()
+		if _, ok := s.ev.subs[ch]; ok {
+			delete(s.ev.subs, ch)
+			close(ch)
+		}
+	}
+	return ch, cancel
+}
+
+// LastSeq - номер последнего изменения
+func (s *Store) LastSeq() uint64 {
+	s.ev.mu.Lock()
+	defer s.ev.mu.Unlock()
+	return s.ev.seq
+}
+
+// publish рассылает изменение подписчикам; вызывается под s.mu, поэтому порядок
+// изменений у всех подписчиков совпадает с порядком в журнале
+func (s *Store) publish(e Event) {
+	s.ev.mu.Lock()
+	defer s.ev.mu.Unlock()
+	s.ev.seq = e.Seq
+	for ch := range s.ev.subs {
+		select {
+		case ch <- e:
+		default: //подписчик отстал - отключаем
+			delete(s.ev.subs, ch)
+			close(ch)
+		}
+	}
+}
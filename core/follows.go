@@ -0,0 +1,233 @@
+package core
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	"Network-exchange/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// edges - направленные связи между пользователями с обратным указателем
+type edges struct {
+	out map[int]map[int]bool //от кого -> к кому
+	in  map[int]map[int]bool //к кому -> от кого
+}
+
+func newEdges() edges {
+	return edges{out: make(map[int]map[int]bool), in: make(map[int]map[int]bool)}
+}
+
+func (g edges) clone() edges {
+	out := newEdges()
+	for a, to := range g.out {
+		out.out[a] = maps.Clone(to)
+	}
+	for b, from := range g.in {
+		out.in[b] = maps.Clone(from)
+	}
+	return out
+}
+
+func (g edges) has(a, b int) bool {
+	return g.out[a][b]
+}
+
+func (g edges) add(a, b int) {
+	if g.out[a] == nil {
+		g.out[a] = make(map[int]bool)
+	}
+	if g.in[b] == nil {
+		g.in[b] = make(map[int]bool)
+	}
+	g.out[a][b], g.in[b][a] = true, true
+}
+
+func (g edges) del(a, b int) {
+	delete(g.out[a], b)
+	delete(g.in[b], a)
+}
+
+// drop убирает все связи пользователя id
+func (g edges) drop(id int) {
+	for b := range g.out[id] {
+		delete(g.in[b], id)
+	}
+	for a := range g.in[id] {
+		delete(g.out[a], id)
+	}
+	delete(g.out, id)
+	delete(g.in, id)
+}
+
+// FollowStats - подписки пользователя
+type FollowStats struct {
+	Followers        int  `json:"followers"`
+	Following        int  `json:"following"`
+	RequiresApproval bool `json:"requires_approval"` //подписчиков одобряет сам пользователь
+}
+
+// Follow подписывает followerID на followeeID. Если followeeID одобряет подписчиков сам,
+// создается заявка (pending = true; повторная заявка - не ошибка). Ошибки: ErrNotFound,
+// ErrSelfFollow, ErrBlocked, ErrAlreadyFollows.
+func (s *Store) Follow(ctx context.Context, followerID, followeeID int) (pending bool, err error) {
+	_, span := tracing.Store(ctx, "follow", attribute.Int("source.id", followerID), attribute.Int("target.id", followeeID))
+	defer span.End()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.pair(followerID, followeeID)
+	switch {
+	case err == ErrSelfFriend:
+		err = ErrSelfFollow
+	case err != nil:
+	case s.state.blocked(followerID, followeeID):
+		err = ErrBlocked
+	case s.state.follows.has(followerID, followeeID):
+		err = ErrAlreadyFollows
+	}
+	if err != nil {
+		tracing.Fail(span, err)
+		return false, err
+	}
+	switch {
+	case s.state.pending.has(followerID, followeeID):
+		return true, nil
+	case s.state.approval[followeeID]:
+		s.record(ctx, Event{Type: FollowRequested, User: User{ID: followerID}, FriendID: followeeID})
+		return true, nil
+	}
+	s.record(ctx, Event{Type: Followed, User: User{ID: followerID}, FriendID: followeeID})
+	return false, nil
+}
+
+// Unfollow прекращает подписку followerID на followeeID или отзывает заявку
+func (s *Store) Unfollow(ctx context.Context, followerID, followeeID int) error {
+	_, span := tracing.Store(ctx, "unfollow", attribute.Int("source.id", followerID), attribute.Int("target.id", followeeID))
+	defer span.End()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.unfollow(ctx, followerID, followeeID)
+	if err != nil {
+		tracing.Fail(span, err)
+	}
+	return err
+}
+
+// unfollow - подписка или заявка прекращается; вызывается под s.mu
+func (s *Store) unfollow(ctx context.Context, followerID, followeeID int) error {
+	switch {
+	case s.state.follows.has(followerID, followeeID):
+		s.record(ctx, Event{Type: Unfollowed, User: User{ID: followerID}, FriendID: followeeID})
+	case s.state.pending.has(followerID, followeeID):
+		s.record(ctx, Event{Type: FollowDeclined, User: User{ID: followerID}, FriendID: followeeID})
+	case s.state.users[followerID] == nil || s.state.users[followeeID] == nil:
+		return ErrNotFound
+	default:
+		return ErrNotFollowing
+	}
+	return nil
+}
+
+// Approve одобряет заявку followerID на подписку на followeeID
+func (s *Store) Approve(ctx context.Context, followeeID, followerID int) error {
+	_, span := tracing.Store(ctx, "approve_follow", attribute.Int("source.id", followerID), attribute.Int("target.id", followeeID))
+	defer span.End()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.state.pending.has(followerID, followeeID) {
+		tracing.Fail(span, ErrNoRequest)
+		return ErrNoRequest
+	}
+	s.record(ctx, Event{Type: Followed, User: User{ID: followerID}, FriendID: followeeID})
+	return nil
+}
+
+// Decline отклоняет заявку followerID на подписку на followeeID
+func (s *Store) Decline(ctx context.Context, followeeID, followerID int) error {
+	_, span := tracing.Store(ctx, "decline_follow", attribute.Int("source.id", followerID), attribute.Int("target.id", followeeID))
+	defer span.End()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.state.pending.has(followerID, followeeID) {
+		tracing.Fail(span, ErrNoRequest)
+		return ErrNoRequest
+	}
+	s.record(ctx, Event{Type: FollowDeclined, User: User{ID: followerID}, FriendID: followeeID})
+	return nil
+}
+
+// SetFollowApproval включает или выключает одобрение подписчиков; при выключении
+// ждущие заявки одобряются
+func (s *Store) SetFollowApproval(ctx context.Context, id int, on bool) (FollowStats, error) {
+	_, span := tracing.Store(ctx, "follow_approval", attribute.Int("user.id", id), attribute.Bool("approval", on))
+	defer span.End()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.state.users[id]; !ok {
+		tracing.Fail(span, ErrNotFound)
+		return FollowStats{}, ErrNotFound
+	}
+	switch {
+	case on && !s.state.approval[id]:
+		s.record(ctx, Event{Type: FollowApprovalOn, User: User{ID: id}})
+	case !on && s.state.approval[id]:
+		s.record(ctx, Event{Type: FollowApprovalOff, User: User{ID: id}})
+		for _, f := range slices.Sorted(maps.Keys(s.state.pending.in[id])) {
+			s.record(ctx, Event{Type: Followed, User: User{ID: f}, FriendID: id})
+		}
+	}
+	return s.followStats(id), nil
+}
+
+// FollowStats - число подписчиков и подписок пользователя id и нужно ли одобрение
+func (s *Store) FollowStats(ctx context.Context, id int) (FollowStats, error) {
+	_, span := tracing.Store(ctx, "follow_stats", attribute.Int("user.id", id))
+	defer span.End()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.state.users[id]; !ok {
+		return FollowStats{}, ErrNotFound
+	}
+	return s.followStats(id), nil
+}
+
+func (s *Store) followStats(id int) FollowStats {
+	return FollowStats{Followers: len(s.state.follows.in[id]), Following: len(s.state.follows.out[id]),
+		RequiresApproval: s.state.approval[id]}
+}
+
+// Followers - подписчики пользователя id по возрастанию ID (без заблокировавших владельца запроса)
+func (s *Store) Followers(ctx context.Context, id int) ([]User, error) {
+	return s.related(ctx, "followers", id, func(p *projection) map[int]bool { return p.follows.in[id] })
+}
+
+// Following - на кого подписан пользователь id
+func (s *Store) Following(ctx context.Context, id int) ([]User, error) {
+	return s.related(ctx, "following", id, func(p *projection) map[int]bool { return p.follows.out[id] })
+}
+
+// FollowRequests - ждущие одобрения пользователя id заявки на подписку
+func (s *Store) FollowRequests(ctx context.Context, id int) ([]User, error) {
+	return s.related(ctx, "follow_requests", id, func(p *projection) map[int]bool { return p.pending.in[id] })
+}
+
+// related - пользователи из множества ids(state) по возрастанию ID
+func (s *Store) related(ctx context.Context, name string, id int, ids func(p *projection) map[int]bool) ([]User, error) {
+	_, span := tracing.Store(ctx, name, attribute.Int("user.id", id))
+	defer span.End()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.state.users[id]; !ok {
+		return nil, ErrNotFound
+	}
+	hidden := s.hiddenFrom(ctx)
+	out := []User{}
+	for _, r := range slices.Sorted(maps.Keys(ids(&s.state))) {
+		if u, ok := s.state.users[r]; ok && !hidden[r] {
+			out = append(out, strip(u.clone(), hidden))
+		}
+	}
+	return out, nil
+}
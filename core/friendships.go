@@ -0,0 +1,190 @@
+package core
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"Network-exchange/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Ограничения меток и близости дружбы
+const (
+	MaxLabels      = 10
+	MaxLabelLength = 32
+	MaxCloseness   = 10
+)
+
+// Friendship - дружба глазами пользователя UserID: когда возникла и кто ее предложил
+// (общее для обоих), а метки и близость - его собственные оценки друга FriendID
+type Friendship struct {
+	UserID      int       `json:"user_id"`
+	FriendID    int       `json:"friend_id"`
+	InitiatorID int       `json:"initiator_id,omitempty"` //0 - неизвестен (дружба из снимка)
+	Since       time.Time `json:"since,omitzero"`         //нулевое - дружба из снимка
+	Labels      []string  `json:"labels"`                 //например family, colleague
+	Closeness   int       `json:"closeness"`              //от 1 до MaxCloseness; 0 - не задана
+}
+
+func (f Friendship) clone() Friendship {
+	f.Labels = slices.Clone(f.Labels)
+	return f
+}
+
+// FriendshipPatch - изменение меток и близости; nil - поле не меняется
+// (пустой, но не nil список Labels снимает все метки)
+type FriendshipPatch struct {
+	Labels    []string
+	Closeness *int
+}
+
+// FriendFilter - условия отбора дружб пользователя; нулевые поля не ограничивают выборку
+type FriendFilter struct {
+	Label        string //есть такая метка (без учета регистра)
+	MinCloseness int
+	MaxCloseness int
+}
+
+func (f FriendFilter) match(t *Friendship) bool {
+	if f.Label != "" && !slices.Contains(t.Labels, strings.ToLower(strings.TrimSpace(f.Label))) {
+		return false
+	}
+	if f.MinCloseness > 0 && t.Closeness < f.MinCloseness {
+		return false
+	}
+	if f.MaxCloseness > 0 && t.Closeness > f.MaxCloseness {
+		return false
+	}
+	return true
+}
+
+// labels приводит метки к нижнему регистру без повторов; ErrInvalidFriendship - пустая
+// или слишком длинная метка, слишком много меток
+func labels(in []string) ([]string, error) {
+	out := []string{}
+	for _, l := range in {
+		l = strings.ToLower(strings.TrimSpace(l))
+		if l == "" || utf8.RuneCountInString(l) > MaxLabelLength {
+			return nil, ErrInvalidFriendship
+		}
+		if !slices.Contains(out, l) {
+			out = append(out, l)
+		}
+	}
+	if len(out) > MaxLabels {
+		return nil, ErrInvalidFriendship
+	}
+	return out, nil
+}
+
+// tie - запись дружбы a с b; для дружбы из снимка - без времени и инициатора
+func (p *projection) tie(a, b int) Friendship {
+	if t, ok := p.ties[a][b]; ok {
+		return t
+	}
+	return Friendship{UserID: a, FriendID: b, Labels: []string{}}
+}
+
+func (p *projection) setTie(t Friendship) {
+	if p.ties[t.UserID] == nil {
+		p.ties[t.UserID] = make(map[int]Friendship)
+	}
+	p.ties[t.UserID][t.FriendID] = t
+}
+
+// friendships - дружбы пользователя id в порядке появления, кроме друзей из hidden
+func (p *projection) friendships(id int, f FriendFilter, hidden map[int]bool) ([]Friendship, error) {
+	u, ok := p.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	out := []Friendship{}
+	for _, fid := range u.Friends {
+		if t := p.tie(id, fid); !hidden[fid] && f.match(&t) {
+			out = append(out, t.clone())
+		}
+	}
+	return out, nil
+}
+
+// friendship - дружба id с friendID
+func (p *projection) friendship(id, friendID int) (Friendship, error) {
+	u, ok := p.users[id]
+	switch {
+	case !ok:
+		return Friendship{}, ErrNotFound
+	case !u.HasFriend(friendID):
+		return Friendship{}, ErrNotFriends
+	}
+	return p.tie(id, friendID).clone(), nil
+}
+
+// Friendships - дружбы пользователя id в порядке появления (без заблокировавших владельца запроса)
+func (s *Store) Friendships(ctx context.Context, id int, f FriendFilter) ([]Friendship, error) {
+	_, span := tracing.Store(ctx, "friendships", attribute.Int("user.id", id))
+	defer span.End()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state.friendships(id, f, s.hiddenFrom(ctx))
+}
+
+// Friendship - дружба пользователя id с friendID; ErrNotFriends - они не друзья
+func (s *Store) Friendship(ctx context.Context, id, friendID int) (Friendship, error) {
+	_, span := tracing.Store(ctx, "friendship", attribute.Int("user.id", id), attribute.Int("friend.id", friendID))
+	defer span.End()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.hiddenFrom(ctx)[friendID] {
+		return Friendship{}, ErrNotFriends
+	}
+	return s.state.friendship(id, friendID)
+}
+
+// UpdateFriendship меняет метки и близость друга friendID для пользователя id
+// (у друга его собственные остаются прежними)
+func (s *Store) UpdateFriendship(ctx context.Context, id, friendID int, patch FriendshipPatch) (Friendship, error) {
+	_, span := tracing.Store(ctx, "update_friendship", attribute.Int("user.id", id), attribute.Int("friend.id", friendID))
+	defer span.End()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, err := s.state.friendship(id, friendID)
+	if err == nil && patch.Labels != nil {
+		t.Labels, err = labels(patch.Labels)
+	}
+	if err == nil && patch.Closeness != nil {
+		if *patch.Closeness < 0 || *patch.Closeness > MaxCloseness {
+			err = ErrInvalidFriendship
+		} else {
+			t.Closeness = *patch.Closeness
+		}
+	}
+	if err != nil {
+		tracing.Fail(span, err)
+		return Friendship{}, err
+	}
+	s.record(ctx, Event{Type: FriendshipUpdated, User: User{ID: id}, FriendID: friendID, Friendship: &t})
+	return s.state.tie(id, friendID).clone(), nil
+}
+
+// Friendships - дружбы пользователя id на момент View (без заблокировавших владельца запроса)
+func (v *View) Friendships(ctx context.Context, id int, f FriendFilter) ([]Friendship, error) {
+	if v.purged(id) {
+		return nil, ErrNotFound
+	}
+	return v.state.friendships(id, f, v.hidden(ctx))
+}
+
+// Friendship - дружба пользователя id с friendID на момент View
+func (v *View) Friendship(ctx context.Context, id, friendID int) (Friendship, error) {
+	if v.purged(id) {
+		return Friendship{}, ErrNotFound
+	}
+	if v.hidden(ctx)[friendID] {
+		return Friendship{}, ErrNotFriends
+	}
+	return v.state.friendship(id, friendID)
+}
@@ -0,0 +1,464 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"Network-exchange/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrHasHistory - снимок загружается только в хранилище без изменений
+var ErrHasHistory = errors.New("в хранилище уже есть изменения")
+
+// projection - состояние, выведенное из журнала: пользователи с друзьями, помеченные
+// удаленными (скрыты от поиска, ID не используются повторно) и блокировки
+type projection struct {
+	users   map[int]*User
+	deleted map[int]*Removal
+	blocks  map[int]map[int]bool       //кто -> кого заблокировал
+	ties    map[int]map[int]Friendship //дружба глазами пользователя: пользователь -> друг
+	follows edges                      //подписчик -> на кого подписан
+	pending edges                      //заявки на подписку: подписчик -> к кому
+	//approval - пользователи, которые одобряют подписчиков сами
+	approval map[int]bool
+	lastID   int
+}
+
+func newProjection() projection {
+	return projection{users: make(map[int]*User), deleted: make(map[int]*Removal), blocks: make(map[int]map[int]bool),
+		ties: make(map[int]map[int]Friendship), follows: newEdges(), pending: newEdges(), approval: make(map[int]bool)}
+}
+
+// clone - независимая копия состояния
+func (p *projection) clone() projection {
+	out := projection{users: make(map[int]*User, len(p.users)), deleted: make(map[int]*Removal, len(p.deleted)), lastID: p.lastID}
+	for id, u := range p.users {
+		c := u.clone()
+		out.users[id] = &c
+	}
+	for id, d := range p.deleted {
+		out.deleted[id] = &Removal{User: d.User.clone(), DeletedAt: d.DeletedAt, ties: slices.Clone(d.ties)}
+	}
+	out.blocks = make(map[int]map[int]bool, len(p.blocks))
+	for id, blocked := range p.blocks {
+		out.blocks[id] = maps.Clone(blocked)
+	}
+	out.ties = make(map[int]map[int]Friendship, len(p.ties))
+	for id, ties := range p.ties {
+		out.ties[id] = maps.Clone(ties) //метки записи не меняются на месте
+	}
+	out.follows, out.pending, out.approval = p.follows.clone(), p.pending.clone(), maps.Clone(p.approval)
+	return out
+}
+
+// apply применяет изменение. Изменения проверяются до записи в журнал, поэтому apply
+// не ошибается, а повторное проигрывание журнала дает то же состояние.
+func (p *projection) apply(e Event) {
+	switch e.Type {
+	case UserRegistered:
+		p.users[e.User.ID] = &User{ID: e.User.ID, Name: e.User.Name, Age: e.User.Age, Friends: []int{},
+			Profile: e.User.Profile.clone(), CreatedAt: e.Time, UpdatedAt: e.Time}
+		p.lastID = max(p.lastID, e.User.ID)
+	case AgeChanged:
+		p.users[e.User.ID].Age = e.User.Age
+		p.users[e.User.ID].UpdatedAt = e.Time
+	case ProfileUpdated:
+		u := p.users[e.User.ID]
+		u.Profile, u.UpdatedAt = e.User.Profile.clone(), e.Time
+		if u.BirthDate != "" {
+			u.Age = ageOn(u.BirthDate, e.Time) //на случай, если дату рождения затем очистят
+		}
+	case FriendshipFormed:
+		source, target := p.users[e.User.ID], p.users[e.FriendID]
+		source.Friends = append(source.Friends, target.ID)
+		target.Friends = append(target.Friends, source.ID)
+		initiator, since := source.ID, e.Time
+		if e.Friendship != nil { //восстановленная дружба
+			initiator, since = e.Friendship.InitiatorID, e.Friendship.Since
+		}
+		p.setTie(Friendship{UserID: source.ID, FriendID: target.ID, InitiatorID: initiator, Since: since, Labels: []string{}})
+		p.setTie(Friendship{UserID: target.ID, FriendID: source.ID, InitiatorID: initiator, Since: since, Labels: []string{}})
+	case FriendshipEnded:
+		source, target := p.users[e.User.ID], p.users[e.FriendID]
+		source.Friends = without(source.Friends, target.ID)
+		target.Friends = without(target.Friends, source.ID)
+		delete(p.ties[source.ID], target.ID)
+		delete(p.ties[target.ID], source.ID)
+	case FriendshipUpdated:
+		p.setTie(*e.Friendship)
+	case UserDeleted:
+		var ties []Friendship
+		for _, f := range p.users[e.User.ID].Friends {
+			ties = append(ties, p.tie(e.User.ID, f), p.tie(f, e.User.ID))
+		}
+		u := p.remove(e.User.ID)
+		p.deleted[u.ID] = &Removal{User: u.clone(), DeletedAt: e.Time, ties: ties}
+	case UserRestored:
+		d := p.deleted[e.User.ID]
+		delete(p.deleted, e.User.ID)
+		u := d.User.clone()
+		u.Friends = []int{}
+		p.users[e.User.ID] = &u
+	case UserPurged:
+		if _, ok := p.users[e.User.ID]; ok {
+			p.remove(e.User.ID)
+		}
+		delete(p.deleted, e.User.ID)
+		delete(p.blocks, e.User.ID)
+		for _, blocked := range p.blocks {
+			delete(blocked, e.User.ID)
+		}
+	case UserBlocked:
+		if p.blocks[e.User.ID] == nil {
+			p.blocks[e.User.ID] = make(map[int]bool)
+		}
+		p.blocks[e.User.ID][e.FriendID] = true
+	case UserUnblocked:
+		delete(p.blocks[e.User.ID], e.FriendID)
+	case FollowRequested:
+		p.pending.add(e.User.ID, e.FriendID)
+	case FollowDeclined:
+		p.pending.del(e.User.ID, e.FriendID)
+	case Followed:
+		p.pending.del(e.User.ID, e.FriendID)
+		p.follows.add(e.User.ID, e.FriendID)
+	case Unfollowed:
+		p.follows.del(e.User.ID, e.FriendID)
+	case FollowApprovalOn:
+		p.approval[e.User.ID] = true
+	case FollowApprovalOff:
+		delete(p.approval, e.User.ID)
+	}
+}
+
+// blocked - заблокировал ли кто-то из двоих другого
+func (p *projection) blocked(a, b int) bool {
+	return p.blocks[a][b] || p.blocks[b][a]
+}
+
+// remove убирает пользователя из текущих, стирает его из друзей остальных (с метками дружбы)
+// и прекращает его подписки (они не восстанавливаются)
+func (p *projection) remove(id int) *User {
+	u := p.users[id]
+	delete(p.users, id)
+	p.follows.drop(id)
+	p.pending.drop(id)
+	for _, f := range u.Friends {
+		if other, ok := p.users[f]; ok {
+			other.Friends = without(other.Friends, id)
+		}
+		delete(p.ties[f], id)
+	}
+	delete(p.ties, id)
+	return u
+}
+
+func (p *projection) get(id int) (User, error) {
+	u, ok := p.users[id]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+	return u.clone(), nil
+}
+
+// byName - пользователь с именем name (при повторах - с меньшим ID)
+func (p *projection) byName(name string) *User {
+	var found *User
+	for _, u := range p.users {
+		if u.Name == name && (found == nil || u.ID < found.ID) {
+			found = u
+		}
+	}
+	return found
+}
+
+// list - страница подходящих под фильтр по возрастанию ID и общее число подходящих;
+// hidden (может быть nil) - кого не показывать ни в списке, ни среди друзей
+func (p *projection) list(f Filter, hidden map[int]bool) ([]User, int) {
+	var out []User
+	for _, u := range p.users {
+		if f.match(u) && !hidden[u.ID] {
+			out = append(out, strip(u.clone(), hidden))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	total := len(out)
+	f.Offset = max(f.Offset, 0) //отрицательный сдвиг - с начала
+	if f.Offset >= len(out) {
+		return []User{}, total
+	}
+	out = out[f.Offset:]
+	if f.Limit > 0 && f.Limit < len(out) {
+		out = out[:f.Limit]
+	}
+	return out, total
+}
+
+// Observer вызывается после каждого изменения в порядке журнала, под блокировкой хранилища
+// (поэтому должен быть быстрым и не обращаться к хранилищу): ctx - контекст изменившего
+// запроса, before - пользователь e.User.ID до изменения (nil - его не было среди
+// текущих: новый, восстановленный или окончательно удаляемый помеченный удаленным)
+type Observer func(ctx context.Context, e Event, before *User)
+
+// Observe добавляет наблюдателя за изменениями; в отличие от Subscribe, наблюдатель
+// видит каждое изменение и контекст запроса, но задерживает само изменение
+func (s *Store) Observe(o Observer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observers = append(s.observers, o)
+}
+
+// record записывает проверенное изменение: назначает номер и время, применяет его
+// к текущему состоянию, добавляет в журнал и сообщает наблюдателям и подписчикам. В e
+// достаточно указать вид, ID (и имя с возрастом) пользователя и FriendID - остальное
+// record заполнит. Вызывается под s.mu.
+func (s *Store) record(ctx context.Context, e Event) Event {
+	e.Seq = uint64(len(s.journal)) + 1
+	e.Time = time.Now().UTC()
+	if n := len(s.journal); n > 0 && e.Time.Before(s.journal[n-1].Time) {
+		e.Time = s.journal[n-1].Time //время в журнале не убывает: по нему ищет At
+	}
+	var before *User
+	if u, ok := s.state.users[e.User.ID]; ok {
+		c := u.clone()
+		before = &c
+	}
+	s.state.apply(e)
+	if e.Type == UserDeleted || e.Type == UserPurged {
+		e.User = tombstone(e.User.ID) //прежние данные есть в before и Removal, а в журнал не попадают
+	} else {
+		e.User = s.state.users[e.User.ID].clone()
+	}
+	s.journal = append(s.journal, e)
+	if e.Type == UserPurged {
+		s.redact(e.User.ID)
+	}
+	if e.Seq%checkpointEvery == 0 {
+		s.checkpoints = append(s.checkpoints, s.state.clone())
+	}
+	for _, o := range s.observers {
+		o(ctx, e, before)
+	}
+	s.publish(e)
+	return e
+}
+
+// tombstone - пользователь, от которого остался только ID
+func tombstone(id int) User {
+	return User{ID: id, Friends: []int{}}
+}
+
+// redact стирает окончательно удаленного id из прошлого: в записях журнала, снимке и
+// запомненных состояниях от него остается только ID (номера изменений и дружба других
+// не меняются, проигрывание дает то же состояние), собранные At состояния сбрасываются.
+// Вызывается под s.mu.
+func (s *Store) redact(id int) {
+	if s.purged == nil {
+		s.purged = make(map[int]bool)
+	}
+	s.purged[id] = true
+	for i := range s.journal {
+		e := &s.journal[i]
+		if e.User.ID != id {
+			continue
+		}
+		e.User = tombstone(id)
+		if e.Friendship != nil { //его метки друга
+			t := *e.Friendship
+			t.Labels, t.Closeness = []string{}, 0
+			e.Friendship = &t
+		}
+	}
+	s.base.redact(id)
+	for i := range s.checkpoints {
+		s.checkpoints[i].redact(id)
+	}
+	s.views.reset()
+}
+
+// redact оставляет от пользователя id только ID и дружбу
+func (p *projection) redact(id int) {
+	if u, ok := p.users[id]; ok {
+		*u = User{ID: id, Friends: u.Friends}
+	}
+	if d, ok := p.deleted[id]; ok {
+		d.User, d.ties = User{ID: id, Friends: d.User.Friends}, nil
+	}
+	for fid, t := range p.ties[id] {
+		t.Labels, t.Closeness = []string{}, 0
+		p.ties[id][fid] = t
+	}
+}
+
+// View - состояние хранилища в прошлом (только чтение). Окончательно удаленных в нем нет,
+// даже если на тот момент они еще были, а заблокировавшие владельца запроса скрыты, как в Store:
+// по блокировкам на сейчас, а не на тот момент.
+type View struct {
+	Seq   uint64 //последнее учтенное изменение; 0 - ни одного
+	state projection
+	store *Store
+}
+
+// hidden - кого View не показывает в списках и среди друзей: окончательно удаленных
+// и заблокировавших владельца запроса
+func (v *View) hidden(ctx context.Context) map[int]bool {
+	v.store.mu.RLock()
+	defer v.store.mu.RUnlock()
+	hidden := v.store.hiddenFrom(ctx)
+	if hidden == nil {
+		hidden = make(map[int]bool, len(v.store.purged))
+	}
+	maps.Copy(hidden, v.store.purged)
+	return hidden
+}
+
+// purged - пользователь id удален окончательно
+func (v *View) purged(id int) bool {
+	v.store.mu.RLock()
+	defer v.store.mu.RUnlock()
+	return v.store.purged[id]
+}
+
+// Сборка прошлых состояний
+const (
+	checkpointEvery = 1000 //изменений между запомненными состояниями
+	viewCacheSize   = 16   //собранных состояний в кэше
+)
+
+// viewCache - последние собранные состояния по номеру изменения; View только читается,
+// поэтому одно состояние отдается всем запросам на тот же момент
+type viewCache struct {
+	mu    sync.Mutex
+	views map[uint64]*View
+	order []uint64 //порядок добавления: первым вытесняется самое старое
+}
+
+// reset очищает кэш: состояния собраны не из того, что теперь в хранилище
+func (c *viewCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.views, c.order = nil, nil
+}
+
+func (c *viewCache) get(seq uint64) *View {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.views[seq]
+}
+
+func (c *viewCache) put(v *View) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.views == nil {
+		c.views = make(map[uint64]*View)
+	}
+	if _, ok := c.views[v.Seq]; ok {
+		return
+	}
+	if len(c.order) >= viewCacheSize {
+		delete(c.views, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.views[v.Seq] = v
+	c.order = append(c.order, v.Seq)
+}
+
+// At восстанавливает состояние на момент t: к ближайшему запомненному состоянию (или снимку
+// из Load) применяются следующие изменения журнала не позже t - не больше checkpointEvery.
+// Собранное состояние кэшируется по номеру последнего изменения.
+func (s *Store) At(ctx context.Context, t time.Time) *View {
+	_, span := tracing.Store(ctx, "replay", attribute.String("as_of", t.UTC().Format(time.RFC3339Nano)))
+	defer span.End()
+	s.mu.RLock()
+	n := sort.Search(len(s.journal), func(i int) bool { return s.journal[i].Time.After(t) })
+	if v := s.views.get(uint64(n)); v != nil {
+		s.mu.RUnlock()
+		span.SetAttributes(attribute.Bool("cached", true))
+		return v
+	}
+	base, from := &s.base, 0
+	if i := n / checkpointEvery; i > 0 {
+		base, from = &s.checkpoints[i-1], i*checkpointEvery
+	}
+	v := &View{Seq: uint64(n), state: base.clone(), store: s}
+	events := slices.Clone(s.journal[from:n]) //redact меняет записи под s.mu
+	s.mu.RUnlock()
+	for _, e := range events {
+		v.state.apply(e)
+	}
+	s.views.put(v)
+	span.SetAttributes(attribute.Int("events", len(events)))
+	return v
+}
+
+// Get находит пользователя по ID
+func (v *View) Get(ctx context.Context, id int) (User, error) {
+	if v.purged(id) {
+		return User{}, ErrNotFound
+	}
+	u, err := v.state.get(id)
+	return strip(u, v.hidden(ctx)), err
+}
+
+// ByName находит пользователя по имени (при повторах - с меньшим ID)
+func (v *View) ByName(ctx context.Context, name string) (User, error) {
+	if u := v.state.byName(name); u != nil {
+		return v.Get(ctx, u.ID)
+	}
+	return User{}, ErrNotFound
+}
+
+// List возвращает страницу подходящих под фильтр пользователей и общее число подходящих
+func (v *View) List(ctx context.Context, f Filter) ([]User, int) {
+	return v.state.list(f, v.hidden(ctx))
+}
+
+// Events - изменения журнала после номера after по порядку, не больше limit (0 - все).
+// По ним сторонние модели чтения строятся заново или догоняют хранилище.
+func (s *Store) Events(after uint64, limit int) []Event {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if after >= uint64(len(s.journal)) {
+		return []Event{}
+	}
+	events := s.journal[after:]
+	if limit > 0 && limit < len(events) {
+		events = events[:limit]
+	}
+	return copyEvents(events, func(Event) bool { return true })
+}
+
+// History - изменения журнала с участием пользователя id (в том числе как друга) по порядку,
+// кроме Private; ErrNotFound - пользователь удален окончательно
+func (s *Store) History(ctx context.Context, id int) ([]Event, error) {
+	_, span := tracing.Store(ctx, "history", attribute.Int("user.id", id))
+	defer span.End()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.purged[id] {
+		tracing.Fail(span, ErrNotFound)
+		return nil, ErrNotFound
+	}
+	return copyEvents(s.journal, func(e Event) bool {
+		return (e.User.ID == id || e.FriendID == id) && !e.Type.Private()
+	}), nil
+}
+
+// copyEvents - копии подходящих записей журнала, которые можно отдать наружу
+func copyEvents(events []Event, ok func(Event) bool) []Event {
+	out := []Event{}
+	for _, e := range events {
+		if ok(e) {
+			e.User = e.User.clone()
+			out = append(out, e)
+		}
+	}
+	return out
+}
@@ -0,0 +1,350 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"net/mail"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"Network-exchange/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// DateLayout - формат даты рождения и полей типа date
+const DateLayout = "2006-01-02"
+
+// Ограничения полей профиля (в символах)
+const (
+	MaxDisplayName = 100
+	MaxBio         = 1000
+	MaxCity        = 100
+	MaxURL         = 2048
+	MaxFieldString = 200
+)
+
+// Profile - данные профиля пользователя; пустые поля не заданы
+type Profile struct {
+	Email       string         `json:"email,omitempty"` //уникален без учета регистра
+	DisplayName string         `json:"display_name,omitempty"`
+	Bio         string         `json:"bio,omitempty"`
+	City        string         `json:"city,omitempty"`
+	BirthDate   string         `json:"birth_date,omitempty"` //ГГГГ-ММ-ДД; возраст вычисляется по ней
+	AvatarURL   string         `json:"avatar_url,omitempty"`
+	Custom      map[string]any `json:"custom,omitempty"` //дополнительные поля (DeclareFields)
+}
+
+func (p Profile) clone() Profile {
+	p.Custom = maps.Clone(p.Custom)
+	return p
+}
+
+// ProfilePatch - изменение профиля: nil - поле не меняется, пустая строка - очищает его;
+// в Custom значение nil удаляет поле
+type ProfilePatch struct {
+	Email       *string
+	DisplayName *string
+	Bio         *string
+	City        *string
+	BirthDate   *string
+	AvatarURL   *string
+	Custom      map[string]any
+}
+
+func (p ProfilePatch) empty() bool {
+	return p.Email == nil && p.DisplayName == nil && p.Bio == nil && p.City == nil &&
+		p.BirthDate == nil && p.AvatarURL == nil && p.Custom == nil
+}
+
+// FieldType - тип дополнительного поля профиля
+type FieldType string
+
+// Типы дополнительных полей
+const (
+	FieldString  FieldType = "string" //до MaxFieldString символов; с Enum - одно из значений
+	FieldInteger FieldType = "integer"
+	FieldNumber  FieldType = "number"
+	FieldBoolean FieldType = "boolean"
+	FieldDate    FieldType = "date" //ГГГГ-ММ-ДД
+	FieldURL     FieldType = "url"  //http или https
+)
+
+// Field - объявленное дополнительное поле профиля
+type Field struct {
+	Name string    `json:"name"`
+	Type FieldType `json:"type"`
+	Enum []string  `json:"enum,omitempty"`
+}
+
+var fieldName = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+
+// ParseFields читает объявление дополнительных полей: "company=string,shoe_size=integer,
+// newsletter=boolean,start=date,site=url,team=red|blue" (значения через | - перечисление строк)
+func ParseFields(spec string) ([]Field, error) {
+	var fields []Field
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, typ, ok := strings.Cut(pair, "=")
+		name, typ = strings.TrimSpace(name), strings.TrimSpace(typ)
+		switch {
+		case !ok || !fieldName.MatchString(name):
+			return nil, fmt.Errorf("поле профиля %q: имя - латиница в нижнем регистре, цифры и _", pair)
+		case slices.ContainsFunc(fields, func(f Field) bool { return f.Name == name }):
+			return nil, fmt.Errorf("поле профиля %q объявлено несколько раз", name)
+		}
+		f := Field{Name: name, Type: FieldType(typ)}
+		if strings.Contains(typ, "|") {
+			f.Type, f.Enum = FieldString, strings.Split(typ, "|")
+			if slices.Contains(f.Enum, "") {
+				return nil, fmt.Errorf("поле профиля %q: пустое значение перечисления", name)
+			}
+		}
+		switch f.Type {
+		case FieldString, FieldInteger, FieldNumber, FieldBoolean, FieldDate, FieldURL:
+		default:
+			return nil, fmt.Errorf("поле профиля %q: неизвестный тип %q", name, typ)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// FieldsFromEnv - дополнительные поля профиля из PROFILE_FIELDS (формат - ParseFields)
+func FieldsFromEnv() ([]Field, error) {
+	return ParseFields(os.Getenv("PROFILE_FIELDS"))
+}
+
+// DeclareFields задает дополнительные поля профиля; вызывается до обработки запросов.
+// Значения необъявленных полей не принимаются.
+func (s *Store) DeclareFields(fields []Field) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fields = slices.Clone(fields)
+}
+
+// Fields - объявленные дополнительные поля профиля
+func (s *Store) Fields() []Field {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.fields)
+}
+
+// invalid - ошибка ErrInvalidProfile с указанием поля
+func invalid(field, reason string) error {
+	return fmt.Errorf("%w: %s - %s", ErrInvalidProfile, field, reason)
+}
+
+// checkProfile приводит профиль к единому виду (email в нижнем регистре, без пробелов по краям)
+// и проверяет его; вызывается под s.mu
+func (s *Store) checkProfile(p Profile) (Profile, error) {
+	p.Email = strings.ToLower(strings.TrimSpace(p.Email))
+	p.DisplayName, p.Bio, p.City = strings.TrimSpace(p.DisplayName), strings.TrimSpace(p.Bio), strings.TrimSpace(p.City)
+	p.BirthDate, p.AvatarURL = strings.TrimSpace(p.BirthDate), strings.TrimSpace(p.AvatarURL)
+	if p.Email != "" {
+		if a, err := mail.ParseAddress(p.Email); err != nil || a.Address != p.Email || !strings.Contains(p.Email[strings.LastIndex(p.Email, "@"):], ".") {
+			return p, invalid("email", "некорректный адрес")
+		}
+	}
+	for _, f := range []struct {
+		name, value string
+		max         int
+	}{{"display_name", p.DisplayName, MaxDisplayName}, {"bio", p.Bio, MaxBio}, {"city", p.City, MaxCity}} {
+		if utf8.RuneCountInString(f.value) > f.max {
+			return p, invalid(f.name, fmt.Sprintf("не больше %d символов", f.max))
+		}
+	}
+	if p.BirthDate != "" {
+		d, err := time.Parse(DateLayout, p.BirthDate)
+		if err != nil || d.After(time.Now()) || d.Year() < 1900 {
+			return p, invalid("birth_date", "дата ГГГГ-ММ-ДД не раньше 1900 года и не в будущем")
+		}
+	}
+	if p.AvatarURL != "" && !validURL(p.AvatarURL) {
+		return p, invalid("avatar_url", "адрес http или https")
+	}
+	if len(p.Custom) == 0 {
+		p.Custom = nil
+	}
+	for name, v := range p.Custom {
+		i := slices.IndexFunc(s.fields, func(f Field) bool { return f.Name == name })
+		if i < 0 {
+			return p, invalid("custom."+name, "поле не объявлено")
+		}
+		if reason := s.fields[i].check(v); reason != "" {
+			return p, invalid("custom."+name, reason)
+		}
+	}
+	return p, nil
+}
+
+// check - причина, по которой значение не подходит полю ("" - подходит)
+func (f Field) check(v any) string {
+	switch f.Type {
+	case FieldInteger, FieldNumber:
+		n, ok := v.(float64) //числа JSON
+		if !ok || f.Type == FieldInteger && n != math.Trunc(n) {
+			return "ожидается " + string(f.Type)
+		}
+	case FieldBoolean:
+		if _, ok := v.(bool); !ok {
+			return "ожидается boolean"
+		}
+	default:
+		s, ok := v.(string)
+		switch {
+		case !ok || s == "" || utf8.RuneCountInString(s) > MaxFieldString:
+			return fmt.Sprintf("ожидается строка от 1 до %d символов", MaxFieldString)
+		case f.Enum != nil && !slices.Contains(f.Enum, s):
+			return "одно из значений: " + strings.Join(f.Enum, ", ")
+		case f.Type == FieldDate:
+			if _, err := time.Parse(DateLayout, s); err != nil {
+				return "дата ГГГГ-ММ-ДД"
+			}
+		case f.Type == FieldURL && !validURL(s):
+			return "адрес http или https"
+		}
+	}
+	return ""
+}
+
+func validURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && len(s) <= MaxURL && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// byEmail - текущий пользователь с адресом email
+func (p *projection) byEmail(email string) *User {
+	for _, u := range p.users {
+		if u.Email == email {
+			return u
+		}
+	}
+	return nil
+}
+
+// emailTaken - адрес занят другим пользователем (не id)
+func (p *projection) emailTaken(email string, id int) bool {
+	u := p.byEmail(email)
+	return email != "" && u != nil && u.ID != id
+}
+
+// ageOn - полных лет на момент t для даты рождения birth (ГГГГ-ММ-ДД)
+func ageOn(birth string, t time.Time) int {
+	d, err := time.Parse(DateLayout, birth)
+	if err != nil {
+		return 0
+	}
+	age := t.Year() - d.Year()
+	if t.Month() < d.Month() || t.Month() == d.Month() && t.Day() < d.Day() {
+		age--
+	}
+	return max(age, 0)
+}
+
+// CreateWithProfile добавляет пользователя с профилем; при заданной дате рождения
+// возраст age не учитывается. Ошибки: ErrInvalid, ErrInvalidProfile, ErrNameTaken, ErrEmailTaken
+// и ошибки reserve.
+func (s *Store) CreateWithProfile(ctx context.Context, name string, age int, p Profile, reserve ...Reserve) (User, error) {
+	_, span := tracing.Store(ctx, "create", attribute.String("user.name", name))
+	defer span.End()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, err := s.checkProfile(p.clone())
+	if err == nil && p.BirthDate != "" {
+		age = ageOn(p.BirthDate, time.Now())
+	}
+	switch {
+	case err != nil:
+	case checkUser(name, age) != nil:
+		err = ErrInvalid
+	case s.uniqueNames && s.state.byName(name) != nil:
+		err = ErrNameTaken
+	case s.state.emailTaken(p.Email, 0):
+		err = ErrEmailTaken
+	}
+	u := User{ID: s.state.lastID + 1, Name: name, Age: age, Profile: p}
+	for _, r := range reserve {
+		if err == nil && r != nil {
+			err = r(u)
+		}
+	}
+	if err != nil {
+		tracing.Fail(span, err)
+		return User{}, err
+	}
+	e := s.record(ctx, Event{Type: UserRegistered, User: u})
+	span.SetAttributes(attribute.Int("user.id", e.User.ID))
+	return e.User.clone(), nil
+}
+
+// UpdateProfile меняет профиль пользователя id. Ошибки: ErrNotFound, ErrInvalidProfile, ErrEmailTaken.
+func (s *Store) UpdateProfile(ctx context.Context, id int, patch ProfilePatch) (User, error) {
+	return s.UpdateUser(ctx, id, nil, patch)
+}
+
+// UpdateUser меняет возраст (age != nil) и профиль пользователя id за одно обращение: все
+// проверяется до записи, поэтому при ошибке не меняется ничего. Возраст нельзя задать при
+// дате рождения - в том числе заданной этим же изменением. Ошибки: ErrNotFound, ErrInvalid,
+// ErrInvalidProfile, ErrEmailTaken, ErrAgeDerived.
+func (s *Store) UpdateUser(ctx context.Context, id int, age *int, patch ProfilePatch) (User, error) {
+	_, span := tracing.Store(ctx, "update_profile", attribute.Int("user.id", id))
+	defer span.End()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.state.users[id]
+	if !ok {
+		tracing.Fail(span, ErrNotFound)
+		return User{}, ErrNotFound
+	}
+	p := u.Profile.clone()
+	for _, f := range []struct {
+		to   *string
+		from *string
+	}{{&p.Email, patch.Email}, {&p.DisplayName, patch.DisplayName}, {&p.Bio, patch.Bio},
+		{&p.City, patch.City}, {&p.BirthDate, patch.BirthDate}, {&p.AvatarURL, patch.AvatarURL}} {
+		if f.from != nil {
+			*f.to = *f.from
+		}
+	}
+	for name, v := range patch.Custom {
+		if p.Custom == nil {
+			p.Custom = make(map[string]any)
+		}
+		if v == nil {
+			delete(p.Custom, name)
+		} else {
+			p.Custom[name] = v
+		}
+	}
+	p, err := s.checkProfile(p)
+	switch {
+	case err != nil:
+	case s.state.emailTaken(p.Email, id):
+		err = ErrEmailTaken
+	case age != nil && p.BirthDate != "":
+		err = ErrAgeDerived
+	case age != nil:
+		err = checkUser(u.Name, *age)
+	}
+	if err != nil {
+		tracing.Fail(span, err)
+		return User{}, err
+	}
+	if age == nil || !patch.empty() {
+		s.record(ctx, Event{Type: ProfileUpdated, User: User{ID: id, Profile: p}})
+	}
+	if age != nil {
+		s.record(ctx, Event{Type: AgeChanged, User: User{ID: id, Age: *age}})
+	}
+	return s.state.users[id].clone(), nil
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"Network-exchange/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Check проверяет целостность набора пользователей (например, файла выгрузки):
+// уникальные положительные ID, корректные данные, взаимная дружба без ссылок
+// на несуществующих пользователей; uniqueNames - имена не повторяются.
+// Возвращает найденные нарушения (nil - нарушений нет).
+func Check(users []User, uniqueNames bool) []string {
+	var problems []string
+	byID := make(map[int]User, len(users))
+	names := make(map[string]int, len(users))
+	for _, u := range users {
+		if u.ID <= 0 {
+			problems = append(problems, fmt.Sprintf("пользователь %q: некорректный ID %d", u.Name, u.ID))
+			continue
+		}
+		if _, dup := byID[u.ID]; dup {
+			problems = append(problems, fmt.Sprintf("ID %d встречается несколько раз", u.ID))
+			continue
+		}
+		byID[u.ID] = u
+		if checkUser(u.Name, u.Age) != nil {
+			problems = append(problems, fmt.Sprintf("ID %d: пустое имя или отрицательный возраст", u.ID))
+		}
+		if other, dup := names[u.Name]; dup && uniqueNames {
+			problems = append(problems, fmt.Sprintf("ID %d: имя %q уже у ID %d", u.ID, u.Name, other))
+		} else if !dup {
+			names[u.Name] = u.ID
+		}
+	}
+	ids := make([]int, 0, len(byID))
+	for id := range byID {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		seen := make(map[int]bool)
+		for _, f := range byID[id].Friends {
+			friend, ok := byID[f]
+			switch {
+			case f == id:
+				problems = append(problems, fmt.Sprintf("ID %d: дружит сам с собой", id))
+			case seen[f]:
+				problems = append(problems, fmt.Sprintf("ID %d: друг %d указан несколько раз", id, f))
+			case !ok:
+				problems = append(problems, fmt.Sprintf("ID %d: друг %d не существует", id, f))
+			case !friend.HasFriend(id):
+				problems = append(problems, fmt.Sprintf("ID %d: дружба с %d не взаимна", id, f))
+			}
+			seen[f] = true
+		}
+	}
+	return problems
+}
+
+// Load загружает в новое хранилище снимок - набор пользователей с их ID и друзьями
+// (в том же порядке); журнал изменений начинается после него. Набор с нарушениями (Check)
+// не загружается, а хранилище, где уже были изменения, не перезаписывается: история неизменна.
+func (s *Store) Load(ctx context.Context, users []User) error {
+	_, span := tracing.Store(ctx, "load", attribute.Int("users", len(users)))
+	defer span.End()
+	if problems := Check(users, s.uniqueNames); len(problems) > 0 {
+		err := fmt.Errorf("%w: %s (всего нарушений: %d)", ErrInvalid, problems[0], len(problems))
+		tracing.Fail(span, err)
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.journal) > 0 {
+		tracing.Fail(span, ErrHasHistory)
+		return ErrHasHistory
+	}
+	base := newProjection()
+	for _, u := range users {
+		u := u.clone()
+		if u.Friends == nil {
+			u.Friends = []int{}
+		}
+		base.users[u.ID] = &u
+		base.lastID = max(base.lastID, u.ID)
+	}
+	s.base, s.state = base, base.clone()
+	s.views.reset() //состояние до первого изменения стало другим
+	return nil
+}
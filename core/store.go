@@ -0,0 +1,343 @@
+package core
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"Network-exchange/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Store - хранилище пользователей в памяти (каждая операция - дочерний спан трассировки).
+// Изменения не правят данные на месте, а записываются в журнал (Event); текущее состояние -
+// результат применения журнала к начальному снимку, из него и читают все запросы.
+type Store struct {
+	mu          sync.RWMutex
+	base        projection //снимок до первого изменения (Load)
+	journal     []Event    //все изменения по порядку; Seq = индекс + 1
+	state       projection //текущее состояние
+	uniqueNames bool
+	isViewer    func(ctx context.Context, u User) bool
+	observers   []Observer
+	fields      []Field //дополнительные поля профиля
+	ev          events  //подписчики на изменения
+	//checkpoints - состояние после каждого checkpointEvery-го изменения: At проигрывает
+	//журнал от ближайшего, а не с начала
+	checkpoints []projection
+	views       viewCache    //последние собранные At состояния
+	purged      map[int]bool //окончательно удаленные (redact)
+}
+
+// NewStore создает пустое хранилище; uniqueNames запрещает пользователей с одинаковыми именами
+func NewStore(uniqueNames bool) *Store {
+	return &Store{base: newProjection(), state: newProjection(), uniqueNames: uniqueNames}
+}
+
+// копия пользователя, которую можно отдать наружу (возраст - на текущий момент)
+func (u *User) clone() User {
+	out := *u
+	out.Friends = append([]int{}, u.Friends...)
+	out.Profile = u.Profile.clone()
+	out.Age = u.age(time.Now())
+	return out
+}
+
+// Reserve вызывается при создании пользователя под блокировкой хранилища, когда проверки
+// пройдены, а событие еще не записано: ошибка отменяет создание (например, занят логин
+// учетной записи), и подписчики такого пользователя не увидят
+type Reserve func(u User) error
+
+// Create добавляет пользователя без профиля и присваивает ему следующий ID
+func (s *Store) Create(ctx context.Context, name string, age int, reserve ...Reserve) (User, error) {
+	return s.CreateWithProfile(ctx, name, age, Profile{}, reserve...)
+}
+
+// Get находит пользователя по ID
+func (s *Store) Get(ctx context.Context, id int) (User, error) {
+	_, span := tracing.Store(ctx, "find", attribute.Int("user.id", id))
+	defer span.End()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	u, err := s.state.get(id)
+	return strip(u, s.hiddenFrom(ctx)), err
+}
+
+// GetMany находит пользователей с перечисленными ID за одно обращение к хранилищу
+// (несуществующие пропускаются)
+func (s *Store) GetMany(ctx context.Context, ids []int) map[int]User {
+	_, span := tracing.Store(ctx, "find_many", attribute.Int("users.count", len(ids)))
+	defer span.End()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	hidden := s.hiddenFrom(ctx)
+	out := make(map[int]User, len(ids))
+	for _, id := range ids {
+		if u, ok := s.state.users[id]; ok {
+			out[id] = strip(u.clone(), hidden)
+		}
+	}
+	return out
+}
+
+// ByName находит пользователя по имени (при повторах - с меньшим ID)
+func (s *Store) ByName(ctx context.Context, name string) (User, error) {
+	_, span := tracing.Store(ctx, "find", attribute.String("user.name", name))
+	defer span.End()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if u := s.state.byName(name); u != nil {
+		return strip(u.clone(), s.hiddenFrom(ctx)), nil
+	}
+	return User{}, ErrNotFound
+}
+
+// List возвращает страницу подходящих под фильтр пользователей по возрастанию ID
+// и общее число подходящих; заблокировавшие владельца запроса не показываются
+func (s *Store) List(ctx context.Context, f Filter) ([]User, int) {
+	_, span := tracing.Store(ctx, "list")
+	defer span.End()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state.list(f, s.hiddenFrom(ctx))
+}
+
+// All - все пользователи по возрастанию ID
+func (s *Store) All(ctx context.Context) []User {
+	out, _ := s.List(ctx, Filter{})
+	return out
+}
+
+// Names - имена пользователей с перечисленными ID (несуществующие пропускаются)
+func (s *Store) Names(ids []int) map[int]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[int]string, len(ids))
+	for _, id := range ids {
+		if u, ok := s.state.users[id]; ok {
+			out[id] = u.Name
+		}
+	}
+	return out
+}
+
+// Befriend делает пользователей друзьями (sourceID - инициатор); ErrBlocked - один
+// из них заблокировал другого
+func (s *Store) Befriend(ctx context.Context, sourceID, targetID int) error {
+	_, span := tracing.Store(ctx, "befriend", attribute.Int("source.id", sourceID), attribute.Int("target.id", targetID))
+	defer span.End()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	source, err := s.pair(sourceID, targetID)
+	switch {
+	case err != nil:
+	case s.state.blocked(sourceID, targetID):
+		err = ErrBlocked
+	case source.HasFriend(targetID):
+		err = ErrAlreadyFriends
+	}
+	if err != nil {
+		tracing.Fail(span, err)
+		return err
+	}
+	s.record(ctx, Event{Type: FriendshipFormed, User: User{ID: sourceID}, FriendID: targetID})
+	return nil
+}
+
+// Unfriend прекращает дружбу пользователей
+func (s *Store) Unfriend(ctx context.Context, sourceID, targetID int) error {
+	_, span := tracing.Store(ctx, "unfriend", attribute.Int("source.id", sourceID), attribute.Int("target.id", targetID))
+	defer span.End()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	source, err := s.pair(sourceID, targetID)
+	if err == nil && !source.HasFriend(targetID) {
+		err = ErrNotFriends
+	}
+	if err != nil {
+		tracing.Fail(span, err)
+		return err
+	}
+	s.record(ctx, Event{Type: FriendshipEnded, User: User{ID: sourceID}, FriendID: targetID})
+	return nil
+}
+
+// pair проверяет, что оба участника дружбы есть и различны; возвращает инициатора
+func (s *Store) pair(sourceID, targetID int) (*User, error) {
+	source, ok1 := s.state.users[sourceID]
+	_, ok2 := s.state.users[targetID]
+	switch {
+	case !ok1 || !ok2:
+		return nil, ErrNotFound
+	case sourceID == targetID:
+		return nil, ErrSelfFriend
+	}
+	return source, nil
+}
+
+func without(ids []int, id int) []int {
+	out := ids[:0]
+	for _, f := range ids {
+		if f != id {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// SetAge изменяет возраст пользователя
+func (s *Store) SetAge(ctx context.Context, id, age int) (User, error) {
+	_, span := tracing.Store(ctx, "update", attribute.Int("user.id", id))
+	defer span.End()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.state.users[id]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+	err := checkUser(u.Name, age)
+	if err == nil && u.BirthDate != "" {
+		err = ErrAgeDerived
+	}
+	if err != nil {
+		tracing.Fail(span, err)
+		return User{}, err
+	}
+	e := s.record(ctx, Event{Type: AgeChanged, User: User{ID: id, Age: age}})
+	return e.User.clone(), nil
+}
+
+// Delete помечает пользователя удаленным: он пропадает из поиска и друзей остальных,
+// а прежние друзья запоминаются для Restore
+func (s *Store) Delete(ctx context.Context, id int) (User, error) {
+	_, span := tracing.Store(ctx, "delete", attribute.Int("user.id", id))
+	defer span.End()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.state.users[id]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+	out := u.clone()
+	s.record(ctx, Event{Type: UserDeleted, User: User{ID: id}})
+	return out, nil
+}
+
+// Restore возвращает помеченного удаленным вместе с дружбой с теми из прежних друзей,
+// кто сам не удален и не заблокирован: с прежними временем, инициатором, метками и близостью
+// (с обеих сторон); ErrNotFound - пользователь не помечен удаленным (или удален окончательно)
+func (s *Store) Restore(ctx context.Context, id int) (User, error) {
+	_, span := tracing.Store(ctx, "restore", attribute.Int("user.id", id))
+	defer span.End()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.state.deleted[id]
+	if !ok {
+		tracing.Fail(span, ErrNotFound)
+		return User{}, ErrNotFound
+	}
+	if s.uniqueNames && s.state.byName(d.User.Name) != nil {
+		tracing.Fail(span, ErrNameTaken)
+		return User{}, ErrNameTaken
+	}
+	if s.state.emailTaken(d.User.Email, id) {
+		tracing.Fail(span, ErrEmailTaken)
+		return User{}, ErrEmailTaken
+	}
+	ties := d.ties //запись d после восстановления больше не меняется
+	s.record(ctx, Event{Type: UserRestored, User: User{ID: id}})
+	for i := 0; i+1 < len(ties); i += 2 { //пары: его запись о друге и запись друга о нем
+		own, theirs := ties[i], ties[i+1]
+		f := own.FriendID
+		if _, ok := s.state.users[f]; !ok || s.state.blocked(id, f) {
+			continue
+		}
+		source, target := id, f
+		if own.InitiatorID == f {
+			source, target = f, id
+		}
+		formed := Friendship{UserID: source, FriendID: target, InitiatorID: own.InitiatorID, Since: own.Since, Labels: []string{}}
+		s.record(ctx, Event{Type: FriendshipFormed, User: User{ID: source}, FriendID: target, Friendship: &formed})
+		for _, t := range []Friendship{own, theirs} {
+			if len(t.Labels) > 0 || t.Closeness > 0 {
+				s.record(ctx, Event{Type: FriendshipUpdated, User: User{ID: t.UserID}, FriendID: t.FriendID, Friendship: &t})
+			}
+		}
+	}
+	return s.state.users[id].clone(), nil
+}
+
+// Purge удаляет пользователя окончательно - и помеченного удаленным, и текущего
+// (например, при отмене регистрации); восстановить его уже нельзя, а из журнала стираются
+// его данные. Возвращает пользователя до удаления (чтобы убрать, например, учетную запись).
+func (s *Store) Purge(ctx context.Context, id int) (User, error) {
+	_, span := tracing.Store(ctx, "purge", attribute.Int("user.id", id))
+	defer span.End()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out User
+	if u, ok := s.state.users[id]; ok {
+		out = u.clone()
+	} else if d, ok := s.state.deleted[id]; ok {
+		out = d.User.clone()
+	} else {
+		tracing.Fail(span, ErrNotFound)
+		return User{}, ErrNotFound
+	}
+	s.record(ctx, Event{Type: UserPurged, User: User{ID: id}})
+	return out, nil
+}
+
+// PurgeBefore окончательно удаляет помеченных удаленными раньше t; возвращает их по возрастанию ID
+func (s *Store) PurgeBefore(ctx context.Context, t time.Time) []User {
+	_, span := tracing.Store(ctx, "purge_expired")
+	defer span.End()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []int
+	for id, d := range s.state.deleted {
+		if d.DeletedAt.Before(t) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	out := make([]User, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, s.state.deleted[id].User.clone())
+		s.record(ctx, Event{Type: UserPurged, User: User{ID: id}})
+	}
+	span.SetAttributes(attribute.Int("users", len(out)))
+	return out
+}
+
+// Removed - помеченные удаленными по времени удаления
+func (s *Store) Removed(ctx context.Context) []Removal {
+	_, span := tracing.Store(ctx, "list_removed")
+	defer span.End()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Removal, 0, len(s.state.deleted))
+	for _, d := range s.state.deleted {
+		out = append(out, Removal{User: d.User.clone(), DeletedAt: d.DeletedAt})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].DeletedAt.Equal(out[j].DeletedAt) {
+			return out[i].DeletedAt.Before(out[j].DeletedAt)
+		}
+		return out[i].User.ID < out[j].User.ID
+	})
+	return out
+}
+
+// RemovedUser - помеченный удаленным пользователь id; ErrNotFound - такого нет
+func (s *Store) RemovedUser(ctx context.Context, id int) (Removal, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	d, ok := s.state.deleted[id]
+	if !ok {
+		return Removal{}, ErrNotFound
+	}
+	return Removal{User: d.User.clone(), DeletedAt: d.DeletedAt}, nil
+}
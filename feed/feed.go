@@ -0,0 +1,228 @@
+// Package feed - лента изменений хранилища core по Server-Sent Events (GET /events).
+// Последние изменения хранятся в памяти: переподключившийся клиент передает заголовок
+// Last-Event-ID и получает пропущенное, если оно еще не вытеснено из буфера.
+package feed
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"Network-exchange/auth"
+	"Network-exchange/core"
+)
+
+// Path - адрес ленты
+const Path = "/events"
+
+// Настройки по умолчанию
+const (
+	DefaultSize      = 1000             //изменений в памяти для Last-Event-ID
+	DefaultHeartbeat = 15 * time.Second //комментарий ": ping", чтобы прокси не закрывали соединение
+	clientBuffer     = 64               //очередь клиента; переполнилась - клиент отключается
+)
+
+// Виды событий ленты
+const (
+	UserCreated        = "user.created"
+	UserDeleted        = "user.deleted"
+	UserRestored       = "user.restored" //дружба с прежними друзьями - следом, событиями friendship.created
+	UserPurged         = "user.purged"   //удаленный (user.deleted) стерт окончательно
+	UserAgeChanged     = "user.age_changed"
+	UserProfileUpdated = "user.profile_updated"
+	FriendshipCreated  = "friendship.created"
+	FriendshipRemoved  = "friendship.removed"
+	Followed           = "follow.created" //user подписался на friend_id (или его заявку одобрили)
+	Unfollowed         = "follow.deleted"
+	//Reset - пропущенные изменения уже вытеснены из памяти (или сервис перезапущен):
+	//клиенту нужно перечитать данные целиком
+	Reset = "reset"
+)
+
+// names - вид события ленты для изменения хранилища
+var names = map[core.EventType]string{
+	core.UserRegistered:   UserCreated,
+	core.UserDeleted:      UserDeleted,
+	core.UserRestored:     UserRestored,
+	core.UserPurged:       UserPurged,
+	core.AgeChanged:       UserAgeChanged, //меняется только возраст
+	core.ProfileUpdated:   UserProfileUpdated,
+	core.FriendshipFormed: FriendshipCreated,
+	core.FriendshipEnded:  FriendshipRemoved,
+	core.Followed:         Followed,
+	core.Unfollowed:       Unfollowed,
+}
+
+// Event - событие ленты (поле data); ID совпадает с полем id события
+type Event struct {
+	ID       uint64    `json:"id"`
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	User     core.User `json:"user"` //для дружбы - инициатор
+	FriendID int       `json:"friend_id,omitempty"`
+}
+
+// Feed - лента изменений: подписка на хранилище, буфер последних изменений и клиенты
+type Feed struct {
+	Heartbeat time.Duration
+
+	store   *core.Store
+	subject func(core.User) string //логин учетной записи пользователя
+	mu      sync.Mutex
+	buf     []Event //кольцевой буфер: изменения без пропусков, последнее - last
+	head    int     //индекс самого старого
+	n       int
+	last    uint64 //номер последнего изменения, известного ленте
+	clients map[*client]struct{}
+}
+
+// client - подключенный клиент
+type client struct {
+	ch    chan Event
+	match filter
+	ctx   context.Context //запрос клиента: по нему - владелец запроса
+}
+
+// New запускает ленту; размер буфера - EVENTS_BUFFER (по умолчанию DefaultSize).
+// subject - логин учетной записи пользователя: email и дата рождения в событиях видны
+// только самому пользователю и с правом users:read
+func New(store *core.Store, subject func(core.User) string) *Feed {
+	size := DefaultSize
+	if v, err := strconv.Atoi(os.Getenv("EVENTS_BUFFER")); err == nil && v > 0 {
+		size = v
+	}
+	f := &Feed{Heartbeat: DefaultHeartbeat, store: store, subject: subject, buf: make([]Event, size),
+		last: store.LastSeq(), clients: make(map[*client]struct{})}
+	go f.run()
+	return f
+}
+
+// run переносит изменения хранилища в ленту. Хранилище отключает отставшего подписчика -
+// тогда лента подписывается заново, а разрыв обнаруживается по номерам изменений.
+func (f *Feed) run() {
+	for {
+		ch, cancel := f.store.Subscribe(len(f.buf))
+		for e := range ch {
+			f.add(e)
+		}
+		cancel()
+	}
+}
+
+// add запоминает изменение и рассылает его клиентам
+func (f *Feed) add(e core.Event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if e.Seq <= f.last {
+		return //уже есть
+	}
+	if e.Seq != f.last+1 { //изменения пропущены: буфер больше не непрерывен
+		f.head, f.n = 0, 0
+		for c := range f.clients { //клиенты переподключатся и получат reset
+			f.drop(c)
+		}
+	}
+	ev := Event{ID: e.Seq, Type: names[e.Type], Time: e.Time, User: e.User, FriendID: e.FriendID}
+	if e.Type.Private() { //блокировки клиентам не отправляются: в буфере остается только номер
+		ev = Event{ID: e.Seq}
+	}
+	if f.n < len(f.buf) {
+		f.buf[(f.head+f.n)%len(f.buf)] = ev
+		f.n++
+	} else {
+		f.buf[f.head] = ev
+		f.head = (f.head + 1) % len(f.buf)
+	}
+	f.last = e.Seq
+	if e.Type == core.UserPurged {
+		f.redact(e.User.ID)
+	}
+	for c := range f.clients {
+		out, ok := f.view(c, ev)
+		if !ok {
+			continue
+		}
+		select {
+		case c.ch <- out:
+		default: //клиент не успевает - отключаем, он продолжит с Last-Event-ID
+			f.drop(c)
+		}
+	}
+}
+
+// view - событие для клиента c; false - не подходит под его отбор или участник события
+// заблокировал владельца запроса (как при чтении, заблокировавшие скрыты и из друзей).
+// Вызывается под f.mu.
+func (f *Feed) view(c *client, e Event) (Event, bool) {
+	if !c.match.ok(e) {
+		return e, false
+	}
+	hidden := f.store.HiddenFrom(c.ctx)
+	if hidden[e.User.ID] || hidden[e.FriendID] {
+		return e, false
+	}
+	e.User = core.Strip(e.User, hidden)
+	if auth.Authorize(c.ctx, f.subject(e.User), auth.PermUsersRead) != nil {
+		e.User = e.User.Public()
+	}
+	return e, true
+}
+
+// redact оставляет в буфере от окончательно удаленного только ID - как в журнале хранилища.
+// Вызывается под f.mu.
+func (f *Feed) redact(id int) {
+	for i := range f.n {
+		if ev := &f.buf[(f.head+i)%len(f.buf)]; ev.User.ID == id {
+			ev.User = core.User{ID: id, Friends: []int{}}
+		}
+	}
+}
+
+// since - изменения после номера seq из буфера; false - часть из них уже вытеснена
+// (или seq из будущего - например, до перезапуска сервиса). Вызывается под f.mu.
+func (f *Feed) since(seq uint64) ([]Event, bool) {
+	oldest := f.last - uint64(f.n) + 1
+	if seq > f.last || seq+1 < oldest {
+		return nil, false
+	}
+	var out []Event
+	for i := int(seq + 1 - oldest); i < f.n; i++ {
+		out = append(out, f.buf[(f.head+i)%len(f.buf)])
+	}
+	return out, true
+}
+
+// subscribe регистрирует клиента и возвращает изменения после lastID (resume - клиент
+// передал Last-Event-ID); регистрация и выборка из буфера атомарны - без пропусков и повторов.
+// reset - часть изменений потеряна, тогда last - номер, с которого клиент продолжит.
+func (f *Feed) subscribe(ctx context.Context, match filter, lastID uint64, resume bool) (c *client, replay []Event, reset bool, last uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c = &client{ch: make(chan Event, clientBuffer), match: match, ctx: ctx}
+	f.clients[c] = struct{}{}
+	if !resume {
+		return c, nil, false, f.last
+	}
+	events, complete := f.since(lastID)
+	for _, e := range events {
+		if e, ok := f.view(c, e); ok {
+			replay = append(replay, e)
+		}
+	}
+	return c, replay, !complete, f.last
+}
+
+// unsubscribe - клиент отключился
+func (f *Feed) unsubscribe(c *client) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.clients, c) //после drop клиента в списке уже нет
+}
+
+// drop отключает клиента: его канал закрывается. Вызывается под f.mu.
+func (f *Feed) drop(c *client) {
+	delete(f.clients, c)
+	close(c.ch)
+}
@@ -0,0 +1,123 @@
+package feed
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"Network-exchange/logging"
+
+	"github.com/gin-contrib/sse"
+)
+
+// filter - отбор событий для клиента; пустые списки не ограничивают
+type filter struct {
+	users []int    //пользователь - участник события (сам пользователь или его друг)
+	types []string //виды событий
+}
+
+func (m filter) ok(e Event) bool {
+	if e.Type == "" { //скрытое изменение
+		return false
+	}
+	if len(m.types) > 0 && !slices.Contains(m.types, e.Type) {
+		return false
+	}
+	return len(m.users) == 0 || slices.Contains(m.users, e.User.ID) ||
+		e.FriendID != 0 && slices.Contains(m.users, e.FriendID)
+}
+
+// parseFilter читает ?user_id=1,2&types=user.created,friendship.created
+// (параметры можно и повторять)
+func parseFilter(r *http.Request) (filter, string) {
+	var m filter
+	q := r.URL.Query()
+	for _, v := range split(q["user_id"]) {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			return m, "user_id - ID пользователей через запятую"
+		}
+		m.users = append(m.users, id)
+	}
+	for _, v := range split(q["types"]) {
+		if !slices.Contains([]string{UserCreated, UserDeleted, UserRestored, UserPurged, UserAgeChanged, UserProfileUpdated, FriendshipCreated, FriendshipRemoved, Followed, Unfollowed}, v) {
+			return m, "неизвестный вид события: " + v
+		}
+		m.types = append(m.types, v)
+	}
+	return m, ""
+}
+
+func split(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+// ServeHTTP - GET /events?user_id=&types=: поток text/event-stream. Каждое событие -
+// id (номер изменения), event (вид) и data (Event в JSON). С заголовком Last-Event-ID
+// сначала отправляются пропущенные события, а если они уже вытеснены - событие reset.
+func (f *Feed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	match, msg := parseFilter(r)
+	if msg != "" {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": msg, "request_id": logging.RequestID(r.Context())})
+		return
+	}
+	lastID, err := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+	resume := err == nil
+
+	rc := http.NewResponseController(w)
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no") //nginx: не накапливать ответ
+	w.WriteHeader(http.StatusOK)
+
+	c, replay, reset, last := f.subscribe(r.Context(), match, lastID, resume)
+	defer f.unsubscribe(c)
+	w.Write([]byte("retry: 3000\n\n")) //переподключение через 3 с
+	if reset {
+		//id - чтобы следующее переподключение продолжило уже отсюда
+		sse.Encode(w, sse.Event{Id: strconv.FormatUint(last, 10), Event: Reset, Data: map[string]uint64{"last_id": last}})
+	}
+	for _, e := range replay {
+		write(w, e)
+	}
+	if rc.Flush() != nil {
+		return //ответ не передается потоком
+	}
+
+	heartbeat := time.NewTicker(f.Heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			w.Write([]byte(": ping\n\n"))
+		case e, ok := <-c.ch:
+			if !ok {
+				return //отстал или лента прервалась: клиент переподключится с Last-Event-ID
+			}
+			write(w, e)
+		}
+		if rc.Flush() != nil {
+			return
+		}
+	}
+}
+
+func write(w http.ResponseWriter, e Event) {
+	sse.Encode(w, sse.Event{Id: strconv.FormatUint(e.ID, 10), Event: e.Type, Data: e})
+}
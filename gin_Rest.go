@@ -1,3 +1,7 @@
+//go:build gin
+
+// Сервис на "Джин": go run gin_Rest.go (тег gin нужен только для go test и go vet пакета).
+
 /*	30.5 Практическая работа: написать HTTP-сервис (с JSON-данными)
 	Обработчики:
 	1. создания пользователя
@@ -14,55 +18,226 @@
 package main
 
 import (
+	"context"
 	"errors"
-	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
 	"strconv"
 
+	"Network-exchange/apiv2"
+	"Network-exchange/audit"
+	"Network-exchange/auth"
+	"Network-exchange/core"
+	"Network-exchange/feed"
+	"Network-exchange/graphqlapi"
+	"Network-exchange/grpcapi"
+	"Network-exchange/logging"
+	"Network-exchange/notify"
+	"Network-exchange/openapi"
+	"Network-exchange/ratelimit"
+	"Network-exchange/retention"
+	"Network-exchange/secure"
+	"Network-exchange/tlsconf"
+	"Network-exchange/tracing"
+	"Network-exchange/versioning"
+	"Network-exchange/webhook"
+
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10" //Пакет предлагает несколько тегов для сравнения
 )
 
 // user представляет данные о пользователе.
 type User struct {
-	Name    string   `json:"name" binding:"required"` //тег требует обязательное заполнение
-	Age     int      `json:"age" binding:"min=18"`    //тег ограничивает минимальный возраст
+	Name    string   `json:"name" binding:"required,excludes=:"` //тег требует обязательное заполнение (без двоеточия)
+	Age     int      `json:"age" binding:"min=18"`               //тег ограничивает минимальный возраст
 	Friends []string `json:"friends"`
 }
 
+// запрос на создание пользователя: данные пользователя и пароль для входа (не обязателен)
+type newUserRequest struct {
+	User
+	Password string `json:"password" binding:"omitempty,min=8"` //пароль хранится только в виде хеша
+}
+
 var (
 	newUser User
-	users   []User
+	store   = core.NewStore(true) //пользователи и дружба (общие для /v1 и /v2); имена уникальны
 	userId  int
 	err     error
 
 	validErr validator.ValidationErrors
+
+	authService *auth.Service //учетные записи и JWT
 )
 
 func main() {
 	//flag.Parse()
-	router := gin.Default()
-	//доверенный IP-адрес клиента (желателен для безопасности)
-	router.SetTrustedProxies([]string{"127.0.0.1"})
+	logging.Setup(os.Stdout) //структурированный журнал в формате JSON
+	//трассировка OpenTelemetry (экспорт задается переменной OTEL_TRACES_EXPORTER)
+	shutdown, err := tracing.Setup(context.Background(), "network-exchange-gin")
+	if err != nil {
+		slog.Error("трассировка не настроена", "error", err)
+		os.Exit(1)
+	}
+	defer shutdown(context.Background())
+	//учетные записи (JWT_SECRET - ключ подписи, ADMIN_PASSWORD - пароль "admin")
+	authService = auth.FromEnv("network-exchange-gin")
+	//дополнительные поля профиля: PROFILE_FIELDS="company=string,shoe_size=integer,team=red|blue"
+	fields, err := core.FieldsFromEnv()
+	if err != nil {
+		slog.Error("поля профиля объявлены с ошибкой", "error", err)
+		os.Exit(1)
+	}
+	store.DeclareFields(fields)
+	//владелец запроса среди пользователей: от него скрыты заблокировавшие его
+	store.SetViewer(func(ctx context.Context, u core.User) bool {
+		id, ok := auth.FromContext(ctx)
+		return ok && id.Subject == u.Name
+	})
 	//создаем начальную базу пользователей (не обязательна)
-	users = []User{
-		{Name: "Monika", Age: 25, Friends: []string{}},
-		{Name: "Barby", Age: 35, Friends: []string{}},
+	for _, user := range []User{{Name: "Monika", Age: 25}, {Name: "Barby", Age: 35}} {
+		repoCreateUser(context.Background(), user, nil)
+	}
+	router, spec, limiter := newRouter(fields)
+	//маршрут без описания (или описание без маршрута) - ошибка запуска
+	if err := spec.CheckRoutes(openapi.GinRoutes(router)); err != nil {
+		slog.Error("описание API устарело", "error", err)
+		os.Exit(1)
+	}
+
+	//gRPC (netx.v1.UserService, netx.v1.FriendshipService) - на том же порту по HTTP/2
+	grpcAPI := grpcapi.New(store, authService, func(u core.User) string { return u.Name })
+	handler := grpcAPI.Handler(grpcAPI.Server(limiter), router)
+	//$ grpcurl -plaintext -d '{"min_age":18}' localhost:8080 netx.v1.UserService/ListUsers
+
+	//слушает ":8080" по HTTP (и h2c), а при заданных TLS_CERT_FILE и TLS_KEY_FILE - по HTTPS
+	//(TLS_CLIENT_CA_FILE включает проверку сертификатов клиентов)
+	if err := tlsconf.ListenAndServe(":8080", handler); err != nil {
+		slog.Error("сервер остановлен", "error", err)
 	}
 
-	router.GET("/users", getUsers)                 // http://localhost:8080/users
-	router.GET("/users/name/:name", getUserByName) // http://localhost:8080/users/name/Barby
-	router.GET("/users/id/:id", getUserByID)       // http://localhost:8080/users/id/2
-	router.GET("/friends/:name", getFriends)       // http://localhost:8080/friends/Barby
+}
 
-	router.POST("/users", postUsers)                       //$ curl -X POST -i http://localhost:8080/users -H "content-type: application/json" -d "{\"name\":\"Willy\",\"age\":33,\"friends\":[]}"
-	router.PUT("/friends", putFriends)                     //$ curl -X PUT -i http://localhost:8080/friends -H "content-type: application/json" -d "{\"source\":\"Monika\",\"target\":\"Barby\"}"
-	router.DELETE("/users/delete/:name", deleteUserByName) //$ curl -X DELETE -i http://localhost:8080/users/delete/Barby
-	router.PUT("/users/:id", putAge)                       //$ curl -X PUT -H "content-type: application/json" -d "22" -i http://localhost:8080/users/2
+// newRouter собирает маршрутизатор со всеми версиями API и служебными маршрутами; вместе
+// с ним - описание API (по нему проверяются маршруты) и ограничитель частоты запросов,
+// общий с gRPC
+func newRouter(fields []core.Field) (*gin.Engine, *openapi.Document, *ratelimit.Limiter) {
+	//ограничение частоты запросов: по ключу API, пользователю или IP клиента
+	//CORS и заголовки безопасности (CORS_ALLOWED_ORIGINS и др.), размер тела запроса (MAX_BODY_BYTES)
+	protection := secure.FromEnv()
+	limiter := ratelimit.New(ratelimit.PerMinute(120), map[string]ratelimit.Limit{
+		"POST /users":                         ratelimit.PerMinute(5), //создание пользователей
+		"POST /v1/users":                      ratelimit.PerMinute(5),
+		"POST /v2/users":                      ratelimit.PerMinute(5),
+		"PUT /friends":                        ratelimit.PerMinute(10), //запросы дружбы
+		"PUT /v1/friends":                     ratelimit.PerMinute(10),
+		"PUT /v2/users/:id/friends/:friendId": ratelimit.PerMinute(10),
+		"POST /login":                         ratelimit.PerMinute(10), //подбор паролей
+		"POST /graphql":                       ratelimit.PerMinute(30), //изменения и тяжелые запросы GraphQL
+		//вызовы gRPC: "GRPC /служба/метод"
+		"GRPC /netx.v1.UserService/CreateUser":      ratelimit.PerMinute(5),
+		"GRPC /netx.v1.FriendshipService/AddFriend": ratelimit.PerMinute(10),
+	})
+	router := gin.New()
+	//восстановление после паники, спаны запросов, журнал с X-Request-ID, CORS, проверка JWT и лимиты
+	//описание API: по нему проверяются параметры и тела запросов (OPENAPI_VALIDATE_RESPONSES=true - и ответы)
+	spec := openapi.GinSpec()
+	openapi.ProfileFields(spec, fields)
+	router.Use(gin.Recovery(), tracing.Gin(), logging.Gin(), protection.Gin(), authService.Gin(), limiter.Gin(),
+		openapi.ValidatorFromEnv(spec).Gin())
+	//доверенный IP-адрес клиента (желателен для безопасности)
+	router.SetTrustedProxies([]string{"127.0.0.1"})
 
-	//По умолчанию слушает "localhost:8080")
-	router.Run()
+	router.POST("/login", gin.WrapF(authService.LoginHandler))           //$ curl -X POST -i http://localhost:8080/login -d "{\"login\":\"Willy\",\"password\":\"secret123\"}"
+	router.POST("/token/refresh", gin.WrapF(authService.RefreshHandler)) //$ curl -X POST -i http://localhost:8080/token/refresh -d "{\"refresh_token\":\"...\"}"
+	router.POST("/logout", gin.WrapF(authService.LogoutHandler))         //$ curl -X POST -i http://localhost:8080/logout -d "{\"refresh_token\":\"...\"}"
 
+	//первая версия: прежние адреса и они же под /v1 (устарели: заголовки Deprecation, Sunset и Link на /v2)
+	v1 := versioning.FromEnv(apiv2.Prefix+"/users", "/docs")
+	registerV1(router.Group("", v1.Gin()))
+	registerV1(router.Group("/v1", v1.Gin()))
+	//вторая версия: пользователи по ID, ошибки problem+json (логин учетной записи - имя)
+	apiv2.New(store, authService, func(u core.User) string { return u.Name }).Gin(router.Group(apiv2.Prefix))
+	//$ go run ./cmd/netx -server http://localhost:8080 users list -name bar -limit 10
+	//$ go run ./cmd/netx -server http://localhost:8080 -login Monika -password ... friends add 1 2
+	//GraphQL: пользователи с друзьями любой вложенности и изменения (GET - только запросы)
+	gql := graphqlapi.New(store, authService, func(u core.User) string { return u.Name })
+	router.GET(graphqlapi.Path, gin.WrapH(gql))
+	router.POST(graphqlapi.Path, gin.WrapH(gql))
+	//$ curl -i http://localhost:8080/graphql -d "{\"query\":\"{ user(id: 1) { name friends { name friends { name } } } }\"}"
+	//лента изменений вместо опроса GET /users (Server-Sent Events, продолжение по Last-Event-ID)
+	router.GET(feed.Path, gin.WrapH(feed.New(store, func(u core.User) string { return u.Name })))
+	//$ curl -N http://localhost:8080/events?user_id=1 -H "Last-Event-ID: 5"
+	//уведомления о дружбе для вошедшего пользователя (WebSocket)
+	router.GET(notify.Path, gin.WrapH(notify.New(store, authService, func(u core.User) string { return u.Name })))
+	//$ websocat "ws://localhost:8080/ws/notifications?access_token=$TOKEN"
+
+	//служебные маршруты: права проверяются по роли (admin - все, auditor - только чтение)
+	admin := router.Group("/admin")
+	admin.GET("/users", auth.RequireGin(auth.PermUsersRead), adminGetUsers)                          //$ curl -i http://localhost:8080/admin/users -H "Authorization: Bearer $TOKEN"
+	admin.PATCH("/users/:name", auth.RequireGin(auth.PermUsersWrite), adminPatchUser)                //$ curl -X PATCH -i http://localhost:8080/admin/users/Barby -H "Authorization: Bearer $TOKEN" -d "{\"age\":40,\"role\":\"auditor\"}"
+	admin.POST("/users/:name/suspend", auth.RequireGin(auth.PermUsersSuspend), adminSuspendUser)     //$ curl -X POST -i http://localhost:8080/admin/users/Barby/suspend -H "Authorization: Bearer $TOKEN"
+	admin.POST("/users/:name/reinstate", auth.RequireGin(auth.PermUsersSuspend), adminReinstateUser) //$ curl -X POST -i http://localhost:8080/admin/users/Barby/reinstate -H "Authorization: Bearer $TOKEN"
+	admin.DELETE("/users/:name", auth.RequireGin(auth.PermUsersDelete), deleteUserByName)            //$ curl -X DELETE -i http://localhost:8080/admin/users/Barby -H "Authorization: Bearer $TOKEN"
+
+	//удаленные: восстановление в течение DELETE_RETENTION, затем фоновая очистка
+	trash := retention.New(store, authService, func(u core.User) string { return u.Name })
+	admin.GET("/deleted-users", auth.RequireGin(auth.PermUsersRead), gin.WrapF(trash.Handler)) //$ curl -i http://localhost:8080/admin/deleted-users -H "Authorization: Bearer $TOKEN"
+	admin.POST("/deleted-users/:userId/restore", auth.RequireGin(auth.PermUsersDelete), func(c *gin.Context) {
+		trash.ServeRestore(c.Writer, c.Request, c.Param("userId"))
+	}) //$ curl -X POST -i http://localhost:8080/admin/deleted-users/2/restore -H "Authorization: Bearer $TOKEN"
+	admin.DELETE("/deleted-users/:userId", auth.RequireGin(auth.PermUsersDelete), func(c *gin.Context) {
+		trash.ServeErase(c.Writer, c.Request, c.Param("userId"))
+	})
+
+	//ключи API для межсервисных клиентов: Authorization: Bearer <key> или X-API-Key: <key>
+	admin.GET("/apikeys", auth.RequireGin(auth.PermKeysManage), gin.WrapF(authService.KeysHandler))  //$ curl -i http://localhost:8080/admin/apikeys -H "Authorization: Bearer $TOKEN"
+	admin.POST("/apikeys", auth.RequireGin(auth.PermKeysManage), gin.WrapF(authService.KeysHandler)) //$ curl -X POST -i http://localhost:8080/admin/apikeys -H "Authorization: Bearer $TOKEN" -d "{\"name\":\"batch\",\"scope\":\"read\",\"expires_in_days\":30}"
+	admin.DELETE("/apikeys/:id", auth.RequireGin(auth.PermKeysManage), func(c *gin.Context) {        //$ curl -X DELETE -i http://localhost:8080/admin/apikeys/<id> -H "Authorization: Bearer $TOKEN"
+		authService.ServeRevokeKey(c.Writer, c.Request, c.Param("id"))
+	})
+
+	//журнал аудита: кто, когда и откуда изменил пользователей (admin, auditor)
+	auditLog := audit.New(store)
+	admin.GET("/audit", auth.RequireGin(auth.PermAuditRead), gin.WrapF(auditLog.Handler)) //$ curl -i "http://localhost:8080/admin/audit?user_id=2&from=2026-10-01T00:00:00Z" -H "Authorization: Bearer $TOKEN"
+	admin.GET("/audit/export", auth.RequireGin(auth.PermAuditRead), gin.WrapF(auditLog.ExportHandler))
+	//$ curl -o audit.ndjson "http://localhost:8080/admin/audit/export?actor=admin" -H "Authorization: Bearer $TOKEN"
+
+	//вебхуки: POST на адрес подписки при создании и удалении пользователей (подпись - X-Webhook-Signature)
+	hooks := webhook.New(store)
+	admin.GET("/webhooks", auth.RequireGin(auth.PermHooksManage), gin.WrapF(hooks.SubscriptionsHandler))
+	admin.POST("/webhooks", auth.RequireGin(auth.PermHooksManage), gin.WrapF(hooks.SubscriptionsHandler))
+	//$ curl -X POST -i http://localhost:8080/admin/webhooks -H "Authorization: Bearer $TOKEN" -d "{\"url\":\"https://partner.example.com/hook\",\"events\":[\"user.created\",\"user.deleted\"]}"
+	admin.GET("/webhooks/dead", auth.RequireGin(auth.PermHooksManage), gin.WrapF(hooks.DeadHandler))
+	admin.POST("/webhooks/dead/:deliveryId/redeliver", auth.RequireGin(auth.PermHooksManage), func(c *gin.Context) {
+		hooks.ServeRedeliver(c.Writer, c.Request, c.Param("deliveryId"))
+	})
+	serveHook := func(c *gin.Context) { hooks.ServeSubscription(c.Writer, c.Request, c.Param("webhookId")) }
+	admin.GET("/webhooks/:webhookId", auth.RequireGin(auth.PermHooksManage), serveHook)
+	admin.DELETE("/webhooks/:webhookId", auth.RequireGin(auth.PermHooksManage), serveHook)
+	admin.GET("/webhooks/:webhookId/deliveries", auth.RequireGin(auth.PermHooksManage), func(c *gin.Context) {
+		hooks.ServeDeliveries(c.Writer, c.Request, c.Param("webhookId"))
+	})
+	//$ curl -i http://localhost:8080/admin/webhooks/<id>/deliveries -H "Authorization: Bearer $TOKEN"
+
+	//документация: описание OpenAPI и страница Swagger UI
+	router.GET("/openapi.json", gin.WrapF(spec.Handler()))          // http://localhost:8080/openapi.json
+	router.GET("/docs", gin.WrapF(spec.UIHandler("/openapi.json"))) // http://localhost:8080/docs
+	return router, spec, limiter
+}
+
+// маршруты первой версии (без префикса и с префиксом /v1)
+func registerV1(g *gin.RouterGroup) {
+	g.GET("/users", getUsers)                 // http://localhost:8080/users
+	g.GET("/users/name/:name", getUserByName) // http://localhost:8080/users/name/Barby
+	g.GET("/users/id/:id", getUserByID)       // http://localhost:8080/users/id/2
+	g.GET("/friends/:name", getFriends)       // http://localhost:8080/friends/Barby
+
+	g.POST("/users", postUsers)                       //$ curl -X POST -i http://localhost:8080/users -H "content-type: application/json" -d "{\"name\":\"Willy\",\"age\":33,\"friends\":[],\"password\":\"secret123\"}"
+	g.PUT("/friends", putFriends)                     //$ curl -X PUT -i http://localhost:8080/friends -H "content-type: application/json" -d "{\"source\":\"Monika\",\"target\":\"Barby\"}"
+	g.DELETE("/users/delete/:name", deleteUserByName) //$ curl -X DELETE -i http://localhost:8080/users/delete/Barby
+	g.PUT("/users/:id", putAge)                       //$ curl -X PUT -H "content-type: application/json" -d "22" -i http://localhost:8080/users/2
 }
 
 // ПОМОЩНИКИ:
@@ -83,39 +258,155 @@ func getErrorMessage(fe validator.FieldError) string {
 	return "Неизвестная ошибка"
 }
 
+// проверка права действовать от имени пользователя (сам пользователь или роль с правом perm);
+// при отказе отвечает 401/403 и прерывает обработку
+func authorize(c *gin.Context, name string, perm auth.Permission) bool {
+	if err := auth.Authorize(c.Request.Context(), name, perm); err != nil {
+		auth.Deny(c.Writer, c.Request, err)
+		c.Abort()
+		return false
+	}
+	return true
+}
+
+// тело запроса больше допустимого: отвечает 413 и прерывает обработку
+func bodyTooLarge(c *gin.Context, err error) bool {
+	if !secure.TooLarge(err) {
+		return false
+	}
+	secure.WriteTooLarge(c.Writer, c.Request)
+	c.Abort()
+	return true
+}
+
+// ID текущего запроса для тела ответа с ошибкой
+func requestID(c *gin.Context) string {
+	return logging.RequestID(c.Request.Context())
+}
+
+// ХРАНИЛИЩЕ (core.Store; каждая операция - дочерний спан трассировки):
+// пользователь первой версии: друзья - имена
+func view(user core.User) User {
+	names := store.Names(user.Friends)
+	friends := make([]string, 0, len(user.Friends))
+	for _, id := range user.Friends {
+		friends = append(friends, names[id])
+	}
+	return User{Name: user.Name, Age: user.Age, Friends: friends}
+}
+
+// все пользователи в порядке добавления (порядковый номер - индекс + 1)
+func repoUsers(ctx context.Context) []User {
+	all := store.All(ctx)
+	out := make([]User, len(all))
+	for i, user := range all {
+		out[i] = view(user)
+	}
+	return out
+}
+
 // поиск пользователя по его имени
-func repoFindUser(name string) User { //находим пользователя по его ID
-	for _, user := range users {
-		if user.Name == name { //если есть совпадение с ID
-			return user //возвращаем пользователя
+func repoFindUser(ctx context.Context, name string) User {
+	user, err := store.ByName(ctx, name)
+	if err != nil {
+		return User{} //если ничего нет возвращаем пустого пользователя
+	}
+	return view(user)
+}
+
+// добавить нового пользователя; перечисленные в Friends существующие пользователи становятся друзьями,
+// account (если задан) занимает учетную запись до появления пользователя
+func repoCreateUser(ctx context.Context, user User, account core.Reserve) (User, error) {
+	created, err := store.Create(ctx, user.Name, user.Age, account)
+	if err != nil {
+		return User{}, err
+	}
+	for _, name := range user.Friends {
+		if friend, err := store.ByName(ctx, name); err == nil {
+			store.Befriend(ctx, created.ID, friend.ID)
 		}
 	}
-	return User{} //если ничего нет возвращаем пустой слайс пользователя
+	return repoFindUser(ctx, user.Name), nil
+}
+
+// делаем пользователей друзьями (ошибки - core.ErrNotFound, core.ErrAlreadyFriends, core.ErrSelfFriend, core.ErrBlocked)
+func repoMakeFriends(ctx context.Context, sourceName, targetName string) error {
+	source, err := store.ByName(ctx, sourceName)
+	if err != nil {
+		return err
+	}
+	target, err := store.ByName(ctx, targetName)
+	if err != nil {
+		return err
+	}
+	return store.Befriend(ctx, source.ID, target.ID)
+}
+
+// обновляем возраст пользователя с тем же именем (core.ErrAgeDerived - возраст задан датой рождения)
+func repoUpdateUser(ctx context.Context, user User) error {
+	found, err := store.ByName(ctx, user.Name)
+	if err != nil {
+		return err
+	}
+	_, err = store.SetAge(ctx, found.ID, user.Age)
+	return err
+}
+
+// помечаем пользователя удаленным и стираем его из друзей остальных; false - пользователя нет
+func repoDeleteUser(ctx context.Context, name string) bool {
+	user, err := store.ByName(ctx, name)
+	if err != nil {
+		return false
+	}
+	_, err = store.Delete(ctx, user.ID)
+	return err == nil
 }
 
 // ОБРАБОЧИКИ:
 // 1. добавляет пользователя из тела запроса
 func postUsers(c *gin.Context) {
+	var req newUserRequest
 	// валидация данных запроса
-	if err := c.ShouldBindJSON(&newUser); err != nil { // метод получает JSON и пишет в var
-		errors.As(err, &validErr)
+	if err := c.ShouldBindJSON(&req); err != nil { // метод получает JSON и пишет в var
+		if bodyTooLarge(c, err) {
+			return
+		}
+		if !errors.As(err, &validErr) { //не JSON или неизвестное поле
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error(), "request_id": requestID(c)}) //ошибка (400)
+			return
+		}
 		out := make([]ErrorMessage, len(validErr))
 
 		for i, fe := range validErr {
 			out[i] = ErrorMessage{fe.Field(), getErrorMessage(fe)} // формирование ответа при несоответствии
 		}
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": out}) //ошибка (400)
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": out, "request_id": requestID(c)}) //ошибка (400)
 		return
 	}
 
+	newUser = req.User
+	logging.SetUser(c.Request.Context(), newUser.Name)
 	//проверяем наличие пользователей в базе
-	userToAdd := repoFindUser(newUser.Name)
+	userToAdd := repoFindUser(c.Request.Context(), newUser.Name)
 	if userToAdd.Name == newUser.Name {
 		c.String(http.StatusForbidden, "Упс! Кто-то уже в базе") //(403)
 		return
 	}
-	// добавить нового пользователя в срез.
-	users = append(users, newUser)
+	// учетная запись для входа: логин - имя пользователя; занимается вместе с созданием пользователя
+	var account core.Reserve
+	if req.Password != "" {
+		pending, err := authService.Prepare(req.Password, auth.RoleUser)
+		if err != nil {
+			c.String(http.StatusForbidden, "Упс! %v", err) //(403)
+			return
+		}
+		account = func(u core.User) error { return pending.Register(u.Name) }
+	}
+	// добавить нового пользователя в хранилище
+	if newUser, err = repoCreateUser(c.Request.Context(), newUser, account); err != nil {
+		c.String(http.StatusForbidden, "Упс! %v", err) //(403)
+		return
+	}
 	//Ответ в "cmd" (c.String - формирует развернутый ответ)
 	c.String(http.StatusCreated, "Создан новый пользователь: %s %d лет\n", newUser.Name, newUser.Age)
 	c.IndentedJSON(http.StatusCreated, newUser) //ответ с красивым выводом структуры
@@ -132,43 +423,36 @@ func putFriends(c *gin.Context) {
 		friend     = make(map[string]string, 2)
 	)
 	if err := c.ShouldBindJSON(&friend); err != nil { //получаем данные из запроса
+		if bodyTooLarge(c, err) {
+			return
+		}
 		c.AbortWithError(http.StatusBadRequest, err) //(400)
 		return
 	}
 	// получаем из "мапы" имена друзей
 	sourceName := friend["source"]
 	targetName := friend["target"]
+	logging.SetUser(c.Request.Context(), sourceName)
+	// дружбу предлагает только сам инициатор (или администратор)
+	if !authorize(c, sourceName, auth.PermUsersWrite) {
+		return
+	}
 
 	//проверяем наличие пользователей в базе
-	sourceUser = repoFindUser(sourceName)
-	targetUser = repoFindUser(targetName)
+	sourceUser = repoFindUser(c.Request.Context(), sourceName)
+	targetUser = repoFindUser(c.Request.Context(), targetName)
 	if sourceUser.Name == "" || targetUser.Name == "" {
 		c.String(http.StatusNotFound, "Упс! Кого-то нет в базе") //(404)
 		return
 	}
 
-	// пополняем хранилища (слайсы) друзей
-	for index, user := range users {
-		if user.Name == sourceName {
-			for _, friend := range user.Friends {
-				if friend == targetName {
-					c.String(http.StatusForbidden, "Упс! Уже есть такой ДРУГ :)") //(403)
-					return
-				}
-			}
-			user.Friends = append(user.Friends, targetName) // друзья инициатора
-			users[index] = user                             // обновляем структуру
-		}
-		if user.Name == targetName {
-			for _, friend := range user.Friends {
-				if friend == sourceName {
-					c.String(http.StatusForbidden, "Упс! Уже есть такой ДРУГ :)") //(403)
-					return
-				}
-			}
-			user.Friends = append(user.Friends, sourceName) // друзья принявшего приглашение
-			users[index] = user                             // обновляем структуру
-		}
+	// пополняем списки друзей обоих
+	if err := repoMakeFriends(c.Request.Context(), sourceName, targetName); errors.Is(err, core.ErrSelfFriend) || errors.Is(err, core.ErrBlocked) {
+		c.String(http.StatusForbidden, "Упс! %v", err) //(403)
+		return
+	} else if err != nil {
+		c.String(http.StatusForbidden, "Упс! Уже есть такой ДРУГ :)") //(403)
+		return
 	}
 	c.String(http.StatusCreated, " %v и %v теперь друзья\n", sourceName, targetName)
 	//	c.IndentedJSON(http.StatusCreated, users)
@@ -178,28 +462,21 @@ func putFriends(c *gin.Context) {
 func deleteUserByName(c *gin.Context) {
 	//получаем имя пользователя
 	name := c.Param("name")
+	logging.SetUser(c.Request.Context(), name)
+	// удалить можно только себя (администратор - любого)
+	if !authorize(c, name, auth.PermUsersDelete) {
+		return
+	}
 	//проверяем наличие пользователя в базе
-	userToDelete := repoFindUser(name)
+	userToDelete := repoFindUser(c.Request.Context(), name)
 	if userToDelete.Name == "" {
 		c.String(http.StatusNotFound, "Упс! Кого-то нет в базе") //(404)
 		return
 	}
 
-	for i, user := range users {
-		for j, friend := range user.Friends { //удаляем пользователя из хранилищ друзей
-			if friend == name {
-				user.Friends = append(user.Friends[:j], user.Friends[j+1:]...)
-
-			}
-			users[i] = user
-		}
-	}
-	for i, user := range users {
-		if user.Name == name { //удаляем пользователя
-			//удалить значение текущего индекса (пользователя) и сдвинуть все последующие влево
-			users = append(users[:i], users[i+1:]...)
-			c.String(http.StatusOK, "Пользователь %v удален", user.Name)
-		}
+	if repoDeleteUser(c.Request.Context(), name) {
+		authService.Suspend(name) //учетная запись заблокирована до восстановления или очистки
+		c.String(http.StatusOK, "Пользователь %v удален", userToDelete.Name)
 	}
 }
 
@@ -211,11 +488,10 @@ func deleteUserByName(c *gin.Context) {
 // 4. возвращает всех друзей пользователя
 func getFriends(c *gin.Context) {
 	name := c.Param("name")
-	for _, user := range users { // поиск пользователя по имени
-		if user.Name == name {
-			c.IndentedJSON(http.StatusOK, user.Friends)
-			return
-		}
+	logging.SetUser(c.Request.Context(), name)
+	if user := repoFindUser(c.Request.Context(), name); user.Name != "" { // поиск пользователя по имени
+		c.IndentedJSON(http.StatusOK, user.Friends)
+		return
 	}
 	c.String(http.StatusNotFound, "пользователь %v не найден. Введите имя", name)
 }
@@ -225,20 +501,31 @@ func putAge(c *gin.Context) {
 
 	var newAge int
 	id := c.Param("id")
+	logging.SetUser(c.Request.Context(), id)
 	if userId, err = strconv.Atoi(id); err != nil { //принимаем "строковое" число - возвращем целое
-		fmt.Printf("ошибка синтаксиса, получен 'ID' = %v \n", id)
+		logging.FromContext(c.Request.Context()).Warn("ошибка синтаксиса ID", "id", id)
 	}
 	if err := c.ShouldBindJSON(&newAge); err != nil { //получаем значение из JSON
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}) //(400)
+		if bodyTooLarge(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "request_id": requestID(c)}) //(400)
 		return
 	}
-	for i, user := range users {
+	for i, user := range repoUsers(c.Request.Context()) {
 		if newAge < 18 {
-			c.JSON(http.StatusForbidden, gin.H{"error": "ограничение в доступе для клиента"}) // (403)
+			c.JSON(http.StatusForbidden, gin.H{"error": "ограничение в доступе для клиента", "request_id": requestID(c)}) // (403)
 			return
 		} else if i == userId-1 {
+			// изменить возраст можно только себе (администратор - любому)
+			if !authorize(c, user.Name, auth.PermUsersWrite) {
+				return
+			}
 			user.Age = newAge
-			users[i] = user
+			if err := repoUpdateUser(c.Request.Context(), user); errors.Is(err, core.ErrAgeDerived) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "request_id": requestID(c)}) //(409)
+				return
+			}
 			c.String(http.StatusOK, "Возраст пользователя: %s изменен на %d лет\n", user.Name, user.Age)
 			c.IndentedJSON(http.StatusOK, user) //(200)
 			return
@@ -250,18 +537,17 @@ func putAge(c *gin.Context) {
 // Дополнительные обработчики:
 // 1. отвечает списком всех пользователей в формате JSON
 func getUsers(c *gin.Context) { //используется для получения запроса JSON
-	c.IndentedJSON(http.StatusOK, users) //вывод блоками
+	c.IndentedJSON(http.StatusOK, repoUsers(c.Request.Context())) //вывод блоками
 	//c.JSON(http.StatusOK, users) //вывод в строку
 }
 
 // 2. показывает пользователя по "Name"
 func getUserByName(c *gin.Context) {
 	name := c.Param("name")
-	for _, us := range users { // поиск пользователя по имени
-		if us.Name == name {
-			c.IndentedJSON(http.StatusOK, us)
-			return
-		}
+	logging.SetUser(c.Request.Context(), name)
+	if us := repoFindUser(c.Request.Context(), name); us.Name != "" { // поиск пользователя по имени
+		c.IndentedJSON(http.StatusOK, us)
+		return
 	}
 	c.String(http.StatusNotFound, "пользователь %v не найден. Введите имя", name)
 }
@@ -269,15 +555,120 @@ func getUserByName(c *gin.Context) {
 // 3. показывает пользователя по порядковому номеру "id"
 func getUserByID(c *gin.Context) {
 	id := c.Param("id")
+	logging.SetUser(c.Request.Context(), id)
 	if userId, err = strconv.Atoi(id); err != nil { //принимаем "строковое" число - возвращем целое
 		c.String(http.StatusBadRequest, "ошибка синтаксиса, получен 'ID' = %v \n", id)
 		return
 	}
-	for i, us := range users { // поиск пользователя по "id"
+	for i, us := range repoUsers(c.Request.Context()) { // поиск пользователя по "id"
 		if i == userId-1 {
 			c.IndentedJSON(http.StatusOK, us)
 			return
 		}
 	}
-	c.IndentedJSON(http.StatusNotFound, gin.H{"Упс": "пользователь не найден"})
+	c.IndentedJSON(http.StatusNotFound, gin.H{"Упс": "пользователь не найден", "request_id": requestID(c)})
+}
+
+// Служебные обработчики (роли admin и auditor):
+// пользователь вместе с ролью и блокировкой его учетной записи
+type adminUserView struct {
+	User
+	Role      auth.Role `json:"role"`
+	Suspended bool      `json:"suspended"`
+}
+
+func adminView(user User) adminUserView {
+	view := adminUserView{User: user, Role: auth.RoleUser}
+	if acc, ok := authService.Account(user.Name); ok {
+		view.Role, view.Suspended = acc.Role, acc.Suspended
+	}
+	return view
+}
+
+// 1. список всех пользователей со статусом учетных записей
+func adminGetUsers(c *gin.Context) {
+	all := repoUsers(c.Request.Context())
+	out := make([]adminUserView, len(all))
+	for i, user := range all {
+		out[i] = adminView(user)
+	}
+	c.IndentedJSON(http.StatusOK, out)
+}
+
+// 2. изменяет возраст и (при праве roles:manage) роль любого пользователя
+func adminPatchUser(c *gin.Context) {
+	var patch struct {
+		Age  *int       `json:"age" binding:"omitempty,min=18"`
+		Role *auth.Role `json:"role"`
+	}
+	name := c.Param("name")
+	logging.SetUser(c.Request.Context(), name)
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		if bodyTooLarge(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "request_id": requestID(c)}) //(400)
+		return
+	}
+	user := repoFindUser(c.Request.Context(), name)
+	if user.Name == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "пользователь не найден", "request_id": requestID(c)}) //(404)
+		return
+	}
+	//права и роль проверяются до изменений: при ошибке не меняется ничего
+	if patch.Role != nil {
+		if err := auth.Check(c.Request.Context(), auth.PermRolesManage); err != nil {
+			auth.Deny(c.Writer, c.Request, err)
+			return
+		}
+		if err := auth.CheckRole(*patch.Role); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "request_id": requestID(c)}) //(400)
+			return
+		}
+	}
+	if patch.Age != nil {
+		user.Age = *patch.Age
+		switch err := repoUpdateUser(c.Request.Context(), user); {
+		case err == nil:
+		case errors.Is(err, core.ErrAgeDerived):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "request_id": requestID(c)}) //(409)
+			return
+		case errors.Is(err, core.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "пользователь не найден", "request_id": requestID(c)}) //(404)
+			return
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "request_id": requestID(c)}) //(400)
+			return
+		}
+	}
+	if patch.Role != nil {
+		authService.SetRole(name, *patch.Role) //роль уже проверена
+	}
+	c.IndentedJSON(http.StatusOK, adminView(user))
+}
+
+// 3. блокирует пользователя: вход и выданные токены перестают действовать
+func adminSuspendUser(c *gin.Context) {
+	name := c.Param("name")
+	logging.SetUser(c.Request.Context(), name)
+	user := repoFindUser(c.Request.Context(), name)
+	if user.Name == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "пользователь не найден", "request_id": requestID(c)}) //(404)
+		return
+	}
+	authService.Suspend(name)
+	c.IndentedJSON(http.StatusOK, adminView(user))
+}
+
+// 4. снимает блокировку пользователя
+func adminReinstateUser(c *gin.Context) {
+	name := c.Param("name")
+	logging.SetUser(c.Request.Context(), name)
+	user := repoFindUser(c.Request.Context(), name)
+	if user.Name == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "пользователь не найден", "request_id": requestID(c)}) //(404)
+		return
+	}
+	authService.Reinstate(name)
+	c.IndentedJSON(http.StatusOK, adminView(user))
 }
@@ -1,3 +1,7 @@
+//go:build gorilla
+
+// Сервис на "Горилла": go run gorilla_Rest.go (тег gorilla нужен только для go test и go vet пакета).
+
 /*
 30.5 Практическая работа: написать HTTP-сервис (с JSON-данными)
 Обработчики:
@@ -17,12 +21,33 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
 	"strconv"
 
+	"Network-exchange/apiv2"
+	"Network-exchange/audit"
+	"Network-exchange/auth"
+	"Network-exchange/core"
+	"Network-exchange/feed"
+	"Network-exchange/graphqlapi"
+	"Network-exchange/grpcapi"
+	"Network-exchange/logging"
+	"Network-exchange/notify"
+	"Network-exchange/openapi"
+	"Network-exchange/ratelimit"
+	"Network-exchange/retention"
+	"Network-exchange/secure"
+	"Network-exchange/tlsconf"
+	"Network-exchange/tracing"
+	"Network-exchange/versioning"
+	"Network-exchange/webhook"
+
 	"github.com/gorilla/mux"
 )
 
@@ -37,37 +62,204 @@ type (
 )
 
 var (
-	//	user  User                 //хранилище для одного пользователя
-	users = make(map[int]User) //хранилище для всех пользователей
+	store = core.NewStore(false) //хранилище пользователей (общее для /v1 и /v2; имена могут повторяться)
+
+	authService *auth.Service //учетные записи и JWT
 )
 
+// Запрос на создание пользователя: данные пользователя и пароль для входа (не обязателен)
+type newUserRequest struct {
+	User
+	Password string `json:"password"` //пароль хранится только в виде хеша
+}
+
 type Friendship struct { // Структура для запроса дружбы (имена переменых)
 	SourceId int `json:"sourceId"` //ID инициатора дружбы
 	TargetId int `json:"targetId"` //ID принявшего запрос
 }
 
 func main() {
+	logging.Setup(os.Stdout) //структурированный журнал в формате JSON
+	//трассировка OpenTelemetry (экспорт задается переменной OTEL_TRACES_EXPORTER)
+	shutdown, err := tracing.Setup(context.Background(), "network-exchange-gorilla")
+	if err != nil {
+		slog.Error("трассировка не настроена", "error", err)
+		os.Exit(1)
+	}
+	defer shutdown(context.Background())
+	//учетные записи (JWT_SECRET - ключ подписи, ADMIN_PASSWORD - пароль "admin")
+	authService = auth.FromEnv("network-exchange-gorilla")
+	//дополнительные поля профиля: PROFILE_FIELDS="company=string,shoe_size=integer,team=red|blue"
+	fields, err := core.FieldsFromEnv()
+	if err != nil {
+		slog.Error("поля профиля объявлены с ошибкой", "error", err)
+		os.Exit(1)
+	}
+	store.DeclareFields(fields)
+	//владелец запроса среди пользователей: от него скрыты заблокировавшие его
+	store.SetViewer(func(ctx context.Context, u core.User) bool {
+		id, ok := auth.FromContext(ctx)
+		return ok && id.Subject == strconv.Itoa(u.ID)
+	})
+
+	router, spec, limiter := newRouter(fields)
+	//маршрут без описания (или описание без маршрута) - ошибка запуска
+	if err := spec.CheckRoutes(openapi.MuxRoutes(router)); err != nil {
+		slog.Error("описание API устарело", "error", err)
+		os.Exit(1)
+	}
+
+	slog.Info("Слушаем порт :8080")
+	//CORS и заголовки безопасности (CORS_ALLOWED_ORIGINS и др.), размер тела запроса (MAX_BODY_BYTES);
+	//оборачивают весь роутер, чтобы предварительные запросы OPTIONS не получали 405
+	handler := secure.FromEnv().Handler(router)
+	//спаны и журнал с X-Request-ID - для всех запросов, в том числе без маршрута (404, 405)
+	handler = tracing.Mux(logging.Mux(handler))
+	//gRPC (netx.v1.UserService, netx.v1.FriendshipService) - на том же порту по HTTP/2
+	grpcAPI := grpcapi.New(store, authService, func(u core.User) string { return strconv.Itoa(u.ID) })
+	handler = grpcAPI.Handler(grpcAPI.Server(limiter), handler)
+	//$ grpcurl -plaintext -d '{"min_age":18}' localhost:8080 netx.v1.UserService/ListUsers
+	//по HTTP, а при заданных TLS_CERT_FILE и TLS_KEY_FILE - по HTTPS (TLS_CLIENT_CA_FILE - mTLS)
+	if err := tlsconf.ListenAndServe(":8080", handler); err != nil { //передаем роутер в функцию ListenAndServe
+		slog.Error("сервер остановлен", "error", err)
+	}
+}
+
+// newRouter собирает маршрутизатор со всеми версиями API и служебными маршрутами; вместе
+// с ним - описание API (по нему проверяются маршруты) и ограничитель частоты запросов,
+// общий с gRPC
+func newRouter(fields []core.Field) (*mux.Router, *openapi.Document, *ratelimit.Limiter) {
+	//ограничение частоты запросов: по ключу API, пользователю или IP клиента
+	limiter := ratelimit.New(ratelimit.PerMinute(120), map[string]ratelimit.Limit{
+		"POST /users":                           ratelimit.PerMinute(5), //создание пользователей
+		"POST /v1/users":                        ratelimit.PerMinute(5),
+		"POST /v2/users":                        ratelimit.PerMinute(5),
+		"POST /friends":                         ratelimit.PerMinute(10), //запросы дружбы
+		"POST /v1/friends":                      ratelimit.PerMinute(10),
+		"PUT /v2/users/{id}/friends/{friendId}": ratelimit.PerMinute(10),
+		"POST /login":                           ratelimit.PerMinute(10), //подбор паролей
+		"POST /graphql":                         ratelimit.PerMinute(30), //изменения и тяжелые запросы GraphQL
+		//вызовы gRPC: "GRPC /служба/метод"
+		"GRPC /netx.v1.UserService/CreateUser":      ratelimit.PerMinute(5),
+		"GRPC /netx.v1.FriendshipService/AddFriend": ratelimit.PerMinute(10),
+	})
+
 	router := mux.NewRouter().StrictSlash(true) //создаем новый маршрутизатор
+	//маршрут для спанов и журнала (сами они оборачивают весь роутер - см. main), проверка JWT и лимиты
+	//описание API: по нему проверяются параметры и тела запросов (OPENAPI_VALIDATE_RESPONSES=true - и ответы)
+	spec := openapi.GorillaSpec()
+	openapi.ProfileFields(spec, fields)
+	router.Use(tracing.Route, logging.Route, authService.Mux, limiter.Mux, openapi.ValidatorFromEnv(spec).Mux)
 	//регистрируем иаршруты
-	router.HandleFunc("/", Index).Methods("GET")                                 //начальная страница
-	router.HandleFunc("/users", userIndex).Methods("GET")                        //получаем всех пользователей
-	router.HandleFunc("/users/{userId}", userShow).Methods("GET")                //получаем пользователя по его ID
-	router.HandleFunc("/users/friends/{userId}", friendsUserShow).Methods("GET") //получаем друзей пользователя по его ID
+	router.HandleFunc("/", Index).Methods("GET")                          //начальная страница
+	router.HandleFunc("/login", authService.LoginHandler).Methods("POST") //вход: логин - ID пользователя
+	//$ curl -i http://localhost:8080/login -d "{\"login\":\"4\",\"password\":\"secret123\"}"
+	router.HandleFunc("/token/refresh", authService.RefreshHandler).Methods("POST") //новая пара токенов
+	//$ curl -i http://localhost:8080/token/refresh -d "{\"refresh_token\":\"...\"}"
+	router.HandleFunc("/logout", authService.LogoutHandler).Methods("POST") //отзыв токена обновления
+
+	//версия 1 устарела (заголовки Deprecation, Sunset и Link): доступна без префикса и под /v1
+	v1 := versioning.FromEnv(apiv2.Prefix+"/users", "/docs")
+	legacy := router.NewRoute().Subrouter()
+	legacy.Use(v1.Mux)
+	registerV1(legacy)
+	prefixed := router.PathPrefix("/v1").Subrouter()
+	prefixed.Use(v1.Mux)
+	registerV1(prefixed)
+	//версия 2: ресурсы /v2/users, ошибки в формате application/problem+json
+	apiv2.New(store, authService, func(u core.User) string { return strconv.Itoa(u.ID) }).Mux(router.PathPrefix(apiv2.Prefix).Subrouter())
+	//$ go run ./cmd/netx -server http://localhost:8080 users list -min-age 18 -limit 10
+	//GraphQL: пользователи с друзьями любой вложенности и изменения (GET - только запросы)
+	gql := graphqlapi.New(store, authService, func(u core.User) string { return strconv.Itoa(u.ID) })
+	router.Handle(graphqlapi.Path, gql).Methods("GET", "POST")
+	//$ curl -i http://localhost:8080/graphql -d "{\"query\":\"{ user(id: 2) { name friends { name friends { name } } } }\"}"
+	//лента изменений вместо опроса GET /users (Server-Sent Events, продолжение по Last-Event-ID)
+	router.Handle(feed.Path, feed.New(store, func(u core.User) string { return strconv.Itoa(u.ID) })).Methods("GET")
+	//$ curl -N http://localhost:8080/events?types=user.created,user.deleted
+	//уведомления о дружбе для вошедшего пользователя (WebSocket)
+	router.Handle(notify.Path, notify.New(store, authService, func(u core.User) string { return strconv.Itoa(u.ID) })).Methods("GET")
+	//$ websocat "ws://localhost:8080/ws/notifications?access_token=$TOKEN"
+
+	//служебные маршруты: права проверяются по роли (admin - все, auditor - только чтение)
+	admin := router.PathPrefix("/admin").Subrouter()
+	admin.Handle("/users", auth.Require(auth.PermUsersRead)(http.HandlerFunc(adminUserIndex))).Methods("GET")
+	//$ curl -i http://localhost:8080/admin/users -H "Authorization: Bearer $TOKEN"
+	admin.Handle("/users/{userId}", auth.Require(auth.PermUsersWrite)(http.HandlerFunc(adminUserPatch))).Methods("PATCH")
+	//$ curl -X PATCH -i http://localhost:8080/admin/users/2 -H "Authorization: Bearer $TOKEN" -d "{\"age\":40,\"role\":\"auditor\"}"
+	admin.Handle("/users/{userId}/suspend", auth.Require(auth.PermUsersSuspend)(http.HandlerFunc(adminUserSuspend))).Methods("POST")
+	//$ curl -X POST -i http://localhost:8080/admin/users/2/suspend -H "Authorization: Bearer $TOKEN"
+	admin.Handle("/users/{userId}/reinstate", auth.Require(auth.PermUsersSuspend)(http.HandlerFunc(adminUserReinstate))).Methods("POST")
+	//$ curl -X POST -i http://localhost:8080/admin/users/2/reinstate -H "Authorization: Bearer $TOKEN"
+	admin.Handle("/users/{userId}", auth.Require(auth.PermUsersDelete)(http.HandlerFunc(deleteUser))).Methods("DELETE")
+	//$ curl -X DELETE -i http://localhost:8080/admin/users/2 -H "Authorization: Bearer $TOKEN"
+
+	//удаленные: восстановление в течение DELETE_RETENTION, затем фоновая очистка
+	trash := retention.New(store, authService, func(u core.User) string { return strconv.Itoa(u.ID) })
+	admin.Handle("/deleted-users", auth.Require(auth.PermUsersRead)(http.HandlerFunc(trash.Handler))).Methods("GET")
+	//$ curl -i http://localhost:8080/admin/deleted-users -H "Authorization: Bearer $TOKEN"
+	admin.Handle("/deleted-users/{userId}/restore", auth.Require(auth.PermUsersDelete)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trash.ServeRestore(w, r, mux.Vars(r)["userId"])
+	}))).Methods("POST")
+	//$ curl -X POST -i http://localhost:8080/admin/deleted-users/2/restore -H "Authorization: Bearer $TOKEN"
+	admin.Handle("/deleted-users/{userId}", auth.Require(auth.PermUsersDelete)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trash.ServeErase(w, r, mux.Vars(r)["userId"])
+	}))).Methods("DELETE")
+
+	//ключи API для межсервисных клиентов: Authorization: Bearer <key> или X-API-Key: <key>
+	admin.Handle("/apikeys", auth.Require(auth.PermKeysManage)(http.HandlerFunc(authService.KeysHandler))).Methods("GET", "POST")
+	//$ curl -X POST -i http://localhost:8080/admin/apikeys -H "Authorization: Bearer $TOKEN" -d "{\"name\":\"batch\",\"scope\":\"read\",\"expires_in_days\":30}"
+	admin.Handle("/apikeys/{keyId}", auth.Require(auth.PermKeysManage)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authService.ServeRevokeKey(w, r, mux.Vars(r)["keyId"])
+	}))).Methods("DELETE")
+	//$ curl -X DELETE -i http://localhost:8080/admin/apikeys/<id> -H "Authorization: Bearer $TOKEN"
+
+	//журнал аудита: кто, когда и откуда изменил пользователей (admin, auditor)
+	auditLog := audit.New(store)
+	admin.Handle("/audit", auth.Require(auth.PermAuditRead)(http.HandlerFunc(auditLog.Handler))).Methods("GET")
+	//$ curl -i "http://localhost:8080/admin/audit?user_id=2&from=2026-10-01T00:00:00Z" -H "Authorization: Bearer $TOKEN"
+	admin.Handle("/audit/export", auth.Require(auth.PermAuditRead)(http.HandlerFunc(auditLog.ExportHandler))).Methods("GET")
+	//$ curl -o audit.ndjson "http://localhost:8080/admin/audit/export?actor=admin" -H "Authorization: Bearer $TOKEN"
+
+	//вебхуки: POST на адрес подписки при создании и удалении пользователей (подпись - X-Webhook-Signature)
+	hooks := webhook.New(store)
+	hooksOnly := auth.Require(auth.PermHooksManage)
+	admin.Handle("/webhooks", hooksOnly(http.HandlerFunc(hooks.SubscriptionsHandler))).Methods("GET", "POST")
+	//$ curl -X POST -i http://localhost:8080/admin/webhooks -H "Authorization: Bearer $TOKEN" -d "{\"url\":\"https://partner.example.com/hook\",\"events\":[\"user.created\",\"user.deleted\"]}"
+	admin.Handle("/webhooks/dead", hooksOnly(http.HandlerFunc(hooks.DeadHandler))).Methods("GET") //раньше /webhooks/{webhookId}
+	admin.Handle("/webhooks/dead/{deliveryId}/redeliver", hooksOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hooks.ServeRedeliver(w, r, mux.Vars(r)["deliveryId"])
+	}))).Methods("POST")
+	admin.Handle("/webhooks/{webhookId}", hooksOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hooks.ServeSubscription(w, r, mux.Vars(r)["webhookId"])
+	}))).Methods("GET", "DELETE")
+	admin.Handle("/webhooks/{webhookId}/deliveries", hooksOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hooks.ServeDeliveries(w, r, mux.Vars(r)["webhookId"])
+	}))).Methods("GET")
+	//$ curl -i http://localhost:8080/admin/webhooks/<id>/deliveries -H "Authorization: Bearer $TOKEN"
+
+	//документация: описание OpenAPI и страница Swagger UI
+	router.HandleFunc("/openapi.json", spec.Handler()).Methods("GET")          // http://localhost:8080/openapi.json
+	router.HandleFunc("/docs", spec.UIHandler("/openapi.json")).Methods("GET") // http://localhost:8080/docs
+	return router, spec, limiter
+}
 
-	router.HandleFunc("/users", userCreate).Methods("POST") //создаем нового пользователя
-	//$ curl -i http://localhost:8080/users -H "content-type: application/json" -d "{\"name\":\"Milli\",\"age\":33,\"friends\":{}}"
+// Маршруты версии 1 (без префикса и под /v1)
+func registerV1(r *mux.Router) {
+	r.HandleFunc("/users", userIndex).Methods("GET")                        //получаем всех пользователей
+	r.HandleFunc("/users/{userId}", userShow).Methods("GET")                //получаем пользователя по его ID
+	r.HandleFunc("/users/friends/{userId}", friendsUserShow).Methods("GET") //получаем друзей пользователя по его ID
 
-	router.HandleFunc("/friends", makeFriends).Methods("POST") //создаем дружеский союз из двух пользователей
-	//$ curl -i http://localhost:8080/friends -H "content-type: application/json" -d "{\"sourceId\":1,\"targetId\":2}"
+	r.HandleFunc("/users", userCreate).Methods("POST") //создаем нового пользователя
+	//$ curl -i http://localhost:8080/users -H "content-type: application/json" -d "{\"name\":\"Milli\",\"age\":33,\"friends\":{},\"password\":\"secret123\"}"
 
-	router.HandleFunc("/users/{userId}", updateAge).Methods("PUT") //изменяем возраст пользователя
-	//$ curl -X PUT -H "content-type: application/json" -d "24" -i http://localhost:8080/users/2
+	r.HandleFunc("/friends", makeFriends).Methods("POST") //создаем дружеский союз из двух пользователей
+	//$ curl -i http://localhost:8080/friends -H "Authorization: Bearer $TOKEN" -H "content-type: application/json" -d "{\"sourceId\":1,\"targetId\":2}"
 
-	router.HandleFunc("/users/{userId}", deleteUser).Methods("DELETE") //удаляем пользователя по его ID
-	//$ curl -X DELETE -i http://localhost:8080/users/1
+	r.HandleFunc("/users/{userId}", updateAge).Methods("PUT") //изменяем возраст пользователя
+	//$ curl -X PUT -H "Authorization: Bearer $TOKEN" -H "content-type: application/json" -d "24" -i http://localhost:8080/users/2
 
-	log.Println("Слушаем порт :8080")
-	log.Fatal(http.ListenAndServe(":8080", router)) //передаем роутер в функцию ListenAndServe
+	r.HandleFunc("/users/{userId}", deleteUser).Methods("DELETE") //удаляем пользователя по его ID
+	//$ curl -X DELETE -H "Authorization: Bearer $TOKEN" -i http://localhost:8080/users/1
 }
 
 //ПОМОЩНИКИ:
@@ -79,40 +271,85 @@ func Index(w http.ResponseWriter, _ *http.Request) {
 
 // 2. Создать начальную базу пользователей
 func init() {
-	repoCreateUser(User{ //пользователь без друзей
+	repoCreateUser(context.Background(), User{ //пользователь без друзей
 		"Adell",
 		21,
 		Friends{},
 	})
-	repoCreateUser(User{"Barbora", 22, map[int]string{999: "Gloria"}}) //у пользователя есть друг
+	repoCreateUser(context.Background(), User{"Barbora", 22, map[int]string{999: "Gloria"}}) //у пользователя есть друг
 }
 
 // 3. Получить всех пользователей по URL  http://localhost:8080/users
-func userIndex(w http.ResponseWriter, _ *http.Request) {
+func userIndex(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8") //формируем заголовок ответа
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(users); err != nil { //показываем всех пользователей в хранилище
+	if err := json.NewEncoder(w).Encode(repoUsers(r.Context())); err != nil { //показываем всех пользователей в хранилище
 		http.Error(w, err.Error(), 500)
 		return
 	}
 }
 
-// 4. Присвоить уникальной ID новому пользователю
-var currentId int //начальный ID регистрации пользователя
+// пользователь хранилища в представлении версии 1: друзья - карта ID -> имя
+func view(user core.User) User {
+	return User{Name: user.Name, Age: user.Age, Friends: store.Names(user.Friends)}
+}
+
+// все пользователи по их ID
+func repoUsers(ctx context.Context) Users {
+	out := Users{}
+	for _, user := range store.All(ctx) {
+		out[user.ID] = view(user)
+	}
+	return out
+}
 
-func repoCreateUser(user User) User {
-	currentId += 1          // увеличиваем текущий ID для созданного пользователя на "1"
-	users[currentId] = user //присваиваем ID новому пользователю
-	return user             //возвращаем обновленный список пользователей
+// 4. Присвоить уникальный ID новому пользователю; перечисленные в Friends существующие пользователи становятся друзьями,
+// account (если задан) занимает учетную запись до появления пользователя
+func repoCreateUser(ctx context.Context, user User, account ...core.Reserve) (int, User, error) {
+	created, err := store.Create(ctx, user.Name, user.Age, account...)
+	if err != nil {
+		return 0, User{}, err
+	}
+	for id := range user.Friends {
+		store.Befriend(ctx, created.ID, id) //несуществующие ID пропускаются
+	}
+	return created.ID, repoFindUser(ctx, created.ID), nil
 }
 
 // 5. Найти определенного пользователя по ID
-func repoFindUser(id int) User { //находим пользователя по его ID
-	for key, user := range users {
-		if key == id { //если есть совпадение с ID
-			return user //возвращаем пользователя
-		}
+func repoFindUser(ctx context.Context, id int) User { //находим пользователя по его ID
+	user, err := store.Get(ctx, id)
+	if err != nil {
+		return User{} //если ничего нет возвращаем пустого пользователя
+	}
+	return view(user)
+}
+
+// 7. Создать дружеский союз двух пользователей по их ID (повторный запрос - не ошибка)
+func repoMakeFriends(ctx context.Context, sourceId, targetId int) error {
+	if err := store.Befriend(ctx, sourceId, targetId); err != nil && !errors.Is(err, core.ErrAlreadyFriends) {
+		return err
 	}
-	return User{} //если ничего нет возвращаем пустой слайс пользователя
+	return nil
+}
+
+// 8. Пометить пользователя удаленным и стереть его из карт друзей всех пользователей
+// (восстановление - POST /admin/deleted-users/{userId}/restore)
+func repoDeleteUser(ctx context.Context, id int) (User, bool) {
+	user, err := store.Delete(ctx, id)
+	if err != nil {
+		return User{}, false
+	}
+	return view(user), true
+}
+
+// 9. Изменить возраст пользователя по ID
+func repoUpdateAge(ctx context.Context, id, age int) (User, error) {
+	user, err := store.SetAge(ctx, id, age)
+	if err != nil {
+		return User{}, err
+	}
+	return view(user), nil
 }
 
 // 6. Получить пользователя по его ID
@@ -122,14 +359,16 @@ func userShow(w http.ResponseWriter, r *http.Request) {
 		err    error
 	)
 	vars := mux.Vars(r) //получаем ID пользователя из запроса
+	logging.SetUser(r.Context(), vars["userId"])
 
 	if userId, err = strconv.Atoi(vars["userId"]); err != nil { //принимаем "строковое" число - возвращем целое
-		fmt.Printf("ошибка синтаксиса, получен 'ID' = %v \n", vars["userId"])
+		logging.FromContext(r.Context()).Warn("ошибка синтаксиса ID", "id", vars["userId"])
 	}
-	user := repoFindUser(userId) //полученный Id отправляем в хранилище для поиска пользователя
+	user := repoFindUser(r.Context(), userId) //полученный Id отправляем в хранилище для поиска пользователя
 
 	if user.Name != "" { //если с таким ID пользователь существует, то:
 		//показываем ответ в окне браузера по URL  http://localhost:8080/users/id
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 		if err := json.NewEncoder(w).Encode(user); err != nil {
 			http.Error(w, err.Error(), 400)
 		}
@@ -138,11 +377,13 @@ func userShow(w http.ResponseWriter, r *http.Request) {
 	// Если не нашли пользователя, то ошибка 404 (не найдено)
 	//формируем ответ
 	type jsonErr struct {
-		Code int    `json:"code"`
-		Text string `json:"text"`
-		ID   string `json:"id"`
+		Code      int    `json:"code"`
+		Text      string `json:"text"`
+		ID        string `json:"id"`
+		RequestID string `json:"request_id"`
 	}
-	json.NewEncoder(w).Encode(jsonErr{Code: http.StatusNotFound, Text: "Нет пользователя:", ID: vars["userId"]})
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(jsonErr{Code: http.StatusNotFound, Text: "Нет пользователя:", ID: vars["userId"], RequestID: logging.RequestID(r.Context())})
 }
 
 //ОБРАБОТЧИКИ:
@@ -150,26 +391,39 @@ func userShow(w http.ResponseWriter, r *http.Request) {
 // 1. Создать нового пользователя и присваиваем ему ID
 func userCreate(w http.ResponseWriter, r *http.Request) {
 
-	var user User                                                     //хранилище для одного пользователя
+	var req newUserRequest                                            //данные пользователя и пароль
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8") //формируем заголовок ответа
-	err := json.NewDecoder(r.Body).Decode(&user)                      //декодируем запрос JSON
-	if err != nil {                                                   //Если при декодировании JSON возникла ошибка,
+	err := secure.DecodeJSON(r, &req)                                 //декодируем запрос JSON (без неизвестных полей)
+	if secure.TooLarge(err) {                                         //тело больше допустимого - код 413
+		secure.WriteTooLarge(w, r)
+		return
+	} else if err != nil { //Если при декодировании JSON возникла ошибка,
 		w.WriteHeader(http.StatusBadRequest) //возвращается код 400 Bad Request
 		http.Error(w, "неправильный, некорректный запрос 'cURL'\n", http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close() //отложенное закрытие запроса
+	user := req.User     //хранилище для одного пользователя (имя проверено по описанию API)
 
-	//если запрос не полный
-	if user.Name == "" && user.Age == 0 {
-		w.WriteHeader(http.StatusNoContent) //ошибка "Нет содержимого"
+	var account core.Reserve
+	if req.Password != "" { //пароль проверяем до создания пользователя
+		pending, err := authService.Prepare(req.Password, auth.RoleUser)
+		if err != nil {
+			auth.WriteError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		//учетная запись для входа: логин и владелец - ID пользователя; занимается вместе с его созданием
+		account = func(u core.User) error { return pending.Register(strconv.Itoa(u.ID)) }
+	}
+	newId, newUser, err := repoCreateUser(r.Context(), user, account) //получаем из функции нового пользователя с присвоенным ему ID
+	if errors.Is(err, auth.ErrLoginTaken) {
+		auth.WriteError(w, r, http.StatusConflict, err.Error())
 		return
-	} else if user.Name == "" {
-		w.WriteHeader(http.StatusPartialContent) //ошибка "Частичное содержимое"
-		w.Write([]byte(" 206 новая запись не создана Имя не указано\n"))
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	newUser := repoCreateUser(user) //получаем из функции нового пользователя с присвоенным ему ID
+	logging.SetUser(r.Context(), strconv.Itoa(newId))
 
 	//удачное завершение
 	//ответ в командной строке
@@ -181,11 +435,6 @@ func userCreate(w http.ResponseWriter, r *http.Request) {
 		age := strconv.Itoa(user.Age)
 		newAge := " Создан новый пользователь -> " + user.Name + " " + age + " лет\n" // В хранилище:\n"
 		w.Write([]byte(newAge))
-		/*		//красивая печать в терминал:
-				w.Write([]byte(" Создан новый пользователь:"))
-				resp, _ := json.MarshalIndent(newUser, "", "    ")
-				fmt.Println(string(resp))
-		*/
 	}
 	//ответ в окне браузера по указанному URL  http://localhost:8080/users
 	if err := json.NewEncoder(w).Encode(newUser); err != nil { //показывает список пользователей
@@ -201,23 +450,39 @@ func makeFriends(w http.ResponseWriter, r *http.Request) { //обработчи
 	var union Friendship
 
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-	if err := json.NewDecoder(r.Body).Decode(&union); err != nil { //Если при декодировании JSON возникла ошибка,
+	if err := secure.DecodeJSON(r, &union); err != nil { //Если при декодировании JSON возникла ошибка,
+		if secure.TooLarge(err) { //тело больше допустимого - код 413
+			secure.WriteTooLarge(w, r)
+			return
+		}
 		w.WriteHeader(http.StatusBadRequest) //возвращается код 400 Bad Request
 		http.Error(w, "неправильный, некорректный запрос 'cURL'\n", http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close() //отложенное закрытие запроса
+	logging.SetUser(r.Context(), strconv.Itoa(union.SourceId))
+	//дружбу предлагает только сам инициатор (или администратор)
+	if err := auth.Authorize(r.Context(), strconv.Itoa(union.SourceId), auth.PermUsersWrite); err != nil {
+		auth.Deny(w, r, err)
+		return
+	}
 
-	source := repoFindUser(union.SourceId) //получаем пользователя инициатора дружбы по его ID
-	target := repoFindUser(union.TargetId) //получаем пользователя который примет инициатора в друзья
+	source := repoFindUser(r.Context(), union.SourceId) //получаем пользователя инициатора дружбы по его ID
+	target := repoFindUser(r.Context(), union.TargetId) //получаем пользователя который примет инициатора в друзья
 
 	if source.Name != "" && target.Name != "" { //проверяем наличие пользователей
-		source.Friends[union.TargetId] = target.Name //пополняем карту друзей инициатора
-		target.Friends[union.SourceId] = source.Name //пополняем карту друзей принявшего приглашение
+		if err := repoMakeFriends(r.Context(), union.SourceId, union.TargetId); errors.Is(err, core.ErrBlocked) {
+			auth.WriteError(w, r, http.StatusForbidden, err.Error()) //один заблокировал другого - код 403
+			return
+		} else if err != nil { //пополняем карты друзей обоих
+			w.WriteHeader(http.StatusBadRequest) //с самим собой дружить нельзя - код 400
+			w.Write([]byte("Упс! " + err.Error() + "\n"))
+			return
+		}
 
 		//ответ в окне браузера по URL  http://localhost:8080/users
-		w.WriteHeader(http.StatusOK)                             //формируем заголовок ответа
-		if err := json.NewEncoder(w).Encode(users); err != nil { //показывает список пользователей
+		w.WriteHeader(http.StatusOK)                                              //формируем заголовок ответа
+		if err := json.NewEncoder(w).Encode(repoUsers(r.Context())); err != nil { //показывает список пользователей
 			w.WriteHeader(http.StatusInternalServerError) //возвращается код 500 (внутренняя ошибка сервера)
 			http.Error(w, "внутренняя ошибка сервера", http.StatusInternalServerError)
 			return
@@ -237,23 +502,20 @@ func deleteUser(w http.ResponseWriter, r *http.Request) {
 	var userId int
 
 	vars := mux.Vars(r) //получаем map[key:value] с Id пользователя из маршрута key => {userId}:1
+	logging.SetUser(r.Context(), vars["userId"])
 
 	userId, _ = strconv.Atoi(vars["userId"]) //принимаем "строковое" число - возвращем целое
+	//удалить можно только себя (администратор - любого)
+	if err := auth.Authorize(r.Context(), strconv.Itoa(userId), auth.PermUsersDelete); err != nil {
+		auth.Deny(w, r, err)
+		return
+	}
 
-	_, ok := users[userId] //получаем true, если пользователь с таким Id существует
-	if ok {                //если true
-		for key, user := range users {
-			if key == userId { //если ID пользователя совпадает с запрошенным
-				delete(users, key) //удаляем пользователя из хранилища
-				deleteId := " пользователь " + user.Name + " удален\n В хранилище:\n"
-				w.Write([]byte(deleteId))
-			}
-			for id := range user.Friends { //проверяем хранилище друзей каждого пользователя
-				if id == userId { //если Id пользователя совпадает с запрошенным
-					delete(user.Friends, id) //удаляем его из друзей оставшихся пользователей
-				}
-			}
-		}
+	user, ok := repoDeleteUser(r.Context(), userId) //получаем true, если пользователь с таким Id существовал
+	if ok {                                         //если true
+		authService.Suspend(strconv.Itoa(userId)) //учетная запись заблокирована до восстановления или очистки
+		deleteId := " пользователь " + user.Name + " удален\n В хранилище:\n"
+		w.Write([]byte(deleteId))
 	} else { // Если мы не нашли пользователя, то ошибка 404 (не найдено)
 		w.WriteHeader(http.StatusNotFound)
 		//ответ в командной строке
@@ -263,7 +525,7 @@ func deleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	json.NewEncoder(w).Encode(users) //показывает список оставшихся пользователей
+	json.NewEncoder(w).Encode(repoUsers(r.Context())) //показывает список оставшихся пользователей
 }
 
 // 4. Показать друзей пользователя по его ID
@@ -274,11 +536,12 @@ func friendsUserShow(w http.ResponseWriter, r *http.Request) {
 		friends string
 	)
 	vars := mux.Vars(r)
+	logging.SetUser(r.Context(), vars["userId"])
 
 	userId, _ = strconv.Atoi(vars["userId"]) //принимаем "строковое" число - возвращем целое
 
-	user := repoFindUser(userId) //полученный Id отправляем в хранилище для поиска пользователя
-	if user.Name != "" {         //если под  таким ID пользователь существует, то:
+	user := repoFindUser(r.Context(), userId) //полученный Id отправляем в хранилище для поиска пользователя
+	if user.Name != "" {                      //если под  таким ID пользователь существует, то:
 		for _, friend := range user.Friends { //проверяем хранилище друзей пользователя
 			friends = friends + " " + friend + " *"
 		}
@@ -296,10 +559,11 @@ func friendsUserShow(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNotFound)                                //ошибка 404 (не найдено)
 	//формируем ответ
 	type jsonErr struct {
-		Code int    `json:"code"`
-		Text string `json:"text"`
+		Code      int    `json:"code"`
+		Text      string `json:"text"`
+		RequestID string `json:"request_id"`
 	}
-	if err := json.NewEncoder(w).Encode(jsonErr{Code: http.StatusNotFound, Text: "Пользователь не найден"}); err != nil {
+	if err := json.NewEncoder(w).Encode(jsonErr{Code: http.StatusNotFound, Text: "Пользователь не найден", RequestID: logging.RequestID(r.Context())}); err != nil {
 		panic(err)
 	}
 }
@@ -311,28 +575,43 @@ func updateAge(w http.ResponseWriter, r *http.Request) {
 		newAge int
 	)
 	vars := mux.Vars(r) //получаем map[key:value] с Id пользователя
+	logging.SetUser(r.Context(), vars["userId"])
 
 	userId, _ = strconv.Atoi(vars["userId"]) //принимаем "строковое" число - возвращем целое
 
-	user := repoFindUser(userId) //полученный Id отправляем в хранилище для поиска пользователя
+	user := repoFindUser(r.Context(), userId) //полученный Id отправляем в хранилище для поиска пользователя
 
 	if user.Name != "" { //если под таким ID пользователь существует, то:
+		//изменить возраст можно только себе (администратор - любому)
+		if err := auth.Authorize(r.Context(), strconv.Itoa(userId), auth.PermUsersWrite); err != nil {
+			auth.Deny(w, r, err)
+			return
+		}
 		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-		if err := json.NewDecoder(r.Body).Decode(&newAge); err != nil {
+		if err := secure.DecodeJSON(r, &newAge); err != nil {
+			if secure.TooLarge(err) { //тело больше допустимого - код 413
+				secure.WriteTooLarge(w, r)
+				return
+			}
 			http.Error(w, err.Error(), 400)
 			return
 		}
 		defer r.Body.Close() //отложенное закрытие запроса
 
-		for key, user := range users {
-			if key == userId { //если Id совпадает с запрошенным
-				user.Age = newAge //обновляем возраст
-				users[key] = user //вносим обновление в хранилище пользователей
-				//формируем ответ в командной строке
-				update := "возраст пользователя " + user.Name + " успешно обновлён на " + strconv.Itoa(user.Age) + "\n"
-				w.Write([]byte(update))
-				return
-			}
+		user, err := repoUpdateAge(r.Context(), userId, newAge) //вносим обновление в хранилище пользователей
+		if err == nil {
+			//формируем ответ в командной строке
+			update := "возраст пользователя " + user.Name + " успешно обновлён на " + strconv.Itoa(user.Age) + "\n"
+			w.Write([]byte(update))
+			return
+		}
+		if errors.Is(err, core.ErrAgeDerived) { //возраст вычисляется по дате рождения
+			auth.WriteError(w, r, http.StatusConflict, err.Error())
+			return
+		}
+		if errors.Is(err, core.ErrInvalid) { //отрицательный возраст
+			http.Error(w, err.Error(), 400)
+			return
 		}
 	}
 	// Если мы не нашли пользователя
@@ -341,5 +620,130 @@ func updateAge(w http.ResponseWriter, r *http.Request) {
 	//формируем ответ
 	updateFails := "не удается найти пользователя c ID = " + strconv.Itoa(userId) + " для изменения возраста\n"
 	w.Write([]byte(updateFails))
-	json.NewEncoder(w).Encode(users) //показывает список всех пользователей
+	json.NewEncoder(w).Encode(repoUsers(r.Context())) //показывает список всех пользователей
+}
+
+//СЛУЖЕБНЫЕ ОБРАБОТЧИКИ (роли admin и auditor):
+
+// Пользователь вместе с ролью и блокировкой его учетной записи
+type adminUserView struct {
+	User
+	Role      auth.Role `json:"role"`
+	Suspended bool      `json:"suspended"`
+}
+
+func adminView(id int, user User) adminUserView {
+	view := adminUserView{User: user, Role: auth.RoleUser}
+	if acc, ok := authService.Account(strconv.Itoa(id)); ok {
+		view.Role, view.Suspended = acc.Role, acc.Suspended
+	}
+	return view
+}
+
+// ответ JSON служебных обработчиков
+func writeAdminJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, "внутренняя ошибка сервера", http.StatusInternalServerError)
+	}
+}
+
+// пользователь из маршрута {userId}; при отсутствии отвечает 404
+func adminFindUser(w http.ResponseWriter, r *http.Request) (int, User, bool) {
+	vars := mux.Vars(r)
+	logging.SetUser(r.Context(), vars["userId"])
+	userId, _ := strconv.Atoi(vars["userId"]) //принимаем "строковое" число - возвращем целое
+	user := repoFindUser(r.Context(), userId)
+	if user.Name == "" {
+		auth.WriteError(w, r, http.StatusNotFound, "не удается найти пользователя c ID = "+vars["userId"])
+		return 0, User{}, false
+	}
+	return userId, user, true
+}
+
+// 1. Список всех пользователей со статусом учетных записей
+func adminUserIndex(w http.ResponseWriter, r *http.Request) {
+	all := repoUsers(r.Context())
+	out := make(map[int]adminUserView, len(all))
+	for id, user := range all {
+		out[id] = adminView(id, user)
+	}
+	writeAdminJSON(w, http.StatusOK, out)
+}
+
+// 2. Изменить возраст и (при праве roles:manage) роль любого пользователя
+func adminUserPatch(w http.ResponseWriter, r *http.Request) {
+	var patch struct {
+		Age  *int       `json:"age"`
+		Role *auth.Role `json:"role"`
+	}
+	if err := secure.DecodeJSON(r, &patch); err != nil {
+		if secure.TooLarge(err) { //тело больше допустимого - код 413
+			secure.WriteTooLarge(w, r)
+			return
+		}
+		auth.WriteError(w, r, http.StatusBadRequest, "неправильный, некорректный запрос")
+		return
+	}
+	defer r.Body.Close() //отложенное закрытие запроса
+	userId, user, ok := adminFindUser(w, r)
+	if !ok {
+		return
+	}
+	//права, роль и возраст проверяются до изменений: при ошибке не меняется ничего
+	if patch.Role != nil {
+		if err := auth.Check(r.Context(), auth.PermRolesManage); err != nil {
+			auth.Deny(w, r, err)
+			return
+		}
+		if err := auth.CheckRole(*patch.Role); err != nil {
+			auth.WriteError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if patch.Age != nil && *patch.Age < 0 {
+		auth.WriteError(w, r, http.StatusBadRequest, core.ErrInvalid.Error())
+		return
+	}
+	if patch.Age != nil {
+		updated, err := repoUpdateAge(r.Context(), userId, *patch.Age) //вносим обновление в хранилище пользователей
+		switch {
+		case err == nil:
+			user = updated
+		case errors.Is(err, core.ErrAgeDerived): //возраст вычисляется по дате рождения
+			auth.WriteError(w, r, http.StatusConflict, err.Error())
+			return
+		case errors.Is(err, core.ErrNotFound):
+			auth.WriteError(w, r, http.StatusNotFound, err.Error())
+			return
+		default:
+			auth.WriteError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if patch.Role != nil {
+		authService.SetRole(strconv.Itoa(userId), *patch.Role) //роль уже проверена
+	}
+	writeAdminJSON(w, http.StatusOK, adminView(userId, user))
+}
+
+// 3. Заблокировать пользователя: вход и выданные токены перестают действовать
+func adminUserSuspend(w http.ResponseWriter, r *http.Request) {
+	userId, user, ok := adminFindUser(w, r)
+	if !ok {
+		return
+	}
+	authService.Suspend(strconv.Itoa(userId))
+	writeAdminJSON(w, http.StatusOK, adminView(userId, user))
+}
+
+// 4. Снять блокировку пользователя
+func adminUserReinstate(w http.ResponseWriter, r *http.Request) {
+	userId, user, ok := adminFindUser(w, r)
+	if !ok {
+		return
+	}
+	authService.Reinstate(strconv.Itoa(userId))
+	writeAdminJSON(w, http.StatusOK, adminView(userId, user))
 }
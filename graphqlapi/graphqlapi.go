@@ -0,0 +1,238 @@
+// Package graphqlapi - GraphQL поверх общего хранилища core: пользователи с вложенными
+// друзьями на любую глубину, дружба и изменения. Друзья загружаются пакетами (по одному
+// обращению к хранилищу на уровень вложенности), глубина и стоимость запроса ограничены.
+package graphqlapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+
+	"Network-exchange/auth"
+	"Network-exchange/core"
+	"Network-exchange/logging"
+	"Network-exchange/secure"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// Path - адрес GraphQL (GET - только запросы, POST - запросы и изменения)
+const Path = "/graphql"
+
+// Ограничения запроса по умолчанию
+const (
+	DefaultMaxDepth      = 8     //вложенность полей
+	DefaultMaxComplexity = 10000 //оценка числа полей в ответе
+	DefaultMaxMutations  = 1     //изменений в одной операции: каждое расходует лимит POST /graphql
+)
+
+// API - схема GraphQL и обработчик запросов
+type API struct {
+	Store *core.Store
+	Auth  *auth.Service
+	//Subject - владелец пользователя в правилах доступа и логин его учетной записи
+	//(в "Джин" - имя, в "Горилле" - ID)
+	Subject func(core.User) string
+
+	MaxDepth      int //0 - без ограничения
+	MaxComplexity int //0 - без ограничения
+	MaxMutations  int //0 - без ограничения
+
+	schema graphql.Schema
+}
+
+// New создает API с ограничениями из GRAPHQL_MAX_DEPTH, GRAPHQL_MAX_COMPLEXITY и GRAPHQL_MAX_MUTATIONS
+// (по умолчанию DefaultMaxDepth, DefaultMaxComplexity и DefaultMaxMutations)
+func New(store *core.Store, authService *auth.Service, subject func(core.User) string) *API {
+	a := &API{Store: store, Auth: authService, Subject: subject,
+		MaxDepth: DefaultMaxDepth, MaxComplexity: DefaultMaxComplexity, MaxMutations: DefaultMaxMutations}
+	if v, err := strconv.Atoi(os.Getenv("GRAPHQL_MAX_DEPTH")); err == nil && v >= 0 {
+		a.MaxDepth = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("GRAPHQL_MAX_COMPLEXITY")); err == nil && v >= 0 {
+		a.MaxComplexity = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("GRAPHQL_MAX_MUTATIONS")); err == nil && v >= 0 {
+		a.MaxMutations = v
+	}
+	schema, err := a.newSchema()
+	if err != nil {
+		panic("graphqlapi: " + err.Error()) //ошибка в описании схемы
+	}
+	a.schema = schema
+	return a
+}
+
+// Request - запрос GraphQL (тело POST или параметры GET)
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// ServeHTTP выполняет запрос: 200 - запрос выполнен (ошибки полей - в errors),
+// 400 - запрос не разобран, не прошел проверку по схеме или превысил ограничения
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	if r.Method == http.MethodGet {
+		q := r.URL.Query()
+		req.Query, req.OperationName = q.Get("query"), q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				writeErrors(w, http.StatusBadRequest, errors.New("variables - некорректный JSON"))
+				return
+			}
+		}
+	} else if err := secure.DecodeJSON(r, &req); err != nil {
+		if secure.TooLarge(err) {
+			secure.WriteTooLarge(w, r)
+		} else {
+			writeErrors(w, http.StatusBadRequest, errors.New("некорректный JSON"))
+		}
+		return
+	}
+	if req.Query == "" {
+		writeErrors(w, http.StatusBadRequest, errors.New("не задан запрос (query)"))
+		return
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL"})})
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, err)
+		return
+	}
+	if v := graphql.ValidateDocument(&a.schema, doc, nil); !v.IsValid {
+		writeJSON(w, http.StatusBadRequest, &graphql.Result{Errors: v.Errors})
+		return
+	}
+	op := operation(doc, req.OperationName)
+	if op == nil {
+		writeErrors(w, http.StatusBadRequest, errors.New("операция не найдена: "+req.OperationName))
+		return
+	}
+	if r.Method == http.MethodGet && op.Operation != ast.OperationTypeQuery {
+		//изменения только через POST: GET не должен менять данные (CSRF, кеши)
+		writeErrors(w, http.StatusMethodNotAllowed, errors.New("изменения принимаются только методом POST"))
+		return
+	}
+	if err := a.checkLimits(doc, op, req.Variables); err != nil {
+		writeErrors(w, http.StatusBadRequest, err)
+		return
+	}
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        a.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       withLoader(r.Context(), a.Store, op.Operation == ast.OperationTypeMutation),
+	})
+	writeJSON(w, http.StatusOK, result)
+}
+
+// operation - выполняемая операция документа: по имени или единственная
+func operation(doc *ast.Document, name string) *ast.OperationDefinition {
+	var found *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if name == "" && found != nil {
+			return nil //несколько операций - нужно operationName
+		}
+		if name == "" || op.Name != nil && op.Name.Value == name {
+			found = op
+		}
+	}
+	return found
+}
+
+func writeJSON(w http.ResponseWriter, status int, result *graphql.Result) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
+
+func writeErrors(w http.ResponseWriter, status int, errs ...error) {
+	formatted := gqlerrors.FormatErrors(errs...)
+	for i, err := range errs {
+		var e *Error
+		if errors.As(err, &e) { //ошибки вне полей: код из extensions нужно добавить самим
+			formatted[i].Extensions = e.Extensions()
+		}
+	}
+	writeJSON(w, status, &graphql.Result{Errors: formatted})
+}
+
+// Коды ошибок в extensions.code
+const (
+	CodeBadInput        = "BAD_USER_INPUT"
+	CodeNotFound        = "NOT_FOUND"
+	CodeConflict        = "CONFLICT"
+	CodeUnauthenticated = "UNAUTHENTICATED"
+	CodeForbidden       = "FORBIDDEN"
+	CodeInternal        = "INTERNAL_SERVER_ERROR"
+)
+
+// Error - ошибка поля с кодом в extensions
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Extensions - дополнительные сведения об ошибке в ответе
+func (e *Error) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.Code}
+}
+
+// fail переводит ошибку хранилища в ошибку поля
+func fail(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, core.ErrNotFound), errors.Is(err, core.ErrNotFriends):
+		return &Error{CodeNotFound, err.Error()}
+	case errors.Is(err, core.ErrNameTaken), errors.Is(err, core.ErrAlreadyFriends), errors.Is(err, core.ErrSelfFriend),
+		errors.Is(err, core.ErrAgeDerived), errors.Is(err, auth.ErrLoginTaken), errors.Is(err, core.ErrEmailTaken):
+		return &Error{CodeConflict, err.Error()}
+	case errors.Is(err, core.ErrInvalid), errors.Is(err, core.ErrInvalidProfile), errors.Is(err, core.ErrInvalidFriendship):
+		return &Error{CodeBadInput, err.Error()}
+	case errors.Is(err, core.ErrBlocked):
+		return &Error{CodeForbidden, err.Error()}
+	default:
+		logging.FromContext(ctx).Error("ошибка хранилища", "error", err)
+		return &Error{CodeInternal, "внутренняя ошибка сервера"}
+	}
+}
+
+// account занимает логин нового пользователя вместе с его созданием; без пароля - nil
+func (a *API) account(password string) (core.Reserve, error) {
+	if password == "" {
+		return nil, nil
+	}
+	p, err := a.Auth.Prepare(password, auth.RoleUser)
+	if err != nil {
+		return nil, err
+	}
+	return func(u core.User) error { return p.Register(a.Subject(u)) }, nil
+}
+
+// deny - отказ в доступе: UNAUTHENTICATED для анонимного запроса, иначе FORBIDDEN
+func deny(err error) error {
+	switch {
+	case errors.Is(err, auth.ErrUnauthenticated), errors.Is(err, auth.ErrInvalidToken):
+		return &Error{CodeUnauthenticated, "требуется вход: заголовок Authorization: Bearer <token>"}
+	case errors.Is(err, auth.ErrSuspended):
+		return &Error{CodeForbidden, err.Error()}
+	default:
+		return &Error{CodeForbidden, auth.ErrForbidden.Error()}
+	}
+}
@@ -0,0 +1,129 @@
+package graphqlapi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// CodeTooComplex - запрос превысил ограничения глубины, стоимости или числа изменений
+const CodeTooComplex = "QUERY_TOO_COMPLEX"
+
+// listSizes - поля-списки и аргумент, задающий их длину; без аргумента длина
+// оценивается значением по умолчанию (те же пределы применяют распознаватели)
+var listSizes = map[string]struct {
+	arg string
+	def int
+	max int
+}{
+	"friends": {"first", DefaultLimit, MaxLimit},
+	"users":   {"limit", DefaultLimit, MaxLimit},
+}
+
+// checkLimits оценивает операцию до выполнения: глубина - наибольшая вложенность полей,
+// стоимость - число полей в ответе, где поля внутри списка умножаются на его длину.
+// Служебные поля (__schema, __typename, ...) не учитываются. Число изменений ограничено
+// отдельно: иначе псевдонимы (a: createUser, b: createUser) обходят лимит частоты запросов.
+func (a *API) checkLimits(doc *ast.Document, op *ast.OperationDefinition, vars map[string]interface{}) error {
+	m := measure{fragments: make(map[string]*ast.FragmentDefinition), vars: vars}
+	for _, def := range doc.Definitions {
+		if f, ok := def.(*ast.FragmentDefinition); ok {
+			m.fragments[f.Name.Value] = f
+		}
+	}
+	if op.Operation == ast.OperationTypeMutation && a.MaxMutations > 0 {
+		if n := m.fields(op.SelectionSet); n > a.MaxMutations {
+			return &Error{CodeTooComplex, fmt.Sprintf("изменений в запросе %d, допустимо %d", n, a.MaxMutations)}
+		}
+	}
+	depth, cost := m.selections(op.SelectionSet)
+	if a.MaxDepth > 0 && depth > a.MaxDepth {
+		return &Error{CodeTooComplex, fmt.Sprintf("глубина запроса %d больше допустимой %d", depth, a.MaxDepth)}
+	}
+	if a.MaxComplexity > 0 && cost > float64(a.MaxComplexity) {
+		return &Error{CodeTooComplex, fmt.Sprintf("стоимость запроса %.0f больше допустимой %d", cost, a.MaxComplexity)}
+	}
+	return nil
+}
+
+type measure struct {
+	fragments map[string]*ast.FragmentDefinition
+	vars      map[string]interface{}
+}
+
+// selections - глубина и стоимость набора полей (циклы фрагментов отсекает проверка по схеме)
+func (m measure) selections(set *ast.SelectionSet) (depth int, cost float64) {
+	if set == nil {
+		return 0, 0
+	}
+	for _, sel := range set.Selections {
+		var d int
+		var c float64
+		switch s := sel.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(s.Name.Value, "__") {
+				continue
+			}
+			d, c = m.selections(s.SelectionSet)
+			d, c = d+1, 1+float64(m.size(s))*c
+		case *ast.InlineFragment:
+			d, c = m.selections(s.SelectionSet)
+		case *ast.FragmentSpread:
+			if f := m.fragments[s.Name.Value]; f != nil {
+				d, c = m.selections(f.SelectionSet)
+			}
+		}
+		depth, cost = max(depth, d), cost+c
+	}
+	return depth, cost
+}
+
+// fields - число полей верхнего уровня с учетом фрагментов (для изменений - число изменений)
+func (m measure) fields(set *ast.SelectionSet) int {
+	if set == nil {
+		return 0
+	}
+	n := 0
+	for _, sel := range set.Selections {
+		switch s := sel.(type) {
+		case *ast.Field:
+			if !strings.HasPrefix(s.Name.Value, "__") {
+				n++
+			}
+		case *ast.InlineFragment:
+			n += m.fields(s.SelectionSet)
+		case *ast.FragmentSpread:
+			if f := m.fragments[s.Name.Value]; f != nil {
+				n += m.fields(f.SelectionSet)
+			}
+		}
+	}
+	return n
+}
+
+// size - ожидаемая длина списка, который возвращает поле (1 - не список)
+func (m measure) size(f *ast.Field) int {
+	list, ok := listSizes[f.Name.Value]
+	if !ok {
+		return 1
+	}
+	n := list.def
+	for _, arg := range f.Arguments {
+		if arg.Name.Value != list.arg {
+			continue
+		}
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			if i, err := strconv.Atoi(v.Value); err == nil && i > 0 {
+				n = i
+			}
+		case *ast.Variable:
+			if i, ok := m.vars[v.Name.Value].(float64); ok && i > 0 { //числа из JSON
+				n = int(i)
+			}
+		}
+	}
+	return min(n, list.max)
+}
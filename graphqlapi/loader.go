@@ -0,0 +1,125 @@
+package graphqlapi
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"Network-exchange/core"
+)
+
+// loader собирает ID пользователей, нужных полям одного уровня запроса, и загружает
+// их одним обращением к хранилищу. Поле регистрирует ID и возвращает отложенное значение
+// (thunk); исполнитель вычисляет отложенные значения по уровням, поэтому первое из них
+// загружает весь уровень, а остальные берут пользователей из кеша. Кеш живет один запрос.
+//
+// Изменения выполняются по очереди, и ответ на каждое должен отражать данные сразу после
+// него, а не после всех изменений запроса - поэтому в них (eager) значения не откладываются.
+type loader struct {
+	ctx     context.Context
+	store   *core.Store
+	eager   bool
+	mu      sync.Mutex
+	pending []int
+	users   map[int]*core.User //nil - пользователя нет
+}
+
+type loaderKey struct{}
+
+// withLoader - контекст запроса со своим загрузчиком
+func withLoader(ctx context.Context, store *core.Store, eager bool) context.Context {
+	return context.WithValue(ctx, loaderKey{}, &loader{ctx: ctx, store: store, eager: eager, users: make(map[int]*core.User)})
+}
+
+func loaderFrom(ctx context.Context) *loader {
+	return ctx.Value(loaderKey{}).(*loader)
+}
+
+// many - отложенный список пользователей ids в том же порядке (удаленные пропускаются)
+func (l *loader) many(ids []int) interface{} {
+	l.want(ids)
+	return l.thunk(func() (interface{}, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.flush(ids)
+		out := make([]core.User, 0, len(ids))
+		for _, id := range ids {
+			if u := l.users[id]; u != nil {
+				out = append(out, *u)
+			}
+		}
+		return out, nil
+	})
+}
+
+// one - отложенный пользователь id (nil - нет такого)
+func (l *loader) one(id int) interface{} {
+	l.want([]int{id})
+	return l.thunk(func() (interface{}, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.flush([]int{id})
+		if u := l.users[id]; u != nil {
+			return *u, nil
+		}
+		return nil, nil
+	})
+}
+
+// thunk - отложенное значение или, в изменениях, уже вычисленное
+func (l *loader) thunk(get func() (interface{}, error)) interface{} {
+	if l.eager {
+		v, _ := get()
+		return v
+	}
+	return get
+}
+
+// prime кладет в кеш уже загруженного пользователя
+func (l *loader) prime(u core.User) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.users[u.ID] = &u
+}
+
+// forget убирает пользователей из кеша после изменения
+func (l *loader) forget(ids ...int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, id := range ids {
+		delete(l.users, id)
+	}
+}
+
+func (l *loader) want(ids []int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, id := range ids {
+		if _, ok := l.users[id]; !ok {
+			l.pending = append(l.pending, id)
+		}
+	}
+}
+
+// flush загружает накопленные ID одним вызовом Store.GetMany, если среди них есть нужные
+// полю ids. Пока вычисляется уровень, поля следующего уже регистрируют свои ID - проверка
+// не дает им уйти отдельной пачкой раньше, чем соберется весь уровень. Вызывается под l.mu.
+func (l *loader) flush(need []int) {
+	if !slices.ContainsFunc(need, func(id int) bool { _, ok := l.users[id]; return !ok }) {
+		return
+	}
+	var ids []int
+	for _, id := range l.pending {
+		if _, ok := l.users[id]; !ok {
+			ids = append(ids, id)
+			l.users[id] = nil //повторы в одной пачке
+		}
+	}
+	l.pending = l.pending[:0]
+	if len(ids) == 0 {
+		return
+	}
+	for id, u := range l.store.GetMany(l.ctx, ids) {
+		l.users[id] = &u
+	}
+}
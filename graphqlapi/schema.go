@@ -0,0 +1,275 @@
+package graphqlapi
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"Network-exchange/auth"
+	"Network-exchange/core"
+	"Network-exchange/logging"
+
+	"github.com/graphql-go/graphql"
+)
+
+// Ограничения списков: пользователей (users, limit) и друзей (friends, first)
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// friendship - результат befriend
+type friendship struct {
+	userID, friendID int
+	created          bool
+}
+
+// userPage - результат users
+type userPage struct {
+	items []core.User
+	total int
+}
+
+func (a *API) newSchema() (graphql.Schema, error) {
+	var user *graphql.Object //friends ссылается на сам тип: поля описываются отложенно
+	user = graphql.NewObject(graphql.ObjectConfig{
+		Name:        "User",
+		Description: "Пользователь",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":   {Type: graphql.NewNonNull(graphql.Int), Resolve: userField(func(u core.User) interface{} { return u.ID })},
+				"name": {Type: graphql.NewNonNull(graphql.String), Resolve: userField(func(u core.User) interface{} { return u.Name })},
+				"age":  {Type: graphql.NewNonNull(graphql.Int), Resolve: userField(func(u core.User) interface{} { return u.Age })},
+				"friendsCount": {Type: graphql.NewNonNull(graphql.Int),
+					Resolve: userField(func(u core.User) interface{} { return len(u.Friends) })},
+				"friends": {
+					Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(user))),
+					Description: "Друзья в порядке появления дружбы",
+					Args: graphql.FieldConfigArgument{
+						"first": {Type: graphql.Int, Description: "только первые first друзей (по умолчанию 50, не больше 500)"},
+					},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						ids, n := p.Source.(core.User).Friends, DefaultLimit
+						if first, ok := p.Args["first"].(int); ok && first >= 0 {
+							n = min(first, MaxLimit)
+						}
+						if n < len(ids) {
+							ids = ids[:n]
+						}
+						return loaderFrom(p.Context).many(ids), nil
+					},
+				},
+			}
+		}),
+	})
+
+	friendshipType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Friendship",
+		Description: "Дружба двух пользователей",
+		Fields: graphql.Fields{
+			"user": {Type: graphql.NewNonNull(user), Description: "инициатор",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loaderFrom(p.Context).one(p.Source.(friendship).userID), nil
+				}},
+			"friend": {Type: graphql.NewNonNull(user),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loaderFrom(p.Context).one(p.Source.(friendship).friendID), nil
+				}},
+			"created": {Type: graphql.NewNonNull(graphql.Boolean), Description: "false - уже были друзьями",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(friendship).created, nil
+				}},
+		},
+	})
+
+	page := graphql.NewObject(graphql.ObjectConfig{
+		Name: "UserPage",
+		Fields: graphql.Fields{
+			"items": {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(user))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source.(userPage).items, nil }},
+			"total": {Type: graphql.NewNonNull(graphql.Int), Description: "всего подходящих",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source.(userPage).total, nil }},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"user": {
+				Type: user,
+				Args: graphql.FieldConfigArgument{"id": {Type: graphql.NewNonNull(graphql.Int)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loaderFrom(p.Context).one(p.Args["id"].(int)), nil
+				},
+			},
+			"userByName": {
+				Type:        user,
+				Description: "Пользователь по имени (при повторах - с меньшим ID)",
+				Args:        graphql.FieldConfigArgument{"name": {Type: graphql.NewNonNull(graphql.String)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					u, err := a.Store.ByName(p.Context, p.Args["name"].(string))
+					if errors.Is(err, core.ErrNotFound) {
+						return nil, nil
+					} else if err != nil {
+						return nil, fail(p.Context, err)
+					}
+					loaderFrom(p.Context).prime(u)
+					return u, nil
+				},
+			},
+			"users": {
+				Type:        graphql.NewNonNull(page),
+				Description: "Поиск пользователей по возрастанию ID",
+				Args: graphql.FieldConfigArgument{
+					"name":   {Type: graphql.String, Description: "часть имени без учета регистра"},
+					"minAge": {Type: graphql.Int},
+					"maxAge": {Type: graphql.Int},
+					"limit":  {Type: graphql.Int, Description: "размер страницы (по умолчанию 50, не больше 500)"},
+					"offset": {Type: graphql.Int},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					f := core.Filter{Limit: DefaultLimit}
+					f.Name, _ = p.Args["name"].(string)
+					f.MinAge, _ = p.Args["minAge"].(int)
+					f.MaxAge, _ = p.Args["maxAge"].(int)
+					f.Offset, _ = p.Args["offset"].(int)
+					if n, ok := p.Args["limit"].(int); ok && n > 0 {
+						f.Limit = min(n, MaxLimit)
+					}
+					if f.Offset < 0 {
+						return nil, &Error{CodeBadInput, "offset не может быть отрицательным"}
+					}
+					items, total := a.Store.List(p.Context, f)
+					l := loaderFrom(p.Context)
+					for _, u := range items {
+						l.prime(u)
+					}
+					return userPage{items: items, total: total}, nil
+				},
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createUser": {
+				Type:        graphql.NewNonNull(user),
+				Description: "Новый пользователь; с паролем - и учетная запись для входа",
+				Args: graphql.FieldConfigArgument{
+					"name":     {Type: graphql.NewNonNull(graphql.String)},
+					"age":      {Type: graphql.NewNonNull(graphql.Int)},
+					"password": {Type: graphql.String},
+				},
+				Resolve: a.createUser,
+			},
+			"befriend": {
+				Type:        graphql.NewNonNull(friendshipType),
+				Description: "Дружба от имени id (администратор - от имени любого)",
+				Args:        pairArgs(),
+				Resolve:     a.befriend,
+			},
+			"unfriend": {
+				Type:        graphql.NewNonNull(graphql.Boolean),
+				Description: "Прекращение дружбы от имени id",
+				Args:        pairArgs(),
+				Resolve:     a.unfriend,
+			},
+			"updateAge": {
+				Type:        graphql.NewNonNull(user),
+				Description: "Изменение возраста: только себе (администратор - любому)",
+				Args: graphql.FieldConfigArgument{
+					"id":  {Type: graphql.NewNonNull(graphql.Int)},
+					"age": {Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: a.updateAge,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+func userField(get func(core.User) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return get(p.Source.(core.User)), nil
+	}
+}
+
+func pairArgs() graphql.FieldConfigArgument {
+	return graphql.FieldConfigArgument{
+		"id":       {Type: graphql.NewNonNull(graphql.Int)},
+		"friendId": {Type: graphql.NewNonNull(graphql.Int)},
+	}
+}
+
+func (a *API) createUser(p graphql.ResolveParams) (interface{}, error) {
+	ctx := p.Context
+	password, _ := p.Args["password"].(string)
+	account, err := a.account(password)
+	if err != nil {
+		return nil, &Error{CodeBadInput, err.Error()}
+	}
+	user, err := a.Store.Create(ctx, p.Args["name"].(string), p.Args["age"].(int), account)
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	logging.SetUser(ctx, strconv.Itoa(user.ID))
+	loaderFrom(ctx).prime(user)
+	return user, nil
+}
+
+func (a *API) befriend(p graphql.ResolveParams) (interface{}, error) {
+	ctx := p.Context
+	user, err := a.owned(ctx, p.Args["id"].(int), auth.PermUsersWrite)
+	if err != nil {
+		return nil, err
+	}
+	fid := p.Args["friendId"].(int)
+	err = a.Store.Befriend(ctx, user.ID, fid)
+	if err != nil && !errors.Is(err, core.ErrAlreadyFriends) {
+		return nil, fail(ctx, err)
+	}
+	loaderFrom(ctx).forget(user.ID, fid)
+	return friendship{userID: user.ID, friendID: fid, created: err == nil}, nil
+}
+
+func (a *API) unfriend(p graphql.ResolveParams) (interface{}, error) {
+	ctx := p.Context
+	user, err := a.owned(ctx, p.Args["id"].(int), auth.PermUsersWrite)
+	if err != nil {
+		return nil, err
+	}
+	fid := p.Args["friendId"].(int)
+	if err := a.Store.Unfriend(ctx, user.ID, fid); err != nil {
+		return nil, fail(ctx, err)
+	}
+	loaderFrom(ctx).forget(user.ID, fid)
+	return true, nil
+}
+
+func (a *API) updateAge(p graphql.ResolveParams) (interface{}, error) {
+	ctx := p.Context
+	user, err := a.owned(ctx, p.Args["id"].(int), auth.PermUsersWrite)
+	if err != nil {
+		return nil, err
+	}
+	if user, err = a.Store.SetAge(ctx, user.ID, p.Args["age"].(int)); err != nil {
+		return nil, fail(ctx, err)
+	}
+	loaderFrom(ctx).prime(user)
+	return user, nil
+}
+
+// owned - пользователь id, от имени которого вправе действовать владелец запроса
+func (a *API) owned(ctx context.Context, id int, perm auth.Permission) (core.User, error) {
+	logging.SetUser(ctx, strconv.Itoa(id))
+	user, err := a.Store.Get(ctx, id)
+	if err != nil {
+		return core.User{}, fail(ctx, err)
+	}
+	if err := auth.Authorize(ctx, a.Subject(user), perm); err != nil {
+		return core.User{}, deny(err)
+	}
+	return user, nil
+}
@@ -0,0 +1,58 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+
+	"Network-exchange/auth"
+	"Network-exchange/core"
+	"Network-exchange/grpcapi/pb"
+
+	"google.golang.org/grpc"
+)
+
+// AddFriend - дружбу предлагает только сам user_id (администратор - за любого)
+func (a *API) AddFriend(ctx context.Context, req *pb.FriendshipRequest) (*pb.AddFriendResponse, error) {
+	user, err := a.owned(ctx, req.GetUserId(), auth.PermUsersWrite)
+	if err != nil {
+		return nil, err
+	}
+	switch err := a.Store.Befriend(ctx, user.ID, int(req.GetFriendId())); {
+	case errors.Is(err, core.ErrAlreadyFriends):
+		return &pb.AddFriendResponse{Created: false}, nil
+	case err != nil:
+		return nil, fail(ctx, err)
+	}
+	return &pb.AddFriendResponse{Created: true}, nil
+}
+
+// RemoveFriend - прекратить дружбу
+func (a *API) RemoveFriend(ctx context.Context, req *pb.FriendshipRequest) (*pb.RemoveFriendResponse, error) {
+	user, err := a.owned(ctx, req.GetUserId(), auth.PermUsersWrite)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.Store.Unfriend(ctx, user.ID, int(req.GetFriendId())); err != nil {
+		return nil, fail(ctx, err)
+	}
+	return &pb.RemoveFriendResponse{}, nil
+}
+
+// ListFriends - друзья пользователя потоком
+func (a *API) ListFriends(req *pb.ListFriendsRequest, stream grpc.ServerStreamingServer[pb.User]) error {
+	ctx := stream.Context()
+	user, err := a.Store.Get(ctx, int(req.GetUserId()))
+	if err != nil {
+		return fail(ctx, err)
+	}
+	for _, fid := range user.Friends {
+		friend, err := a.Store.Get(ctx, fid)
+		if err != nil {
+			continue //удален между чтениями
+		}
+		if err := stream.Send(toPB(friend)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+// Package grpcapi - API пользователей и дружбы по gRPC (службы netx.v1.UserService
+// и netx.v1.FriendshipService) поверх общего хранилища core. Вызовы gRPC принимаются
+// на том же порту, что и REST: по HTTP/2 с TLS или без него (h2c).
+package grpcapi
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative pb/users.proto
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"Network-exchange/auth"
+	"Network-exchange/core"
+	"Network-exchange/grpcapi/pb"
+	"Network-exchange/logging"
+	"Network-exchange/ratelimit"
+	"Network-exchange/tracing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// Размер страницы ListUsers, как в /v2 и GraphQL
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// API - реализация служб gRPC
+type API struct {
+	pb.UnimplementedUserServiceServer
+	pb.UnimplementedFriendshipServiceServer
+
+	Store *core.Store
+	Auth  *auth.Service
+	//Subject - владелец пользователя в правилах доступа и логин его учетной записи
+	//(в "Джин" - имя, в "Горилле" - ID)
+	Subject func(core.User) string
+}
+
+// New создает службы gRPC
+func New(store *core.Store, authService *auth.Service, subject func(core.User) string) *API {
+	return &API{Store: store, Auth: authService, Subject: subject}
+}
+
+// Server - сервер gRPC со службами, спанами, журналом, проверкой владельца запроса
+// и лимитами limiter (nil - без лимитов)
+func (a *API) Server(limiter *ratelimit.Limiter) *grpc.Server {
+	unary := []grpc.UnaryServerInterceptor{tracing.UnaryServer(), logging.UnaryServer(), unaryAuth}
+	stream := []grpc.StreamServerInterceptor{tracing.StreamServer(), logging.StreamServer(), streamAuth}
+	if limiter != nil {
+		unary = append(unary, limiter.UnaryServer())
+		stream = append(stream, limiter.StreamServer())
+	}
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(unary...), grpc.ChainStreamInterceptor(stream...))
+	pb.RegisterUserServiceServer(srv, a)
+	pb.RegisterFriendshipServiceServer(srv, a)
+	reflection.Register(srv) //для grpcurl и подобных клиентов
+	return srv
+}
+
+// Handler направляет вызовы gRPC (HTTP/2, Content-Type application/grpc) серверу srv,
+// остальные запросы - next. Владелец вызова определяется так же, как для REST:
+// Bearer-токен, ключ API или сертификат клиента.
+func (a *API) Handler(srv *grpc.Server, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		id, ok, err := a.Auth.Identify(r)
+		ctx := r.Context()
+		if err != nil {
+			ctx = context.WithValue(ctx, authErrKey{}, err) //ответ - в перехватчике
+		} else if ok {
+			ctx = auth.WithIdentity(ctx, id)
+		}
+		srv.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+type authErrKey struct{}
+
+// недействительный токен или ключ - Unauthenticated до вызова службы
+func authFailed(ctx context.Context) error {
+	if err, ok := ctx.Value(authErrKey{}).(error); ok {
+		return deny(err)
+	}
+	return nil
+}
+
+func unaryAuth(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := authFailed(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func streamAuth(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := authFailed(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// deny - отказ в доступе: Unauthenticated для анонимного вызова и недействительного токена,
+// иначе PermissionDenied
+func deny(err error) error {
+	switch {
+	case errors.Is(err, auth.ErrUnauthenticated), errors.Is(err, auth.ErrInvalidToken):
+		return status.Error(codes.Unauthenticated, "требуется вход: метаданные authorization: Bearer <token>")
+	case errors.Is(err, auth.ErrSuspended):
+		return status.Error(codes.PermissionDenied, err.Error())
+	default:
+		return status.Error(codes.PermissionDenied, auth.ErrForbidden.Error())
+	}
+}
+
+// fail переводит ошибку хранилища в код gRPC
+func fail(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, core.ErrNotFound), errors.Is(err, core.ErrNotFriends):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, core.ErrNameTaken), errors.Is(err, core.ErrAlreadyFriends), errors.Is(err, auth.ErrLoginTaken):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, core.ErrBlocked):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, core.ErrSelfFriend), errors.Is(err, core.ErrAgeDerived):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, core.ErrInvalid):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	default:
+		logging.FromContext(ctx).Error("ошибка хранилища", "error", err)
+		return status.Error(codes.Internal, "внутренняя ошибка сервера")
+	}
+}
+
+// account - учетная запись для нового пользователя (nil - пароль не задан)
+func (a *API) account(password string) (core.Reserve, error) {
+	if password == "" {
+		return nil, nil
+	}
+	p, err := a.Auth.Prepare(password, auth.RoleUser)
+	if err != nil {
+		return nil, err
+	}
+	return func(u core.User) error { return p.Register(a.Subject(u)) }, nil
+}
+
+// owned - пользователь id, от имени которого вправе действовать владелец вызова
+func (a *API) owned(ctx context.Context, id int64, perm auth.Permission) (core.User, error) {
+	logging.SetUser(ctx, strconv.FormatInt(id, 10))
+	user, err := a.Store.Get(ctx, int(id))
+	if err != nil {
+		return core.User{}, fail(ctx, err)
+	}
+	if err := auth.Authorize(ctx, a.Subject(user), perm); err != nil {
+		return core.User{}, deny(err)
+	}
+	return user, nil
+}
+
+func toPB(u core.User) *pb.User {
+	friends := make([]int64, len(u.Friends))
+	for i, f := range u.Friends {
+		friends[i] = int64(f)
+	}
+	return &pb.User{Id: int64(u.ID), Name: u.Name, Age: int32(u.Age), Friends: friends}
+}
@@ -0,0 +1,131 @@
+package grpcapi
+
+import (
+	"context"
+	"slices"
+	"strconv"
+
+	"Network-exchange/auth"
+	"Network-exchange/core"
+	"Network-exchange/grpcapi/pb"
+	"Network-exchange/logging"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ListUsers - подходящие пользователи потоком, по одному сообщению
+func (a *API) ListUsers(req *pb.ListUsersRequest, stream grpc.ServerStreamingServer[pb.User]) error {
+	ctx := stream.Context()
+	if req.GetOffset() < 0 {
+		return status.Error(codes.InvalidArgument, "offset не может быть отрицательным")
+	}
+	f := core.Filter{
+		Name:   req.GetName(),
+		MinAge: int(req.GetMinAge()),
+		MaxAge: int(req.GetMaxAge()),
+		Limit:  DefaultLimit,
+		Offset: int(req.GetOffset()),
+	}
+	if n := int(req.GetLimit()); n > 0 {
+		f.Limit = min(n, MaxLimit)
+	}
+	users, _ := a.Store.List(ctx, f)
+	for _, u := range users {
+		if err := stream.Send(toPB(u)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetUser - пользователь по ID
+func (a *API) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.User, error) {
+	logging.SetUser(ctx, strconv.FormatInt(req.GetId(), 10))
+	user, err := a.Store.Get(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	return toPB(user), nil
+}
+
+// CreateUser - новый пользователь; с паролем - и учетная запись для входа
+func (a *API) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.User, error) {
+	account, err := a.account(req.GetPassword())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	user, err := a.Store.Create(ctx, req.GetName(), int(req.GetAge()), account)
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	logging.SetUser(ctx, strconv.Itoa(user.ID))
+	return toPB(user), nil
+}
+
+// UpdateUser - изменить возраст: только себе (администратор - любому)
+func (a *API) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.User, error) {
+	user, err := a.owned(ctx, req.GetId(), auth.PermUsersWrite)
+	if err != nil {
+		return nil, err
+	}
+	if req.Age != nil {
+		if user, err = a.Store.SetAge(ctx, user.ID, int(req.GetAge())); err != nil {
+			return nil, fail(ctx, err)
+		}
+	}
+	return toPB(user), nil
+}
+
+// DeleteUser - пометить пользователя удаленным и заблокировать учетную запись
+func (a *API) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*pb.DeleteUserResponse, error) {
+	user, err := a.owned(ctx, req.GetId(), auth.PermUsersDelete)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := a.Store.Delete(ctx, user.ID); err != nil {
+		return nil, fail(ctx, err)
+	}
+	a.Auth.Suspend(a.Subject(user))
+	return &pb.DeleteUserResponse{}, nil
+}
+
+// WatchUsers - изменения хранилища до отмены вызова клиентом (только для вошедших).
+// Отставшего клиента сервер отключает с кодом ResourceExhausted: нужно перечитать
+// данные и подписаться заново.
+func (a *API) WatchUsers(req *pb.WatchUsersRequest, stream grpc.ServerStreamingServer[pb.UserEvent]) error {
+	ctx := stream.Context()
+	if err := auth.Check(ctx); err != nil {
+		return deny(err)
+	}
+	events, cancel := a.Store.Subscribe(0)
+	defer cancel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-events:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "клиент не успевает получать изменения")
+			}
+			if e.Type.Private() || len(req.GetTypes()) > 0 && !slices.Contains(req.GetTypes(), string(e.Type)) {
+				continue
+			}
+			hidden := a.Store.HiddenFrom(ctx) //заблокировавшие вызывающего скрыты, как в ленте /events
+			if hidden[e.User.ID] || hidden[e.FriendID] {
+				continue
+			}
+			if err := stream.Send(&pb.UserEvent{
+				Seq:      e.Seq,
+				Type:     string(e.Type),
+				Time:     timestamppb.New(e.Time),
+				User:     toPB(core.Strip(e.User, hidden)),
+				FriendId: int64(e.FriendID),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package logging
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Gin - промежуточный обработчик "Джин": ID запроса и запись в журнал
+func Gin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		e := &entry{id: requestID(c.GetHeader(HeaderRequestID)), ip: c.ClientIP()}
+		c.Request = c.Request.WithContext(withEntry(c.Request.Context(), e))
+		c.Header(HeaderRequestID, e.id) //возвращаем ID клиенту
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" { //маршрут не найден
+			route = c.Request.URL.Path
+		}
+		logRequest(c.Request.Context(), e,
+			slog.String("method", c.Request.Method),
+			slog.String("route", route),
+			slog.Int("status", c.Writer.Status()),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+		)
+	}
+}
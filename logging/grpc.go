@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// grpcEntry - поля вызова gRPC: ID из метаданных x-request-id (или новый),
+// который возвращается клиенту в заголовках ответа
+func grpcEntry(ctx context.Context) (context.Context, *entry) {
+	var incoming string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(strings.ToLower(HeaderRequestID)); len(v) > 0 {
+			incoming = v[0]
+		}
+	}
+	e := &entry{id: requestID(incoming)}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		e.ip, _, _ = net.SplitHostPort(p.Addr.String())
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(HeaderRequestID), e.id))
+	return withEntry(ctx, e), e
+}
+
+func logCall(ctx context.Context, e *entry, method string, start time.Time, err error) {
+	logRequest(ctx, e,
+		slog.String("method", "GRPC"),
+		slog.String("route", method),
+		slog.String("code", status.Code(err).String()),
+		slog.Duration("latency", time.Since(start)),
+		slog.String("client_ip", e.ip),
+	)
+}
+
+// UnaryServer - перехватчик gRPC: ID запроса и запись в журнал
+func UnaryServer() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx, e := grpcEntry(ctx)
+		resp, err := handler(ctx, req)
+		logCall(ctx, e, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamServer - перехватчик потоковых вызовов gRPC (запись - после закрытия потока)
+func StreamServer() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx, e := grpcEntry(ss.Context())
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		logCall(ctx, e, info.FullMethod, start, err)
+		return err
+	}
+}
+
+// serverStream - поток с дополненным контекстом
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context { return s.ctx }
@@ -0,0 +1,126 @@
+// Package logging — структурированное журналирование (log/slog) и сквозной
+// идентификатор запроса (X-Request-ID), общие для сервисов на "Джин" и "Горилле".
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// HeaderRequestID - заголовок, в котором клиент передает (а сервис возвращает) ID запроса
+const HeaderRequestID = "X-Request-ID"
+
+// максимальная длина принимаемого от клиента ID запроса
+const maxRequestIDLen = 128
+
+type ctxKey struct{}
+
+// entry - поля запроса, которые обработчики дополняют по ходу работы
+type entry struct {
+	id    string
+	ip    string //адрес клиента без порта
+	mu    sync.Mutex
+	user  string //пользователь, которого затронул запрос
+	route string //шаблон маршрута "Гориллы" (см. Route)
+}
+
+// Setup делает JSON-логгер логгером по умолчанию для всего приложения
+func Setup(w io.Writer) *slog.Logger {
+	if w == nil {
+		w = os.Stdout
+	}
+	logger := slog.New(slog.NewJSONHandler(w, nil))
+	slog.SetDefault(logger)
+	return logger
+}
+
+// NewRequestID формирует новый случайный ID запроса (32 шестнадцатеричных символа)
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// requestID принимает ID клиента, если он разумной длины и из печатных символов,
+// иначе формирует новый
+func requestID(incoming string) string {
+	if incoming == "" || len(incoming) > maxRequestIDLen {
+		return NewRequestID()
+	}
+	for _, r := range incoming {
+		if r < 0x21 || r > 0x7e { //только видимые ASCII-символы
+			return NewRequestID()
+		}
+	}
+	return incoming
+}
+
+// withEntry кладет поля запроса в контекст
+func withEntry(ctx context.Context, e *entry) context.Context {
+	return context.WithValue(ctx, ctxKey{}, e)
+}
+
+func fromContext(ctx context.Context) *entry {
+	e, _ := ctx.Value(ctxKey{}).(*entry)
+	return e
+}
+
+// RequestID возвращает ID текущего запроса ("" - вне запроса)
+func RequestID(ctx context.Context) string {
+	if e := fromContext(ctx); e != nil {
+		return e.id
+	}
+	return ""
+}
+
+// ClientIP возвращает адрес клиента текущего запроса ("" - вне запроса)
+func ClientIP(ctx context.Context) string {
+	if e := fromContext(ctx); e != nil {
+		return e.ip
+	}
+	return ""
+}
+
+// SetUser запоминает пользователя (ID или имя), которого затронул запрос
+func SetUser(ctx context.Context, user string) {
+	if e := fromContext(ctx); e != nil {
+		e.mu.Lock()
+		e.user = user
+		e.mu.Unlock()
+	}
+}
+
+func (e *entry) userName() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.user
+}
+
+func (e *entry) routeName() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.route
+}
+
+// FromContext возвращает логгер с ID текущего запроса
+func FromContext(ctx context.Context) *slog.Logger {
+	if id := RequestID(ctx); id != "" {
+		return slog.Default().With("request_id", id)
+	}
+	return slog.Default()
+}
+
+// logRequest пишет итоговую запись о выполненном запросе
+func logRequest(ctx context.Context, e *entry, attrs ...slog.Attr) {
+	if user := e.userName(); user != "" {
+		attrs = append(attrs, slog.String("user", user))
+	}
+	slog.Default().LogAttrs(ctx, slog.LevelInfo, "request", append([]slog.Attr{slog.String("request_id", e.id)}, attrs...)...)
+}
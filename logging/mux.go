@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"bufio"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// statusWriter запоминает код ответа
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap - исходный ответ для http.ResponseController (Flush в потоковых ответах)
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Hijack передает соединение обработчику (WebSocket)
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Mux - промежуточный обработчик "Гориллы": ID запроса и запись в журнал. Чтобы в журнал попадали
+// и ответы 404/405, им оборачивают весь роутер, а шаблон маршрута передает Route из router.Use.
+func Mux(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		e := &entry{id: requestID(r.Header.Get(HeaderRequestID)), ip: r.RemoteAddr}
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			e.ip = host
+		}
+		r = r.WithContext(withEntry(r.Context(), e))
+		w.Header().Set(HeaderRequestID, e.id) //возвращаем ID клиенту
+
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+
+		route := e.routeName()
+		if route == "" {
+			route = routeTemplate(r, r.URL.Path)
+		}
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		logRequest(r.Context(), e,
+			slog.String("method", r.Method),
+			slog.String("route", route),
+			slog.Int("status", sw.status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", r.RemoteAddr),
+		)
+	})
+}
+
+// Route - промежуточный обработчик "Гориллы" (router.Use): запоминает шаблон найденного маршрута
+// для записи Mux, которым обернут весь роутер
+func Route(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if e := fromContext(r.Context()); e != nil {
+			e.mu.Lock()
+			e.route = routeTemplate(r, "")
+			e.mu.Unlock()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// routeTemplate - шаблон маршрута "Гориллы" ("/users/{userId}"); вне маршрута - fallback
+func routeTemplate(r *http.Request, fallback string) string {
+	if cr := mux.CurrentRoute(r); cr != nil {
+		if tpl, err := cr.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return fallback
+}
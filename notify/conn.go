@@ -0,0 +1,209 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"Network-exchange/auth"
+	"Network-exchange/logging"
+
+	"github.com/gorilla/websocket"
+)
+
+// Настройки подключения
+const (
+	queueSize  = 32               //очередь уведомлений подключения
+	maxDropped = 256              //столько пропусков подряд - и медленный клиент отключается
+	writeWait  = 10 * time.Second //запись, которая дольше, считается зависшей
+	pongWait   = 60 * time.Second //без ответа на ping дольше - соединение потеряно
+	pingPeriod = pongWait * 9 / 10
+	maxMessage = 4 << 10 //сообщения клиента - только подписка
+)
+
+// Control - сообщение клиента: {"action":"subscribe","types":["friend.added"]}
+// или "unsubscribe"; ответ - {"type":"subscribed","types":[...]} с текущей подпиской
+type Control struct {
+	Action string   `json:"action"`
+	Types  []string `json:"types"`
+}
+
+// reply - служебный ответ клиенту
+type reply struct {
+	Type  string   `json:"type"` //subscribed или error
+	Types []string `json:"types,omitempty"`
+	Error string   `json:"error,omitempty"`
+}
+
+// conn - подключение пользователя
+type conn struct {
+	subject string
+	send    chan interface{} //Notification или reply
+
+	mu      sync.Mutex
+	types   []string //подписка
+	dropped int      //пропущено подряд из-за переполненной очереди
+	closed  bool
+}
+
+var upgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 1024} //Origin - только свой сайт
+
+// ServeHTTP - GET /ws/notifications: WebSocket только для вошедших пользователей.
+// Токен - в заголовке Authorization, а из браузера (где заголовок не задать) -
+// в параметре access_token.
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, ok := auth.FromContext(r.Context())
+	if token := r.URL.Query().Get("access_token"); !ok && token != "" {
+		withToken := r.Clone(r.Context())
+		withToken.Header.Set("Authorization", "Bearer "+token)
+		var err error
+		if id, ok, err = h.auth.Identify(withToken); err != nil {
+			auth.Deny(w, r, err)
+			return
+		}
+	}
+	if !ok {
+		auth.Deny(w, r, auth.ErrUnauthenticated)
+		return
+	}
+	ws, err := upgrader.Upgrade(w, r, nil) //на ошибку upgrader отвечает сам
+	if err != nil {
+		return
+	}
+	c := &conn{subject: id.Subject, send: make(chan interface{}, queueSize), types: slices.Clone(Types)}
+	logging.FromContext(r.Context()).Info("подключение к уведомлениям", "subject", id.Subject)
+	c.send <- reply{Type: "subscribed", Types: c.subscription()} //очередь еще пуста: до h.add уведомлений нет
+	h.add(c)
+	go c.writeLoop(ws)
+	c.readLoop(ws)
+	h.remove(c)
+	c.close()
+}
+
+// deliver ставит уведомление в очередь, не дожидаясь клиента: при переполненной очереди
+// уведомление пропускается (число пропусков придет со следующим), а после maxDropped
+// пропусков подряд клиент отключается
+func (c *conn) deliver(n Notification) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || !slices.Contains(c.types, n.Type) {
+		return
+	}
+	n.Dropped = c.dropped
+	select {
+	case c.send <- n:
+		c.dropped = 0
+	default:
+		c.dropped++
+		if c.dropped >= maxDropped {
+			c.closed = true
+			close(c.send) //writeLoop закроет соединение с кодом 1013
+		}
+	}
+}
+
+func (c *conn) subscription() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.types)
+}
+
+// control меняет подписку по сообщению клиента
+func (c *conn) control(msg Control) reply {
+	for _, t := range msg.Types {
+		if !slices.Contains(Types, t) {
+			return reply{Type: "error", Error: "неизвестный вид уведомлений: " + t}
+		}
+	}
+	c.mu.Lock()
+	switch msg.Action {
+	case "subscribe":
+		for _, t := range msg.Types {
+			if !slices.Contains(c.types, t) {
+				c.types = append(c.types, t)
+			}
+		}
+	case "unsubscribe":
+		c.types = slices.DeleteFunc(c.types, func(t string) bool { return slices.Contains(msg.Types, t) })
+	default:
+		c.mu.Unlock()
+		return reply{Type: "error", Error: "action - subscribe или unsubscribe"}
+	}
+	c.mu.Unlock()
+	return reply{Type: "subscribed", Types: c.subscription()}
+}
+
+// readLoop читает сообщения клиента до разрыва соединения; ответ на ping продлевает срок
+func (c *conn) readLoop(ws *websocket.Conn) {
+	ws.SetReadLimit(maxMessage)
+	ws.SetReadDeadline(time.Now().Add(pongWait))
+	ws.SetPongHandler(func(string) error { return ws.SetReadDeadline(time.Now().Add(pongWait)) })
+	for {
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		var msg Control
+		r := reply{Type: "error", Error: "ожидается JSON {\"action\":...,\"types\":[...]}"}
+		if json.Unmarshal(data, &msg) == nil {
+			r = c.control(msg)
+		}
+		c.mu.Lock()
+		if !c.closed {
+			select {
+			case c.send <- r:
+			default: //очередь полна - ответ не важнее уведомлений
+			}
+		}
+		c.mu.Unlock()
+	}
+}
+
+// writeLoop отправляет уведомления и ping; завершается, когда очередь закрыта
+func (c *conn) writeLoop(ws *websocket.Conn) {
+	ping := time.NewTicker(pingPeriod)
+	defer func() {
+		ping.Stop()
+		ws.Close()
+	}()
+	for {
+		select {
+		case msg, ok := <-c.send:
+			ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				code, text := websocket.CloseNormalClosure, ""
+				if c.slow() {
+					code, text = websocket.CloseTryAgainLater, "клиент не успевает получать уведомления"
+				}
+				ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, text))
+				return
+			}
+			if err := ws.WriteJSON(msg); err != nil {
+				return
+			}
+		case <-ping.C:
+			ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *conn) slow() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dropped >= maxDropped
+}
+
+// close закрывает очередь после отключения клиента
+func (c *conn) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.send)
+	}
+}
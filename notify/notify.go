@@ -0,0 +1,132 @@
+// Package notify - уведомления пользователей о дружбе по WebSocket: кто-то подружился
+// с пользователем, прекратил дружбу или удалил свою учетную запись. Уведомления строятся
+// из изменений хранилища core и доставляются всем подключениям адресата.
+package notify
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"Network-exchange/auth"
+	"Network-exchange/core"
+)
+
+// Path - адрес подключения
+const Path = "/ws/notifications"
+
+// Виды уведомлений. Дружба в сервисе взаимна и возникает сразу, без подтверждения,
+// поэтому запрос дружбы и его принятие - одно уведомление friend.added.
+const (
+	FriendAdded   = "friend.added"   //пользователь user подружился с адресатом
+	FriendRemoved = "friend.removed" //пользователь user прекратил дружбу
+	FriendDeleted = "friend.deleted" //друг user удалил учетную запись
+)
+
+// Types - все виды уведомлений (подписка нового подключения)
+var Types = []string{FriendAdded, FriendRemoved, FriendDeleted}
+
+// Person - пользователь в уведомлении
+type Person struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Notification - уведомление адресату
+type Notification struct {
+	Type    string    `json:"type"`
+	ID      uint64    `json:"id"` //номер изменения хранилища
+	Time    time.Time `json:"time"`
+	User    Person    `json:"user"`
+	Dropped int       `json:"dropped,omitempty"` //пропущено перед этим из-за переполнения очереди
+}
+
+// Hub - подключения по адресатам и рассылка уведомлений
+type Hub struct {
+	store *core.Store
+	auth  *auth.Service
+	//subject - логин пользователя, как в auth.Identity.Subject
+	//(в "Джин" - имя, в "Горилле" - ID)
+	subject func(core.User) string
+
+	mu    sync.Mutex
+	conns map[string]map[*conn]struct{} //по логину адресата
+}
+
+// New запускает рассылку уведомлений
+func New(store *core.Store, authService *auth.Service, subject func(core.User) string) *Hub {
+	h := &Hub{store: store, auth: authService, subject: subject, conns: make(map[string]map[*conn]struct{})}
+	go h.run()
+	return h
+}
+
+// run переводит изменения хранилища в уведомления; хранилище отключает отставшего
+// подписчика - тогда рассылка подписывается заново (пропущенные уведомления теряются)
+func (h *Hub) run() {
+	for {
+		ch, cancel := h.store.Subscribe(core.DefaultBuffer * 4)
+		for e := range ch {
+			h.dispatch(e)
+		}
+		cancel()
+	}
+}
+
+// dispatch рассылает уведомления об изменении e его адресатам
+func (h *Hub) dispatch(e core.Event) {
+	var (
+		typ        string
+		recipients []int
+		user       = e.User
+	)
+	switch e.Type {
+	case core.FriendshipFormed:
+		typ, recipients = FriendAdded, []int{e.FriendID}
+	case core.FriendshipEnded:
+		//дружба прекращена блокировкой - заблокированный о ней не узнает
+		//(блокировка записана в хранилище раньше, чем изменение дошло сюда)
+		if h.store.Blocks(e.User.ID, e.FriendID) {
+			return
+		}
+		typ, recipients = FriendRemoved, []int{e.FriendID}
+	case core.UserDeleted: //в журнале - только ID: имя и друзья - из записи об удалении
+		d, err := h.store.RemovedUser(context.Background(), e.User.ID)
+		if err != nil {
+			return //уже восстановлен или стерт окончательно
+		}
+		typ, recipients, user = FriendDeleted, d.User.Friends, d.User
+	default:
+		return
+	}
+	n := Notification{Type: typ, ID: e.Seq, Time: e.Time, User: Person{ID: user.ID, Name: user.Name}}
+	users := h.store.GetMany(context.Background(), recipients)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, id := range recipients {
+		u, ok := users[id]
+		if !ok {
+			continue
+		}
+		for c := range h.conns[h.subject(u)] {
+			c.deliver(n)
+		}
+	}
+}
+
+func (h *Hub) add(c *conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.conns[c.subject] == nil {
+		h.conns[c.subject] = make(map[*conn]struct{})
+	}
+	h.conns[c.subject][c] = struct{}{}
+}
+
+func (h *Hub) remove(c *conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.conns[c.subject], c)
+	if len(h.conns[c.subject]) == 0 {
+		delete(h.conns, c.subject)
+	}
+}
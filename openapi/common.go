@@ -0,0 +1,112 @@
+package openapi
+
+import "net/http"
+
+// Способы аутентификации
+const (
+	SchemeBearer    = "bearerAuth" //JWT или ключ API в Authorization: Bearer
+	SchemeAPIKey    = "apiKey"     //ключ API в X-API-Key
+	SchemeMutualTLS = "mutualTLS"  //сертификат клиента
+)
+
+// Общие схемы и ответы
+const (
+	SchemaError            = "Error"
+	SchemaValidationErrors = "ValidationErrors"
+	SchemaTokens           = "Tokens"
+	SchemaAPIKey           = "APIKey"
+	RespUnauthorized       = "Unauthorized"
+	RespTooManyRequests    = "TooManyRequests"
+	RespPayloadTooLarge    = "PayloadTooLarge"
+)
+
+// addCommon добавляет общие для сервисов компоненты и маршруты:
+// вход, токены, ключи API и саму документацию
+func addCommon(d *Document) {
+	c := &d.Components
+	c.SecuritySchemes[SchemeBearer] = &SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT",
+		Description: "access_token из /login или ключ API (nxk_...)"}
+	c.SecuritySchemes[SchemeAPIKey] = &SecurityScheme{Type: "apiKey", In: "header", Name: "X-API-Key"}
+	c.SecuritySchemes[SchemeMutualTLS] = &SecurityScheme{Type: "mutualTLS",
+		Description: "сертификат клиента; владелец запроса - CN сертификата"}
+
+	c.Schemas[SchemaError] = Object(map[string]*Schema{
+		"error":      String(),
+		"request_id": String().Describe("ID запроса (заголовок X-Request-ID)"),
+	}, "error").Open() //"Упс" и другие поля старых ответов
+	c.Schemas[SchemaValidationErrors] = Object(map[string]*Schema{
+		"error": String(),
+		"errors": Array(Object(map[string]*Schema{
+			"поле":   String().Describe("имя поля; \"тело\" - тело запроса целиком"),
+			"ошибка": String(),
+		}, "поле", "ошибка")),
+		"request_id": String(),
+	}, "errors")
+	c.Schemas[SchemaTokens] = Object(map[string]*Schema{
+		"access_token":  String().Describe("JWT"),
+		"refresh_token": String(),
+		"token_type":    String().OneOf("Bearer"),
+		"expires_in":    Integer().Describe("срок жизни access_token в секундах"),
+	}, "access_token", "refresh_token", "token_type", "expires_in")
+	c.Schemas["Login"] = Object(map[string]*Schema{
+		"login":    String(),
+		"password": String(),
+	}, "login", "password")
+	c.Schemas["RefreshToken"] = Object(map[string]*Schema{
+		"refresh_token": String(),
+	}, "refresh_token")
+	c.Schemas[SchemaAPIKey] = Object(map[string]*Schema{
+		"id":           String(),
+		"name":         String(),
+		"scope":        String().OneOf("read", "write", "admin"),
+		"owner":        String(),
+		"created_at":   String().Formatted("date-time"),
+		"expires_at":   String().Formatted("date-time"),
+		"last_used_at": String().Formatted("date-time"),
+		"revoked":      Boolean(),
+		"key":          String().Describe("сам ключ; только в ответе на выпуск"),
+	}, "id", "name", "scope", "owner", "created_at", "revoked")
+	c.Schemas["NewAPIKey"] = Object(map[string]*Schema{
+		"name":            String().MinLen(1),
+		"scope":           String().OneOf("read", "write", "admin"),
+		"expires_in_days": Integer().Min(0).Describe("0 - бессрочный"),
+	}, "name", "scope")
+
+	c.Responses[RespUnauthorized] = &Response{Description: "требуется вход или токен недействителен",
+		Headers: map[string]*Header{"WWW-Authenticate": {Schema: String()}},
+		Content: map[string]*MediaType{"application/json": {Schema: Ref(SchemaError)}}}
+	c.Responses[RespTooManyRequests] = &Response{Description: "превышен лимит частоты запросов",
+		Headers: map[string]*Header{"Retry-After": {Description: "секунды до следующей попытки", Schema: Integer()}},
+		Content: map[string]*MediaType{"application/json": {Schema: Ref(SchemaError)}}}
+	c.Responses[RespPayloadTooLarge] = &Response{Description: "тело запроса больше допустимого",
+		Content: map[string]*MediaType{"application/json": {Schema: Ref(SchemaError)}}}
+
+	d.Add(http.MethodPost, "/login", Op("login", "Вход: пара токенов", "auth").
+		Body(Ref("Login")).
+		JSON(200, "токены", Ref(SchemaTokens)).
+		JSON(400, "некорректный запрос", Ref(SchemaError)).
+		JSON(401, "неверный логин или пароль", Ref(SchemaError)).
+		JSON(403, "учетная запись заблокирована", Ref(SchemaError)))
+	d.Add(http.MethodPost, "/token/refresh", Op("refreshToken", "Новая пара токенов по токену обновления", "auth").
+		Body(Ref("RefreshToken")).
+		JSON(200, "токены", Ref(SchemaTokens)).
+		JSON(400, "некорректный запрос", Ref(SchemaError)).
+		JSON(401, "токен обновления недействителен", Ref(SchemaError)).
+		JSON(403, "учетная запись заблокирована", Ref(SchemaError)))
+	d.Add(http.MethodPost, "/logout", Op("logout", "Отзыв токена обновления", "auth").
+		Body(Ref("RefreshToken")).
+		Empty(204, "токен отозван").
+		JSON(400, "некорректный запрос", Ref(SchemaError)))
+
+	d.Add(http.MethodGet, "/admin/apikeys", Op("listAPIKeys", "Список ключей API", "admin").Secured().
+		JSON(200, "ключи", Array(Ref(SchemaAPIKey))))
+	d.Add(http.MethodPost, "/admin/apikeys", Op("createAPIKey", "Выпуск ключа API", "admin").Secured().
+		Body(Ref("NewAPIKey")).
+		JSON(201, "ключ (поле key показывается один раз)", Ref(SchemaAPIKey)).
+		JSON(400, "некорректный запрос", Ref(SchemaError)))
+
+	d.Add(http.MethodGet, "/openapi.json", Op("openapi", "Этот документ", "docs").
+		JSON(200, "документ OpenAPI", Map(nil).Open()))
+	d.Add(http.MethodGet, "/docs", Op("docs", "Страница Swagger UI", "docs").
+		content(200, "HTML-страница", "text/html", String()))
+}
@@ -0,0 +1,431 @@
+// Package openapi — описание API сервисов в формате OpenAPI 3.1: документ
+// строится в коде, отдается по /openapi.json вместе со страницей Swagger UI,
+// а при запуске сверяется с зарегистрированными маршрутами.
+package openapi
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Version - версия спецификации OpenAPI
+const Version = "3.1.0"
+
+// Document - документ OpenAPI
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Servers    []Server             `json:"servers,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+// Info - название и версия API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Server - адрес сервиса
+type Server struct {
+	URL string `json:"url"`
+}
+
+// PathItem - операции одного пути
+type PathItem struct {
+	Get    *Operation `json:"get,omitempty"`
+	Put    *Operation `json:"put,omitempty"`
+	Post   *Operation `json:"post,omitempty"`
+	Delete *Operation `json:"delete,omitempty"`
+	Patch  *Operation `json:"patch,omitempty"`
+}
+
+// Components - общие схемы, ответы и способы аутентификации
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	Responses       map[string]*Response       `json:"responses,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme - способ аутентификации
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// Operation - операция (метод пути)
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Tags        []string              `json:"tags,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter - параметр пути или запроса
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"` //"path", "query", "header"
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody - тело запроса
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response - ответ; Ref ссылается на общий ответ из components
+type Response struct {
+	Ref         string                `json:"$ref,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Headers     map[string]*Header    `json:"headers,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// Header - заголовок ответа
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// MediaType - содержимое определенного типа
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema - JSON Schema (подмножество, которое используют сервисы)
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 Types              `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	PropertyNames        *Schema            `json:"propertyNames,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	Closed               bool               `json:"-"` //неизвестные свойства запрещены
+}
+
+// Types - тип значения; несколько типов - ["integer","null"]
+type Types []string
+
+// MarshalJSON пишет одиночный тип строкой
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// MarshalJSON дописывает "additionalProperties": false для закрытых объектов
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	type plain Schema
+	if !s.Closed {
+		return json.Marshal((*plain)(s))
+	}
+	return json.Marshal(struct {
+		*plain
+		AdditionalProperties bool `json:"additionalProperties"`
+	}{(*plain)(s), false})
+}
+
+// New создает пустой документ
+func New(title, version, description string) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    Info{Title: title, Version: version, Description: description},
+		Paths:   make(map[string]*PathItem),
+		Components: Components{
+			Schemas:         make(map[string]*Schema),
+			Responses:       make(map[string]*Response),
+			SecuritySchemes: make(map[string]*SecurityScheme),
+		},
+	}
+}
+
+// параметры пути в шаблоне "/users/{userId}"
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// Add регистрирует операцию; параметры пути, не описанные явно, добавляются строками
+func (d *Document) Add(method, path string, op *Operation) {
+	for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+		if op.param(m[1]) == nil {
+			op.Param(m[1], "", String())
+		}
+	}
+	//параметры и тело проверяются по описанию (Validator), лимит частоты действует
+	//на все маршруты, размер тела - на запросы с телом
+	if op.problems() && (len(op.Parameters) > 0 || op.RequestBody != nil) {
+		op.Problem(400, "запрос не соответствует описанию")
+	} else if len(op.Parameters) > 0 || op.RequestBody != nil {
+		op.JSON(400, "запрос не соответствует описанию", Ref(SchemaValidationErrors))
+	}
+	if _, ok := op.Responses["429"]; !ok {
+		op.Ref(429, RespTooManyRequests)
+	}
+	if _, ok := op.Responses["413"]; !ok && op.RequestBody != nil {
+		op.Ref(413, RespPayloadTooLarge)
+	}
+	item, ok := d.Paths[path]
+	if !ok {
+		item = &PathItem{}
+		d.Paths[path] = item
+	}
+	switch strings.ToUpper(method) {
+	case "GET":
+		item.Get = op
+	case "PUT":
+		item.Put = op
+	case "POST":
+		item.Post = op
+	case "DELETE":
+		item.Delete = op
+	case "PATCH":
+		item.Patch = op
+	default:
+		panic("openapi: метод " + method + " не поддерживается")
+	}
+}
+
+// Operations - все операции документа по методу и пути
+func (d *Document) Operations() []Route {
+	var out []Route
+	for path, item := range d.Paths {
+		for method, op := range item.operations() {
+			if op != nil {
+				out = append(out, Route{Method: method, Path: path})
+			}
+		}
+	}
+	sortRoutes(out)
+	return out
+}
+
+// Operation возвращает операцию по методу и пути OpenAPI; nil - нет такой
+func (d *Document) Operation(method, path string) *Operation {
+	if item, ok := d.Paths[path]; ok {
+		return item.operations()[strings.ToUpper(method)]
+	}
+	return nil
+}
+
+func (p *PathItem) operations() map[string]*Operation {
+	return map[string]*Operation{"GET": p.Get, "PUT": p.Put, "POST": p.Post, "DELETE": p.Delete, "PATCH": p.Patch}
+}
+
+// Resolve возвращает схему по ссылке "#/components/schemas/Name" (или саму схему)
+func (d *Document) Resolve(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		s = d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+// Op создает операцию
+func Op(id, summary string, tags ...string) *Operation {
+	return &Operation{OperationID: id, Summary: summary, Tags: tags, Responses: make(map[string]*Response)}
+}
+
+func (o *Operation) param(name string) *Parameter {
+	for _, p := range o.Parameters {
+		if p.Name == name && p.In == "path" {
+			return p
+		}
+	}
+	return nil
+}
+
+// Param описывает параметр пути
+func (o *Operation) Param(name, description string, schema *Schema) *Operation {
+	o.Parameters = append(o.Parameters, &Parameter{Name: name, In: "path", Description: description, Required: true, Schema: schema})
+	return o
+}
+
+// Query описывает необязательный параметр запроса
+func (o *Operation) Query(name, description string, schema *Schema) *Operation {
+	o.Parameters = append(o.Parameters, &Parameter{Name: name, In: "query", Description: description, Schema: schema})
+	return o
+}
+
+// Body описывает обязательное тело запроса в JSON
+func (o *Operation) Body(schema *Schema) *Operation {
+	o.RequestBody = &RequestBody{Required: true, Content: map[string]*MediaType{"application/json": {Schema: schema}}}
+	return o
+}
+
+// response возвращает ответ с кодом status, дополняя описание уже имеющегося
+func (o *Operation) response(status int, description string) *Response {
+	code := strconv.Itoa(status)
+	resp, ok := o.Responses[code]
+	if !ok || resp.Ref != "" {
+		resp = &Response{Description: description}
+		o.Responses[code] = resp
+	} else if !strings.Contains(resp.Description, description) {
+		resp.Description += "; " + description
+	}
+	return resp
+}
+
+// content добавляет ответу содержимое типа mediaType
+func (o *Operation) content(status int, description, mediaType string, schema *Schema) *Operation {
+	resp := o.response(status, description)
+	if resp.Content == nil {
+		resp.Content = make(map[string]*MediaType)
+	}
+	if mt, ok := resp.Content[mediaType]; ok && !sameSchema(mt.Schema, schema) { //разные тела с одним кодом
+		if mt.Schema != nil && schema != nil {
+			mt.Schema = &Schema{AnyOf: []*Schema{mt.Schema, schema}}
+		} else { //тело без схемы - любое
+			mt.Schema = nil
+		}
+		return o
+	}
+	resp.Content[mediaType] = &MediaType{Schema: schema}
+	return o
+}
+
+func sameSchema(a, b *Schema) bool {
+	return a == b || a != nil && b != nil && a.Ref != "" && a.Ref == b.Ref
+}
+
+// JSON описывает ответ в JSON (несколько вызовов с одним кодом объединяются)
+func (o *Operation) JSON(status int, description string, schema *Schema) *Operation {
+	return o.content(status, description, "application/json", schema)
+}
+
+// Text описывает текстовый ответ (в том числе текст вперемешку с JSON)
+func (o *Operation) Text(status int, description string) *Operation {
+	return o.content(status, description, "text/plain", String())
+}
+
+// Mixed описывает ответ с заголовком application/json, но телом из текста и JSON
+// (ответы старых обработчиков; тело не проверяется)
+func (o *Operation) Mixed(status int, description string) *Operation {
+	return o.content(status, description, "application/json", nil)
+}
+
+// Empty описывает ответ без тела
+func (o *Operation) Empty(status int, description string) *Operation {
+	o.response(status, description)
+	return o
+}
+
+// Ref ссылается на общий ответ из components
+func (o *Operation) Ref(status int, name string) *Operation {
+	o.Responses[strconv.Itoa(status)] = &Response{Ref: "#/components/responses/" + name}
+	return o
+}
+
+// Secured требует аутентификацию (JWT, ключ API или сертификат клиента)
+// и описывает отказы 401 и 403
+func (o *Operation) Secured() *Operation {
+	for _, s := range []string{SchemeBearer, SchemeAPIKey, SchemeMutualTLS} {
+		o.Security = append(o.Security, map[string][]string{s: {}})
+	}
+	o.Ref(401, RespUnauthorized)
+	return o.JSON(403, "недостаточно прав или учетная запись заблокирована", Ref(SchemaError))
+}
+
+// Deprecate отмечает операцию устаревшей
+func (o *Operation) Deprecate() *Operation {
+	o.Deprecated = true
+	return o
+}
+
+// Схемы
+
+// Ref - ссылка на общую схему
+func Ref(name string) *Schema { return &Schema{Ref: "#/components/schemas/" + name} }
+
+// String - строка
+func String() *Schema { return &Schema{Type: Types{"string"}} }
+
+// Integer - целое число
+func Integer() *Schema { return &Schema{Type: Types{"integer"}} }
+
+// Number - число
+func Number() *Schema { return &Schema{Type: Types{"number"}} }
+
+// Boolean - логическое значение
+func Boolean() *Schema { return &Schema{Type: Types{"boolean"}} }
+
+// Array - массив элементов
+func Array(items *Schema) *Schema { return &Schema{Type: Types{"array"}, Items: items} }
+
+// Map - объект с произвольными ключами и значениями values
+func Map(values *Schema) *Schema {
+	return &Schema{Type: Types{"object"}, AdditionalProperties: values}
+}
+
+// Object - объект с перечисленными свойствами, неизвестные свойства запрещены
+func Object(props map[string]*Schema, required ...string) *Schema {
+	return &Schema{Type: Types{"object"}, Properties: props, Required: required, Closed: true}
+}
+
+// Open разрешает неизвестные свойства объекта
+func (s *Schema) Open() *Schema { s.Closed = false; return s }
+
+// Nullable допускает null
+func (s *Schema) Nullable() *Schema { s.Type = append(s.Type, "null"); return s }
+
+// Min задает минимум числа
+func (s *Schema) Min(v float64) *Schema { s.Minimum = &v; return s }
+
+// MinLen задает минимальную длину строки
+func (s *Schema) MinLen(n int) *Schema { s.MinLength = &n; return s }
+
+// MaxLen задает максимальную длину строки
+func (s *Schema) MaxLen(n int) *Schema { s.MaxLength = &n; return s }
+
+// Formatted задает формат строки
+func (s *Schema) Formatted(f string) *Schema { s.Format = f; return s }
+
+// Describe задает описание
+func (s *Schema) Describe(d string) *Schema { s.Description = d; return s }
+
+// OneOf ограничивает значения перечислением
+func (s *Schema) OneOf(values ...interface{}) *Schema { s.Enum = values; return s }
+
+// Keys задает схему ключей объекта-карты
+func (s *Schema) Keys(k *Schema) *Schema { s.PropertyNames = k; return s }
+
+// Matching задает регулярное выражение строки
+func (s *Schema) Matching(pattern string) *Schema { s.Pattern = pattern; return s }
+
+// Route - метод и путь в формате OpenAPI ("/users/{userId}")
+type Route struct {
+	Method string
+	Path   string
+}
+
+func (r Route) String() string { return r.Method + " " + r.Path }
+
+func sortRoutes(routes []Route) {
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+}
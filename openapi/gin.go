@@ -0,0 +1,53 @@
+package openapi
+
+import "github.com/gin-gonic/gin"
+
+// Gin - проверка запросов (и ответов) для "Джин"
+func (v *Validator) Gin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.FullPath()
+		if path == "" { //маршрут не найден
+			c.Next()
+			return
+		}
+		route := Route{Method: c.Request.Method, Path: ginParam.ReplaceAllString(path, "{$1}")}
+		params := make(map[string]string, len(c.Params))
+		for _, p := range c.Params {
+			params[p.Key] = p.Value
+		}
+		if !v.request(c.Writer, c.Request, route, params) {
+			c.Abort()
+			return
+		}
+		if !v.recorded(route) {
+			c.Next()
+			return
+		}
+		w := c.Writer
+		rec := &ginRecorder{ResponseWriter: w}
+		c.Writer = rec
+		c.Next()
+		c.Writer = w
+		v.response(w, c.Request, route, &rec.rec)
+	}
+}
+
+// ginRecorder задерживает ответ обработчиков "Джин" до проверки
+type ginRecorder struct {
+	gin.ResponseWriter
+	rec recorder
+}
+
+func (g *ginRecorder) WriteHeader(status int)            { g.rec.writeHeader(status) }
+func (g *ginRecorder) WriteHeaderNow()                   {}
+func (g *ginRecorder) Write(b []byte) (int, error)       { return g.rec.write(b) }
+func (g *ginRecorder) WriteString(s string) (int, error) { return g.rec.write([]byte(s)) }
+func (g *ginRecorder) Written() bool                     { return g.rec.status != 0 }
+func (g *ginRecorder) Size() int                         { return g.rec.body.Len() }
+
+func (g *ginRecorder) Status() int {
+	if g.rec.status == 0 {
+		return 200
+	}
+	return g.rec.status
+}
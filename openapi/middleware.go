@@ -0,0 +1,222 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"Network-exchange/logging"
+	"Network-exchange/problem"
+	"Network-exchange/secure"
+)
+
+// Validator проверяет запросы по документу, а в режиме тестов - и ответы
+type Validator struct {
+	Doc       *Document
+	Responses bool //проверять ответы: несоответствие заменяется ответом 500
+}
+
+// ValidatorFromEnv создает проверку запросов; OPENAPI_VALIDATE_RESPONSES=true
+// включает проверку ответов (для тестов и отладки)
+func ValidatorFromEnv(d *Document) *Validator {
+	responses, _ := strconv.ParseBool(os.Getenv("OPENAPI_VALIDATE_RESPONSES"))
+	return &Validator{Doc: d, Responses: responses}
+}
+
+// поле ошибок, относящихся ко всему телу
+const bodyField = "тело"
+
+// CheckRequest проверяет параметры и тело запроса к операции route; params - параметры пути.
+// Тело читается целиком и возвращается в r.Body; ошибка - тело не прочитано (например, слишком большое)
+func (v *Validator) CheckRequest(r *http.Request, route Route, params map[string]string) ([]FieldError, error) {
+	op := v.Doc.Operation(route.Method, route.Path)
+	if op == nil {
+		return nil, nil
+	}
+	var errs []FieldError
+	query := r.URL.Query()
+	for _, p := range op.Parameters {
+		var (
+			raw string
+			ok  bool
+		)
+		switch p.In {
+		case "path":
+			raw, ok = params[p.Name]
+		case "query":
+			ok = query.Has(p.Name)
+			raw = query.Get(p.Name)
+		}
+		if !ok {
+			if p.Required {
+				errs = append(errs, FieldError{Field: p.Name, Message: "Это поле обязательно для заполнения"})
+			}
+			continue
+		}
+		errs = append(errs, v.Doc.Validate(p.Schema, v.Doc.paramValue(p.Schema, raw), p.Name)...)
+	}
+	if op.RequestBody == nil {
+		return errs, nil
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	if len(bytes.TrimSpace(data)) == 0 {
+		if op.RequestBody.Required {
+			errs = append(errs, FieldError{Field: bodyField, Message: "Тело запроса обязательно"})
+		}
+		return errs, nil
+	}
+	value, err := DecodeValue(data)
+	if err != nil {
+		return append(errs, FieldError{Field: bodyField, Message: "Некорректный JSON"}), nil
+	}
+	if mt := op.RequestBody.Content["application/json"]; mt != nil {
+		errs = append(errs, named(v.Doc.Validate(mt.Schema, value, ""))...)
+	}
+	return errs, nil
+}
+
+// CheckResponse проверяет код, тип содержимого и тело ответа операции route
+func (v *Validator) CheckResponse(route Route, status int, header http.Header, body []byte) []FieldError {
+	op := v.Doc.Operation(route.Method, route.Path)
+	if op == nil {
+		return nil
+	}
+	resp, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		return []FieldError{{Field: "статус", Message: "Код " + strconv.Itoa(status) + " не описан"}}
+	}
+	if resp.Ref != "" {
+		resp = v.Doc.Components.Responses[strings.TrimPrefix(resp.Ref, "#/components/responses/")]
+	}
+	if len(resp.Content) == 0 {
+		if len(body) > 0 {
+			return []FieldError{{Field: bodyField, Message: "Тело ответа не описано"}}
+		}
+		return nil
+	}
+	if len(body) == 0 {
+		return []FieldError{{Field: bodyField, Message: "Ожидалось тело ответа"}}
+	}
+	contentType := header.Get("Content-Type")
+	if contentType == "" { //тип определит net/http по началу тела
+		contentType = http.DetectContentType(body)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	mt, ok := resp.Content[mediaType]
+	if !ok {
+		return []FieldError{{Field: "Content-Type", Message: "Тип " + mediaType + " не описан"}}
+	}
+	if mt.Schema == nil || mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return nil
+	}
+	value, err := DecodeValue(body)
+	if err != nil {
+		return []FieldError{{Field: bodyField, Message: "Некорректный JSON"}}
+	}
+	return named(v.Doc.Validate(mt.Schema, value, ""))
+}
+
+// named дает имя ошибкам тела целиком
+func named(errs []FieldError) []FieldError {
+	for i := range errs {
+		if errs[i].Field == "" {
+			errs[i].Field = bodyField
+		}
+	}
+	return errs
+}
+
+// recorded сообщает, нужно ли задерживать ответ до проверки: потоковые ответы
+// (text/event-stream) и переход на WebSocket передаются сразу и не проверяются
+func (v *Validator) recorded(route Route) bool {
+	op := v.Doc.Operation(route.Method, route.Path)
+	if !v.Responses || op == nil {
+		return false
+	}
+	if _, ok := op.Responses["101"]; ok {
+		return false
+	}
+	for _, resp := range op.Responses {
+		if _, ok := resp.Content["text/event-stream"]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// request проверяет запрос и при ошибке отвечает 400 (413); false - обработку прервать
+func (v *Validator) request(w http.ResponseWriter, r *http.Request, route Route, params map[string]string) bool {
+	errs, err := v.CheckRequest(r, route, params)
+	switch {
+	case secure.TooLarge(err):
+		secure.WriteTooLarge(w, r)
+		return false
+	case err != nil:
+		writeErrors(w, r, http.StatusBadRequest, "тело запроса не прочитано", nil)
+		return false
+	case len(errs) > 0 && v.Doc.Operation(route.Method, route.Path).problems():
+		problem.Write(w, r, http.StatusBadRequest, problem.Validation, "", errs)
+		return false
+	case len(errs) > 0:
+		writeErrors(w, r, http.StatusBadRequest, "запрос не соответствует описанию API", errs)
+		return false
+	}
+	return true
+}
+
+// response отправляет записанный ответ обработчика, если он соответствует описанию,
+// иначе - 500 со списком расхождений
+func (v *Validator) response(w http.ResponseWriter, r *http.Request, route Route, rec *recorder) {
+	status := rec.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if errs := v.CheckResponse(route, status, w.Header(), rec.body.Bytes()); len(errs) > 0 {
+		logging.FromContext(r.Context()).Error("ответ не соответствует описанию API",
+			"route", route.String(), "status", status, "errors", errs)
+		w.Header().Del("Content-Length")
+		writeErrors(w, r, http.StatusInternalServerError, "ответ не соответствует описанию API", errs)
+		return
+	}
+	if rec.status != 0 {
+		w.WriteHeader(rec.status)
+	}
+	w.Write(rec.body.Bytes())
+}
+
+func writeErrors(w http.ResponseWriter, r *http.Request, status int, text string, errs []FieldError) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Error     string       `json:"error"`
+		Errors    []FieldError `json:"errors"`
+		RequestID string       `json:"request_id,omitempty"`
+	}{text, errs, logging.RequestID(r.Context())})
+}
+
+// recorder накапливает ответ обработчика до проверки
+type recorder struct {
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *recorder) writeHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+func (rec *recorder) write(b []byte) (int, error) {
+	rec.writeHeader(http.StatusOK)
+	return rec.body.Write(b)
+}
@@ -0,0 +1,44 @@
+package openapi
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Mux - проверка запросов (и ответов) для "Гориллы": router.Use(validator.Mux)
+func (v *Validator) Mux(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := mux.CurrentRoute(r)
+		if current == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		path, err := current.GetPathTemplate()
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		route := Route{Method: r.Method, Path: path}
+		if !v.request(w, r, route, mux.Vars(r)) {
+			return
+		}
+		if !v.recorded(route) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := &muxRecorder{w: w}
+		next.ServeHTTP(rec, r)
+		v.response(w, r, route, &rec.rec)
+	})
+}
+
+// muxRecorder задерживает ответ обработчика до проверки
+type muxRecorder struct {
+	w   http.ResponseWriter
+	rec recorder
+}
+
+func (m *muxRecorder) Header() http.Header         { return m.w.Header() }
+func (m *muxRecorder) WriteHeader(status int)      { m.rec.writeHeader(status) }
+func (m *muxRecorder) Write(b []byte) (int, error) { return m.rec.write(b) }
@@ -0,0 +1,69 @@
+package openapi
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/mux"
+)
+
+// CheckRoutes сверяет зарегистрированные маршруты с документом: ошибка перечисляет
+// маршруты без описания и описания без маршрутов
+func (d *Document) CheckRoutes(registered []Route) error {
+	have := make(map[Route]bool)
+	for _, r := range registered {
+		have[r] = true
+	}
+	described := make(map[Route]bool)
+	var problems []string
+	for _, r := range d.Operations() {
+		described[r] = true
+		if !have[r] {
+			problems = append(problems, "описан, но не зарегистрирован: "+r.String())
+		}
+	}
+	sortRoutes(registered)
+	for _, r := range registered {
+		if !described[r] {
+			problems = append(problems, "зарегистрирован, но не описан: "+r.String())
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New("openapi: маршруты расходятся с документом:\n\t" + strings.Join(problems, "\n\t"))
+	}
+	return nil
+}
+
+// параметр пути "Джин": ":name" или "*path"
+var ginParam = regexp.MustCompile(`[:*]([^/]+)`)
+
+// GinRoutes - маршруты "Джин" в формате OpenAPI ("/users/:name" -> "/users/{name}")
+func GinRoutes(engine *gin.Engine) []Route {
+	var out []Route
+	for _, r := range engine.Routes() {
+		out = append(out, Route{Method: r.Method, Path: ginParam.ReplaceAllString(r.Path, "{$1}")})
+	}
+	return out
+}
+
+// MuxRoutes - маршруты "Гориллы" (шаблоны путей уже в формате OpenAPI)
+func MuxRoutes(router *mux.Router) []Route {
+	var out []Route
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil { //подмаршрутизатор или маршрут без методов
+			return nil
+		}
+		for _, m := range methods {
+			out = append(out, Route{Method: m, Path: path})
+		}
+		return nil
+	})
+	return out
+}
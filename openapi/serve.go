@@ -0,0 +1,55 @@
+package openapi
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+)
+
+// Handler отдает документ в JSON (GET /openapi.json)
+func (d *Document) Handler() http.HandlerFunc {
+	body, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		panic(err) //документ строится в коде - ошибка означает ошибку программиста
+	}
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.Write(body)
+	}
+}
+
+// версия Swagger UI, загружаемая страницей документации
+const swaggerUIVersion = "5.17.14"
+
+// политика безопасности страницы: скрипты и стили Swagger UI с CDN, документ - с сервиса
+const uiCSP = "default-src 'none'; script-src 'self' 'unsafe-inline' https://unpkg.com; " +
+	"style-src 'self' 'unsafe-inline' https://unpkg.com; img-src 'self' data: https://unpkg.com; " +
+	"connect-src 'self'; frame-ancestors 'none'"
+
+var uiPage = template.Must(template.New("ui").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+  <meta charset="utf-8">
+  <title>{{.Title}}</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@{{.Version}}/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@{{.Version}}/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = function () {
+      SwaggerUIBundle({url: "{{.SpecURL}}", dom_id: "#swagger-ui"});
+    };
+  </script>
+</body>
+</html>
+`))
+
+// UIHandler отдает страницу Swagger UI для документа по адресу specURL (GET /docs)
+func (d *Document) UIHandler(specURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		w.Header().Set("Content-Security-Policy", uiCSP) //заменяет строгую политику JSON API
+		uiPage.Execute(w, struct{ Title, Version, SpecURL string }{d.Info.Title, swaggerUIVersion, specURL})
+	}
+}
@@ -0,0 +1,51 @@
+package openapi
+
+import "net/http"
+
+// addAudit описывает журнал аудита; общий для обоих сервисов
+func addAudit(d *Document) {
+	c := &d.Components
+	user := userSchema(map[string]*Schema{
+		"id":      Integer(),
+		"name":    String(),
+		"age":     Integer(),
+		"friends": Array(Integer()).Describe("ID друзей"),
+	}, "id", "name", "age", "friends")
+	c.Schemas["AuditEntry"] = Object(map[string]*Schema{
+		"seq":  Integer().Describe("номер изменения в журнале хранилища"),
+		"time": String().Formatted("date-time"),
+		"action": String().OneOf("user.created", "user.updated", "user.profile_updated", "user.deleted", "user.restored", "user.purged",
+			"friendship.created", "friendship.deleted", "friendship.updated", "user.blocked", "user.unblocked",
+			"follow.requested", "follow.declined", "follow.created", "follow.deleted",
+			"user.follow_approval_on", "user.follow_approval_off"),
+		"actor":      String().Describe("владелец запроса (логин, apikey:<id>, CN сертификата), anonymous или system"),
+		"role":       String(),
+		"request_id": String(),
+		"client_ip":  String(),
+		"user_id":    Integer().Describe("измененный пользователь (для дружбы - инициатор)"),
+		"friend_id":  Integer(),
+		"before":     user.Nullable().Describe("null - пользователя еще не было"),
+		"after":      user.Nullable().Describe("null - пользователь удален"),
+	}, "seq", "time", "action", "actor", "user_id", "before", "after")
+
+	filter := func(o *Operation) *Operation {
+		return o.Secured().
+			Query("user_id", "измененный пользователь или второй участник дружбы", Integer().Min(1)).
+			Query("actor", "владелец запроса", String()).
+			Query("action", "вид изменения", String()).
+			Query("from", "не раньше (RFC 3339)", String().Formatted("date-time")).
+			Query("to", "раньше (RFC 3339)", String().Formatted("date-time")).
+			JSON(400, "некорректный фильтр", Ref(SchemaError))
+	}
+	d.Add(http.MethodGet, "/admin/audit", filter(Op("listAudit", "Журнал аудита изменений", "admin")).
+		Query("limit", "размер страницы (по умолчанию 100, не больше 1000)", Integer().Min(1)).
+		Query("offset", "", Integer().Min(0)).
+		JSON(200, "записи по порядку изменений", Object(map[string]*Schema{
+			"items":  Array(Ref("AuditEntry")),
+			"total":  Integer(),
+			"limit":  Integer(),
+			"offset": Integer(),
+		}, "items", "total", "limit", "offset")))
+	d.Add(http.MethodGet, "/admin/audit/export", filter(Op("exportAudit", "Выгрузка журнала аудита", "admin")).
+		content(200, "все подходящие записи AuditEntry, по одной в строке", "application/x-ndjson", String()))
+}
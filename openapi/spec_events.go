@@ -0,0 +1,15 @@
+package openapi
+
+import "net/http"
+
+// addEvents описывает ленту изменений (Server-Sent Events); общая для обоих сервисов
+func addEvents(d *Document) {
+	d.Add(http.MethodGet, "/events", Op("events", "Лента изменений (Server-Sent Events)", "events").
+		Query("user_id", "только события с участием пользователей (ID через запятую)", String()).
+		Query("types", "виды событий через запятую: user.created, user.deleted, user.restored, user.purged, user.age_changed, user.profile_updated, "+
+			"friendship.created, friendship.removed, follow.created, follow.deleted", String()).
+		content(200, "поток событий: id - номер изменения, event - вид, data - событие в JSON "+
+			"(email и birth_date - только самому пользователю и с правом users:read; события заблокировавших не приходят); "+
+			"с заголовком Last-Event-ID - сначала пропущенные (или событие reset)", "text/event-stream", String()).
+		JSON(400, "некорректный фильтр", Ref(SchemaError)))
+}
@@ -0,0 +1,125 @@
+package openapi
+
+import "net/http"
+
+// GinSpec - описание сервиса на gin (gin_Rest.go): пользователи по именам
+func GinSpec() *Document {
+	d := New("Network-exchange (gin)", "2.0.0",
+		"Пользователи и дружба между ними. Первая версия (без префикса и /v1) адресует пользователей "+
+			"по имени и устарела: ее ответы содержат заголовки Deprecation, Sunset и Link на /v2.")
+	addCommon(d)
+	c := &d.Components
+
+	c.Schemas["User"] = Object(map[string]*Schema{
+		"name":    String().MinLen(1),
+		"age":     Integer().Min(18),
+		"friends": Array(String()).Nullable().Describe("имена друзей"),
+	}, "name", "age")
+	c.Schemas["NewUser"] = Object(map[string]*Schema{
+		"name":     String().MinLen(1).Describe("без двоеточия"),
+		"age":      Integer().Min(18),
+		"friends":  Array(String()).Nullable(),
+		"password": String().MinLen(8).Describe("пароль для входа; без него учетная запись не создается"),
+	}, "name", "age")
+	c.Schemas["Friendship"] = Object(map[string]*Schema{
+		"source": String().Describe("имя инициатора"),
+		"target": String().Describe("имя друга"),
+	}, "source", "target")
+	c.Schemas["AdminUser"] = Object(map[string]*Schema{
+		"name":      String(),
+		"age":       Integer(),
+		"friends":   Array(String()).Nullable(),
+		"role":      String().OneOf("user", "auditor", "admin"),
+		"suspended": Boolean(),
+	}, "name", "age", "role", "suspended")
+	c.Schemas["AdminPatch"] = Object(map[string]*Schema{
+		"age":  Integer().Min(18).Nullable(),
+		"role": String().OneOf("user", "auditor", "admin").Describe("требует право roles:manage"),
+	})
+
+	//первая версия: без префикса (как раньше) и под /v1; обе устарели
+	for _, prefix := range []string{"", "/v1"} {
+		ginV1(d, prefix)
+	}
+	addV2(d)
+	addGraphQL(d)
+	addEvents(d)
+	addNotify(d)
+	addWebhooks(d)
+	addAudit(d)
+	addRetention(d)
+
+	name := String().Describe("имя пользователя")
+	d.Add(http.MethodGet, "/admin/users", Op("adminGetUsers", "Пользователи с ролями и блокировками", "admin").Secured().
+		JSON(200, "пользователи", Array(Ref("AdminUser"))))
+	d.Add(http.MethodPatch, "/admin/users/{name}", Op("adminPatchUser", "Изменение возраста и роли", "admin").Secured().
+		Param("name", "", name).
+		Body(Ref("AdminPatch")).
+		JSON(200, "пользователь", Ref("AdminUser")).
+		JSON(400, "некорректный запрос", Ref(SchemaError)).
+		JSON(404, "пользователь не найден", Ref(SchemaError)).
+		JSON(409, "возраст вычисляется по дате рождения", Ref(SchemaError)))
+	for _, a := range []struct{ path, id, summary string }{
+		{"/admin/users/{name}/suspend", "adminSuspendUser", "Блокировка пользователя"},
+		{"/admin/users/{name}/reinstate", "adminReinstateUser", "Снятие блокировки"},
+	} {
+		d.Add(http.MethodPost, a.path, Op(a.id, a.summary, "admin").Secured().
+			Param("name", "", name).
+			JSON(200, "пользователь", Ref("AdminUser")).
+			JSON(404, "пользователь не найден", Ref(SchemaError)))
+	}
+	d.Add(http.MethodDelete, "/admin/users/{name}", Op("adminDeleteUser", "Удаление любого пользователя (с возможностью восстановить)", "admin").Secured().
+		Param("name", "", name).
+		Text(200, "пользователь удален").
+		Text(404, "пользователь не найден"))
+	d.Add(http.MethodDelete, "/admin/apikeys/{id}", Op("revokeAPIKey", "Отзыв ключа API", "admin").Secured().
+		Empty(204, "ключ отозван").
+		JSON(404, "ключ не найден", Ref(SchemaError)))
+	return d
+}
+
+// ginV1 описывает маршруты первой версии с префиксом prefix
+func ginV1(d *Document, prefix string) {
+	name := String().Describe("имя пользователя")
+	d.Add(http.MethodGet, prefix+"/users", v1Op(prefix, "getUsers", "Все пользователи", "users").
+		JSON(200, "пользователи", Array(Ref("User"))))
+	d.Add(http.MethodGet, prefix+"/users/name/{name}", v1Op(prefix, "getUserByName", "Пользователь по имени", "users").
+		Param("name", "", name).
+		JSON(200, "пользователь", Ref("User")).
+		Text(404, "пользователь не найден"))
+	d.Add(http.MethodGet, prefix+"/users/id/{id}", v1Op(prefix, "getUserByID", "Пользователь по номеру в списке", "users").
+		Param("id", "номер с 1", Integer()).
+		JSON(200, "пользователь", Ref("User")).
+		JSON(404, "пользователь не найден", Object(map[string]*Schema{
+			"Упс":        String(),
+			"request_id": String(),
+		}, "Упс")))
+	d.Add(http.MethodGet, prefix+"/friends/{name}", v1Op(prefix, "getFriends", "Друзья пользователя", "friends").
+		Param("name", "", name).
+		JSON(200, "имена друзей", Array(String()).Nullable()).
+		Text(404, "пользователь не найден"))
+
+	d.Add(http.MethodPost, prefix+"/users", v1Op(prefix, "postUsers", "Создание пользователя (с паролем - и учетной записи)", "users").
+		Body(Ref("NewUser")).
+		Text(201, "сообщение и созданный пользователь в JSON").
+		JSON(400, "ошибки проверки полей", Ref(SchemaValidationErrors)).
+		JSON(400, "некорректный запрос", Ref(SchemaError)).
+		Text(403, "пользователь уже есть или пароль слишком простой"))
+	d.Add(http.MethodPut, prefix+"/friends", v1Op(prefix, "putFriends", "Дружба двух пользователей (от имени source)", "friends").Secured().
+		Body(Ref("Friendship")).
+		Text(201, "пользователи теперь друзья").
+		Empty(400, "некорректный запрос").
+		Text(403, "уже друзья, дружба с собой или один заблокировал другого").
+		Text(404, "кого-то нет в базе"))
+	d.Add(http.MethodDelete, prefix+"/users/delete/{name}", v1Op(prefix, "deleteUserByName", "Удаление себя", "users").Secured().
+		Param("name", "", name).
+		Text(200, "пользователь удален").
+		Text(404, "пользователь не найден"))
+	d.Add(http.MethodPut, prefix+"/users/{id}", v1Op(prefix, "putAge", "Изменение своего возраста", "users").Secured().
+		Param("id", "номер с 1", Integer()).
+		Body(Integer().Min(18).Describe("новый возраст")).
+		Text(200, "сообщение и пользователь в JSON").
+		JSON(400, "некорректный запрос", Ref(SchemaError)).
+		Text(404, "пользователь не найден").
+		JSON(409, "возраст вычисляется по дате рождения", Ref(SchemaError)))
+}
@@ -0,0 +1,128 @@
+package openapi
+
+import "net/http"
+
+// GorillaSpec - описание сервиса на gorilla/mux (gorilla_Rest.go): пользователи по ID
+func GorillaSpec() *Document {
+	d := New("Network-exchange (gorilla/mux)", "2.0.0",
+		"Пользователи и дружба между ними по числовому ID. Первая версия (без префикса и /v1) "+
+			"устарела: ее ответы содержат заголовки Deprecation, Sunset и Link на /v2.")
+	addCommon(d)
+	c := &d.Components
+
+	id := String().Matching(`^[0-9]+$`)
+	friends := Map(String()).Keys(id).Nullable().Describe("ID друга - имя друга")
+	c.Schemas["User"] = Object(map[string]*Schema{
+		"name":    String(),
+		"age":     Integer(),
+		"friends": friends,
+	}, "name", "age", "friends")
+	c.Schemas["NewUser"] = Object(map[string]*Schema{
+		"name":     String().MinLen(1).Describe("без двоеточия"),
+		"age":      Integer().Min(0).Describe("не указан - 0"),
+		"friends":  friends,
+		"password": String().MinLen(8).Describe("пароль для входа; логин - ID созданного пользователя"),
+	}, "name")
+	c.Schemas["Friendship"] = Object(map[string]*Schema{
+		"sourceId": Integer().Describe("ID инициатора"),
+		"targetId": Integer().Describe("ID друга"),
+	}, "sourceId", "targetId")
+	c.Schemas["NotFound"] = Object(map[string]*Schema{
+		"code":       Integer(),
+		"text":       String(),
+		"id":         String(),
+		"request_id": String(),
+	}, "code", "text")
+	c.Schemas["AdminUser"] = Object(map[string]*Schema{
+		"name":      String(),
+		"age":       Integer(),
+		"friends":   friends,
+		"role":      String().OneOf("user", "auditor", "admin"),
+		"suspended": Boolean(),
+	}, "name", "age", "role", "suspended")
+	c.Schemas["AdminPatch"] = Object(map[string]*Schema{
+		"age":  Integer().Nullable(),
+		"role": String().OneOf("user", "auditor", "admin").Describe("требует право roles:manage"),
+	})
+
+	userID := Integer().Describe("ID пользователя")
+	d.Add(http.MethodGet, "/", Op("index", "Начальная страница", "docs").
+		Text(200, "приветствие"))
+	//первая версия: без префикса (как раньше) и под /v1; обе устарели
+	for _, prefix := range []string{"", "/v1"} {
+		gorillaV1(d, prefix)
+	}
+	addV2(d)
+	addGraphQL(d)
+	addEvents(d)
+	addNotify(d)
+	addWebhooks(d)
+	addAudit(d)
+	addRetention(d)
+
+	d.Add(http.MethodGet, "/admin/users", Op("adminUserIndex", "Пользователи с ролями и блокировками", "admin").Secured().
+		JSON(200, "пользователи по ID", Map(Ref("AdminUser")).Keys(id)))
+	d.Add(http.MethodPatch, "/admin/users/{userId}", Op("adminUserPatch", "Изменение возраста и роли", "admin").Secured().
+		Param("userId", "", userID).
+		Body(Ref("AdminPatch")).
+		JSON(200, "пользователь", Ref("AdminUser")).
+		JSON(400, "некорректный запрос", Ref(SchemaError)).
+		JSON(404, "пользователь не найден", Ref(SchemaError)).
+		JSON(409, "возраст вычисляется по дате рождения", Ref(SchemaError)))
+	for _, a := range []struct{ path, id, summary string }{
+		{"/admin/users/{userId}/suspend", "adminUserSuspend", "Блокировка пользователя"},
+		{"/admin/users/{userId}/reinstate", "adminUserReinstate", "Снятие блокировки"},
+	} {
+		d.Add(http.MethodPost, a.path, Op(a.id, a.summary, "admin").Secured().
+			Param("userId", "", userID).
+			JSON(200, "пользователь", Ref("AdminUser")).
+			JSON(404, "пользователь не найден", Ref(SchemaError)))
+	}
+	d.Add(http.MethodDelete, "/admin/users/{userId}", Op("adminDeleteUser", "Удаление любого пользователя (с возможностью восстановить)", "admin").Secured().
+		Param("userId", "", userID).
+		Text(200, "сообщение и оставшиеся пользователи в JSON").
+		Text(404, "пользователь не найден"))
+	d.Add(http.MethodDelete, "/admin/apikeys/{keyId}", Op("revokeAPIKey", "Отзыв ключа API", "admin").Secured().
+		Empty(204, "ключ отозван").
+		JSON(404, "ключ не найден", Ref(SchemaError)))
+	return d
+}
+
+// gorillaV1 описывает маршруты первой версии с префиксом prefix
+func gorillaV1(d *Document, prefix string) {
+	userID := Integer().Describe("ID пользователя")
+	id := String().Matching(`^[0-9]+$`)
+	d.Add(http.MethodGet, prefix+"/users", v1Op(prefix, "userIndex", "Все пользователи", "users").
+		JSON(200, "пользователи по ID", Map(Ref("User")).Keys(id)))
+	d.Add(http.MethodGet, prefix+"/users/{userId}", v1Op(prefix, "userShow", "Пользователь по ID", "users").
+		Param("userId", "", userID).
+		JSON(200, "пользователь", Ref("User")).
+		JSON(200, "пользователь не найден (код в теле)", Ref("NotFound")))
+	d.Add(http.MethodGet, prefix+"/users/friends/{userId}", v1Op(prefix, "friendsUserShow", "Друзья пользователя", "friends").
+		Param("userId", "", userID).
+		Mixed(200, "друзья в JSON и сообщение").
+		JSON(404, "пользователь не найден", Ref("NotFound")))
+
+	d.Add(http.MethodPost, prefix+"/users", v1Op(prefix, "userCreate", "Создание пользователя (с паролем - и учетной записи)", "users").
+		Body(Ref("NewUser")).
+		Mixed(201, "сообщение и созданный пользователь в JSON").
+		Mixed(400, "некорректный запрос").
+		JSON(400, "пароль слишком простой", Ref(SchemaError)).
+		JSON(409, "учетная запись с таким логином уже есть", Ref(SchemaError)))
+	d.Add(http.MethodPost, prefix+"/friends", v1Op(prefix, "makeFriends", "Дружба двух пользователей (от имени sourceId)", "friends").Secured().
+		Body(Ref("Friendship")).
+		Mixed(200, "все пользователи в JSON и сообщение").
+		Mixed(400, "некорректный запрос или дружба с собой").
+		Mixed(404, "кого-то нет в базе"))
+	d.Add(http.MethodPut, prefix+"/users/{userId}", v1Op(prefix, "updateAge", "Изменение своего возраста", "users").Secured().
+		Param("userId", "", userID).
+		Body(Integer().Describe("новый возраст")).
+		Mixed(200, "возраст обновлен").
+		Text(400, "некорректный запрос или возраст").
+		Mixed(404, "пользователь не найден; сообщение и все пользователи в JSON").
+		JSON(409, "возраст вычисляется по дате рождения", Ref(SchemaError)))
+	d.Add(http.MethodDelete, prefix+"/users/{userId}", v1Op(prefix, "deleteUser", "Удаление себя", "users").Secured().
+		Param("userId", "", userID).
+		Text(200, "сообщение и оставшиеся пользователи в JSON").
+		Text(404, "пользователь не найден"))
+}
@@ -0,0 +1,35 @@
+package openapi
+
+import "net/http"
+
+// addGraphQL описывает адрес GraphQL; общий для обоих сервисов. Схема самих запросов -
+// в схеме GraphQL (интроспекция), здесь - только конверт запроса и ответа.
+func addGraphQL(d *Document) {
+	c := &d.Components
+	c.Schemas["GraphQLRequest"] = Object(map[string]*Schema{
+		"query":         String().MinLen(1).Describe("запрос или изменение на языке GraphQL"),
+		"operationName": String().Describe("операция, если в запросе их несколько"),
+		"variables":     Map(nil).Open().Nullable(),
+	}, "query")
+	c.Schemas["GraphQLResponse"] = Object(map[string]*Schema{
+		"data": Map(nil).Open().Nullable(),
+		"errors": Array(Object(map[string]*Schema{
+			"message":    String(),
+			"locations":  Array(Map(nil).Open()),
+			"path":       Array(nil),
+			"extensions": Map(nil).Open().Describe("code: BAD_USER_INPUT, NOT_FOUND, CONFLICT, UNAUTHENTICATED, FORBIDDEN, QUERY_TOO_COMPLEX, ..."),
+		}, "message").Open()),
+	})
+
+	d.Add(http.MethodGet, "/graphql", Op("graphqlQuery", "Запрос GraphQL (только чтение)", "graphql").
+		Query("query", "запрос на языке GraphQL", String().MinLen(1)).
+		Query("operationName", "", String()).
+		Query("variables", "переменные в JSON", String()).
+		JSON(200, "результат; ошибки отдельных полей - в errors", Ref("GraphQLResponse")).
+		JSON(400, "запрос не разобран, не соответствует схеме или слишком сложен", Ref("GraphQLResponse")).
+		JSON(405, "изменения (mutation) - только методом POST", Ref("GraphQLResponse")))
+	d.Add(http.MethodPost, "/graphql", Op("graphql", "Запрос или изменение GraphQL", "graphql").
+		Body(Ref("GraphQLRequest")).
+		JSON(200, "результат; ошибки отдельных полей - в errors", Ref("GraphQLResponse")).
+		JSON(400, "запрос не разобран, не соответствует схеме или слишком сложен", Ref("GraphQLResponse")))
+}
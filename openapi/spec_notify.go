@@ -0,0 +1,15 @@
+package openapi
+
+import "net/http"
+
+// addNotify описывает уведомления о дружбе по WebSocket; общие для обоих сервисов
+func addNotify(d *Document) {
+	d.Add(http.MethodGet, "/ws/notifications", Op("notifications", "Уведомления о дружбе (WebSocket)", "events").Secured().
+		Query("access_token", "токен доступа для браузеров, где нельзя задать Authorization", String()).
+		Empty(101, "соединение WebSocket: сервер присылает {\"type\":\"friend.added|friend.removed|friend.deleted\","+
+			"\"id\",\"time\",\"user\":{\"id\",\"name\"},\"dropped\"}, клиент - {\"action\":\"subscribe|unsubscribe\",\"types\":[...]}").
+		Ref(401, RespUnauthorized).
+		JSON(403, "учетная запись заблокирована", Ref(SchemaError)).
+		Text(403, "чужой Origin").
+		Text(400, "запрос не является переходом на WebSocket"))
+}
@@ -0,0 +1,79 @@
+package openapi
+
+import (
+	"maps"
+
+	"Network-exchange/core"
+)
+
+// Схемы дополнительных полей профиля (ProfileFields)
+const (
+	SchemaCustomFields      = "CustomFields"
+	SchemaCustomFieldsPatch = "CustomFieldsPatch" //null удаляет поле
+)
+
+// userSchema - пользователь core.User: основные свойства props (обязательные - required)
+// и профиль
+func userSchema(props map[string]*Schema, required ...string) *Schema {
+	maps.Copy(props, profileProps())
+	props["email"].Describe("уникален без учета регистра; виден только самому пользователю и с правом users:read")
+	props["birth_date"].Describe("ГГГГ-ММ-ДД; видна только самому пользователю и с правом users:read")
+	props["created_at"] = String().Formatted("date-time").Describe("нет - пользователь из файла выгрузки")
+	props["updated_at"] = String().Formatted("date-time").Describe("последнее изменение возраста или профиля")
+	return Object(props, required...)
+}
+
+// profileProps - поля профиля (общие для пользователя и тела запроса на создание)
+func profileProps() map[string]*Schema {
+	return map[string]*Schema{
+		"email":        String().Formatted("email").Describe("уникален без учета регистра"),
+		"display_name": String().MaxLen(core.MaxDisplayName),
+		"bio":          String().MaxLen(core.MaxBio),
+		"city":         String().MaxLen(core.MaxCity),
+		"birth_date":   String().Formatted("date").Describe("ГГГГ-ММ-ДД; возраст вычисляется по ней"),
+		"avatar_url":   String().Formatted("uri").MaxLen(core.MaxURL).Describe("http или https"),
+		"custom":       Ref(SchemaCustomFields),
+	}
+}
+
+// addProfile описывает дополнительные поля профиля без объявленных полей
+func addProfile(d *Document) {
+	ProfileFields(d, nil)
+}
+
+// ProfileFields описывает объявленные дополнительные поля профиля (core.Store.DeclareFields)
+func ProfileFields(d *Document, fields []core.Field) {
+	props, patch := map[string]*Schema{}, map[string]*Schema{}
+	for _, f := range fields {
+		props[f.Name], patch[f.Name] = fieldSchema(f), fieldSchema(f).Nullable()
+		if f.Enum != nil {
+			patch[f.Name].Enum = append(patch[f.Name].Enum, nil) //null - тоже допустимое значение
+		}
+	}
+	d.Components.Schemas[SchemaCustomFields] = Object(props).Describe("дополнительные поля профиля (PROFILE_FIELDS)")
+	d.Components.Schemas[SchemaCustomFieldsPatch] = Object(patch).Describe("null удаляет поле")
+}
+
+func fieldSchema(f core.Field) *Schema {
+	switch f.Type {
+	case core.FieldInteger:
+		return Integer()
+	case core.FieldNumber:
+		return Number()
+	case core.FieldBoolean:
+		return Boolean()
+	case core.FieldDate:
+		return String().Formatted("date").Describe("ГГГГ-ММ-ДД")
+	case core.FieldURL:
+		return String().Formatted("uri").MaxLen(core.MaxURL)
+	}
+	s := String().MinLen(1).MaxLen(core.MaxFieldString)
+	if f.Enum != nil {
+		enum := make([]interface{}, len(f.Enum))
+		for i, v := range f.Enum {
+			enum[i] = v
+		}
+		s.OneOf(enum...)
+	}
+	return s
+}
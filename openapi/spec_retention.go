@@ -0,0 +1,39 @@
+package openapi
+
+import "net/http"
+
+// addRetention описывает восстановление и окончательное удаление удаленных; общее для обоих сервисов
+func addRetention(d *Document) {
+	c := &d.Components
+	c.Schemas["DeletedUser"] = Object(map[string]*Schema{
+		"user": userSchema(map[string]*Schema{
+			"id":      Integer(),
+			"name":    String(),
+			"age":     Integer(),
+			"friends": Array(Integer()).Describe("друзья на момент удаления; дружба восстанавливается с неудаленными"),
+		}, "id", "name", "age", "friends"),
+		"deleted_at": String().Formatted("date-time"),
+		"purge_at":   String().Formatted("date-time").Describe("после - восстановить нельзя, пользователь будет стерт"),
+	}, "user", "deleted_at", "purge_at")
+
+	id := Integer().Min(1).Describe("ID удаленного пользователя")
+	d.Add(http.MethodGet, "/admin/deleted-users", Op("listDeletedUsers", "Удаленные, которых можно восстановить", "admin").Secured().
+		JSON(200, "по времени удаления", Array(Ref("DeletedUser"))))
+	d.Add(http.MethodPost, "/admin/deleted-users/{userId}/restore", Op("restoreUser", "Восстановление удаленного вместе с дружбой", "admin").Secured().
+		Param("userId", "", id).
+		JSON(200, "восстановленный пользователь", userSchema(map[string]*Schema{
+			"id":      Integer(),
+			"name":    String(),
+			"age":     Integer(),
+			"friends": Array(Integer()),
+		}, "id", "name", "age", "friends")).
+		JSON(400, "некорректный ID", Ref(SchemaError)).
+		JSON(404, "удаленный пользователь не найден", Ref(SchemaError)).
+		JSON(409, "имя или email уже заняты", Ref(SchemaError)).
+		JSON(410, "срок восстановления истек", Ref(SchemaError)))
+	d.Add(http.MethodDelete, "/admin/deleted-users/{userId}", Op("eraseUser", "Окончательное удаление до срока", "admin").Secured().
+		Param("userId", "", id).
+		Empty(204, "пользователь стерт").
+		JSON(400, "некорректный ID", Ref(SchemaError)).
+		JSON(404, "удаленный пользователь не найден", Ref(SchemaError)))
+}
@@ -0,0 +1,290 @@
+package openapi
+
+import (
+	"net/http"
+	"strings"
+
+	"Network-exchange/problem"
+)
+
+// SchemaProblem - ошибка в формате problem+json (вторая версия)
+const SchemaProblem = "Problem"
+
+// v1Op - операция первой версии: без префикса - прежний operationId, под /v1 - с приставкой v1
+func v1Op(prefix, id, summary string, tags ...string) *Operation {
+	if prefix != "" {
+		id = "v1" + strings.ToUpper(id[:1]) + id[1:]
+	}
+	return Op(id, summary, tags...).Deprecate()
+}
+
+// Problem описывает ответ с ошибкой в формате problem+json
+func (o *Operation) Problem(status int, description string) *Operation {
+	return o.content(status, description, problem.ContentType, Ref(SchemaProblem))
+}
+
+// problems сообщает, отвечает ли операция ошибками в формате problem+json
+func (o *Operation) problems() bool {
+	for _, resp := range o.Responses {
+		if _, ok := resp.Content[problem.ContentType]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// securedV2 - аутентификация второй версии: отказы обработчиков - problem+json,
+// отказы проверки токена до обработчика - прежний JSON
+func securedV2(o *Operation) *Operation {
+	return o.Secured().
+		JSON(401, "требуется вход или токен недействителен", Ref(SchemaError)).
+		Problem(401, "требуется вход").
+		Problem(403, "недостаточно прав (или пользователь заблокирован)")
+}
+
+// addV2 описывает вторую версию: ресурсы по ID, ошибки problem+json; общая для обоих сервисов
+func addV2(d *Document) {
+	c := &d.Components
+	c.Schemas[SchemaProblem] = Object(map[string]*Schema{
+		"type":       String().Describe("тип ошибки: /problems/validation, /problems/not-found, /problems/conflict, ..."),
+		"title":      String(),
+		"status":     Integer(),
+		"detail":     String(),
+		"instance":   String().Describe("путь запроса"),
+		"request_id": String(),
+		"errors": Array(Object(map[string]*Schema{
+			"поле":   String(),
+			"ошибка": String(),
+		}, "поле", "ошибка")),
+	}, "type", "title", "status")
+	addProfile(d)
+	c.Schemas["UserV2"] = userSchema(map[string]*Schema{
+		"id":      Integer(),
+		"name":    String(),
+		"age":     Integer().Describe("при заданной birth_date - по ней"),
+		"friends": Array(Integer()).Describe("ID друзей"),
+	}, "id", "name", "age", "friends")
+	c.Schemas["UserPageV2"] = Object(map[string]*Schema{
+		"items":  Array(Ref("UserV2")),
+		"total":  Integer().Describe("всего подходящих"),
+		"limit":  Integer(),
+		"offset": Integer(),
+	}, "items", "total", "limit", "offset")
+	newUser := profileProps()
+	newUser["name"] = String().MinLen(1).MaxLen(100).Describe("без двоеточия")
+	newUser["age"] = Integer().Min(0).Describe("обязателен без birth_date; с ней не учитывается")
+	newUser["password"] = String().MinLen(8).Describe("пароль для входа; без него учетная запись не создается")
+	c.Schemas["NewUserV2"] = Object(newUser, "name")
+	patch := profileProps()
+	patch["age"] = Integer().Min(0).Describe("нельзя при заданной birth_date")
+	patch["custom"] = Ref(SchemaCustomFieldsPatch)
+	c.Schemas["UserPatchV2"] = Object(patch).Describe("поля без значения не меняются; пустая строка очищает поле профиля")
+	c.Schemas["EventV2"] = Object(map[string]*Schema{
+		"seq": Integer().Describe("номер изменения; журнал - без пропусков"),
+		"type": String().OneOf("user.created", "user.updated", "user.profile_updated", "user.deleted", "user.restored", "user.purged",
+			"friendship.created", "friendship.deleted", "friendship.updated", "user.blocked", "user.unblocked",
+			"follow.requested", "follow.declined", "follow.created", "follow.deleted",
+			"user.follow_approval_on", "user.follow_approval_off").
+			Describe("регистрация, смена возраста, изменение профиля, удаление (с возможностью восстановить), восстановление, " +
+				"окончательное удаление, дружба возникла, дружба прекращена, метки и близость друга изменены, блокировка (friend_id - заблокированный) " +
+				"и ее снятие, заявка на подписку, ее отклонение, подписка (user - подписчик, friend_id - на кого), " +
+				"отписка, включение и выключение одобрения подписчиков; меток, блокировок, заявок и настроек нет " +
+				"в истории пользователя"),
+		"time":       String().Formatted("date-time"),
+		"user":       Ref("UserV2").Describe("пользователь после изменения (при удалении - только id); для дружбы - инициатор; от удаленного окончательно во всех записях остается только id"),
+		"friend_id":  Integer().Describe("второй участник дружбы"),
+		"friendship": Ref("Friendship").Describe("для friendship.updated - метки и близость после изменения; для friendship.created после восстановления удаленного - прежние since и initiator_id"),
+	}, "seq", "type", "time", "user")
+	c.Schemas["Friendship"] = Object(map[string]*Schema{
+		"user_id":      Integer(),
+		"friend_id":    Integer(),
+		"initiator_id": Integer().Describe("кто предложил дружбу; нет - дружба из файла выгрузки"),
+		"since":        String().Formatted("date-time").Describe("когда возникла; нет - дружба из файла выгрузки"),
+		"labels":       Array(String().MinLen(1).MaxLen(32)).Describe("метки пользователя user_id, например family, colleague"),
+		"closeness":    Integer().Min(0).Describe("близость по оценке user_id, от 1 до 10; 0 - не задана"),
+	}, "user_id", "friend_id", "labels", "closeness")
+	c.Schemas["FriendshipV2"] = Object(map[string]*Schema{
+		"user_id":      Integer(),
+		"friend_id":    Integer(),
+		"initiator_id": Integer(),
+		"since":        String().Formatted("date-time"),
+		"labels":       Array(String()),
+		"closeness":    Integer(),
+		"friend":       Ref("UserV2"),
+	}, "user_id", "friend_id", "labels", "closeness", "friend")
+	c.Schemas["FriendshipPageV2"] = Object(map[string]*Schema{
+		"items": Array(Ref("FriendshipV2")),
+		"total": Integer(),
+	}, "items", "total")
+	c.Schemas["FriendshipPatchV2"] = Object(map[string]*Schema{
+		"labels":    Array(String().MinLen(1).MaxLen(32)).Describe("не больше 10; [] - снять все метки"),
+		"closeness": Integer().Min(0).Describe("от 0 до 10"),
+	})
+	c.Schemas["FollowStats"] = Object(map[string]*Schema{
+		"followers":         Integer().Describe("число подписчиков"),
+		"following":         Integer().Describe("на скольких подписан"),
+		"requires_approval": Boolean().Describe("подписчиков одобряет сам пользователь"),
+	}, "followers", "following", "requires_approval")
+	c.Schemas["FollowSettings"] = Object(map[string]*Schema{
+		"requires_approval": Boolean().Describe("false - ждущие заявки одобряются"),
+	})
+
+	id := Integer().Min(1).Describe("ID пользователя")
+	friendID := Integer().Min(1).Describe("ID друга")
+	asOf := String().Formatted("date-time")
+	const asOfText = "состояние на этот момент (RFC 3339), восстановленное по журналу изменений; только после входа"
+	d.Add(http.MethodGet, "/v2/users", Op("listUsers", "Поиск пользователей", "v2").
+		Query("as_of", asOfText, asOf).
+		Query("name", "часть имени без учета регистра", String()).
+		Query("min_age", "", Integer().Min(0)).
+		Query("max_age", "", Integer().Min(0)).
+		Query("limit", "размер страницы (по умолчанию 50, не больше 500)", Integer().Min(1)).
+		Query("offset", "", Integer().Min(0)).
+		JSON(200, "страница пользователей по возрастанию ID", Ref("UserPageV2")).
+		Problem(400, "некорректный запрос").
+		Problem(401, "as_of без входа"))
+	d.Add(http.MethodPost, "/v2/users", Op("createUser", "Создание пользователя (с паролем - и учетной записи)", "v2").
+		Body(Ref("NewUserV2")).
+		JSON(201, "пользователь; заголовок Location - его адрес", Ref("UserV2")).
+		Problem(400, "некорректный запрос или профиль").
+		Problem(409, "имя, логин или email заняты"))
+	d.Add(http.MethodGet, "/v2/users/{id}", Op("getUser", "Пользователь", "v2").
+		Param("id", "", id).
+		Query("as_of", asOfText, asOf).
+		JSON(200, "пользователь", Ref("UserV2")).
+		Problem(400, "некорректный запрос").
+		Problem(401, "as_of без входа").
+		Problem(404, "пользователь не найден"))
+	d.Add(http.MethodPatch, "/v2/users/{id}", securedV2(Op("patchUser", "Изменение себя", "v2")).
+		Param("id", "", id).
+		Body(Ref("UserPatchV2")).
+		JSON(200, "пользователь", Ref("UserV2")).
+		Problem(400, "некорректный запрос или профиль").
+		Problem(404, "пользователь не найден").
+		Problem(409, "email занят или возраст вычисляется по дате рождения"))
+	d.Add(http.MethodDelete, "/v2/users/{id}", securedV2(Op("deleteUser", "Удаление себя", "v2")).
+		Param("id", "", id).
+		Empty(204, "пользователь удален").
+		Problem(404, "пользователь не найден"))
+	d.Add(http.MethodGet, "/v2/users/{id}/friends", Op("listFriends", "Друзья пользователя", "v2").
+		Param("id", "", id).
+		Query("as_of", asOfText, asOf).
+		JSON(200, "друзья в порядке появления дружбы", Ref("UserPageV2")).
+		Problem(400, "некорректный запрос").
+		Problem(401, "as_of без входа").
+		Problem(404, "пользователь не найден"))
+	d.Add(http.MethodGet, "/v2/users/{id}/history", Op("userHistory", "Изменения с участием пользователя", "v2").
+		Param("id", "", id).
+		JSON(200, "изменения по порядку (и для помеченного удаленным)", Array(Ref("EventV2"))).
+		Problem(400, "некорректный запрос").
+		Problem(404, "пользователь не найден или удален окончательно"))
+	d.Add(http.MethodGet, "/v2/journal", securedV2(Op("journal", "Журнал изменений (право audit:read)", "v2")).
+		Query("after", "seq последнего полученного изменения", Integer().Min(0)).
+		Query("limit", "размер страницы (по умолчанию 50, не больше 500)", Integer().Min(1)).
+		JSON(200, "изменения по порядку; пустой список - журнал прочитан", Array(Ref("EventV2"))))
+	d.Add(http.MethodPut, "/v2/users/{id}/friends/{friendId}", securedV2(Op("addFriend", "Дружба от имени {id}", "v2")).
+		Param("id", "", id).
+		Param("friendId", "", friendID).
+		Empty(201, "дружба создана").
+		Empty(204, "уже друзья").
+		Problem(404, "пользователь не найден").
+		Problem(409, "дружба с самим собой"))
+	d.Add(http.MethodDelete, "/v2/users/{id}/friends/{friendId}", securedV2(Op("removeFriend", "Прекращение дружбы", "v2")).
+		Param("id", "", id).
+		Param("friendId", "", friendID).
+		Empty(204, "дружба прекращена").
+		Problem(404, "пользователь не найден или не друзья").
+		Problem(409, "дружба с самим собой"))
+	d.Add(http.MethodGet, "/v2/users/{id}/friendships", securedV2(Op("listFriendships", "Дружбы пользователя с метками и близостью", "v2")).
+		Param("id", "", id).
+		Query("label", "только с этой меткой", String()).
+		Query("min_closeness", "близость не меньше", Integer().Min(0)).
+		Query("max_closeness", "близость не больше", Integer().Min(0)).
+		Query("as_of", asOfText, asOf).
+		JSON(200, "дружбы в порядке появления", Ref("FriendshipPageV2")).
+		Problem(400, "некорректный запрос").
+		Problem(404, "пользователь не найден"))
+	d.Add(http.MethodGet, "/v2/users/{id}/friendships/{friendId}", securedV2(Op("getFriendship", "Дружба с {friendId}", "v2")).
+		Param("id", "", id).
+		Param("friendId", "", friendID).
+		Query("as_of", asOfText, asOf).
+		JSON(200, "дружба", Ref("FriendshipV2")).
+		Problem(400, "некорректный запрос").
+		Problem(404, "пользователь не найден или не друзья"))
+	d.Add(http.MethodPatch, "/v2/users/{id}/friendships/{friendId}", securedV2(Op("patchFriendship", "Метки и близость друга", "v2")).
+		Param("id", "", id).
+		Param("friendId", "", friendID).
+		Body(Ref("FriendshipPatchV2")).
+		JSON(200, "дружба", Ref("FriendshipV2")).
+		Problem(400, "некорректный запрос или метки").
+		Problem(404, "пользователь не найден или не друзья"))
+	blockedID := Integer().Min(1).Describe("ID заблокированного")
+	d.Add(http.MethodGet, "/v2/users/{id}/blocks", securedV2(Op("listBlocked", "Заблокированные пользователем {id}", "v2")).
+		Param("id", "", id).
+		JSON(200, "заблокированные по возрастанию ID", Ref("UserPageV2")).
+		Problem(400, "некорректный запрос").
+		Problem(404, "пользователь не найден"))
+	d.Add(http.MethodPut, "/v2/users/{id}/blocks/{blockedId}", securedV2(Op("blockUser", "Блокировка от имени {id}: дружба прекращается", "v2")).
+		Param("id", "", id).
+		Param("blockedId", "", blockedID).
+		Empty(201, "заблокирован").
+		Empty(204, "уже заблокирован").
+		Problem(404, "пользователь не найден").
+		Problem(409, "блокировка самого себя"))
+	d.Add(http.MethodDelete, "/v2/users/{id}/blocks/{blockedId}", securedV2(Op("unblockUser", "Снятие блокировки", "v2")).
+		Param("id", "", id).
+		Param("blockedId", "", blockedID).
+		Empty(204, "блокировка снята").
+		Problem(404, "пользователь не найден или не заблокирован"))
+	d.Add(http.MethodGet, "/v2/users/{id}/follows", Op("getFollows", "Число подписчиков и подписок", "v2").
+		Param("id", "", id).
+		JSON(200, "подписки", Ref("FollowStats")).
+		Problem(400, "некорректный запрос").
+		Problem(404, "пользователь не найден"))
+	d.Add(http.MethodPatch, "/v2/users/{id}/follows", securedV2(Op("patchFollows", "Одобрение подписчиков", "v2")).
+		Param("id", "", id).
+		Body(Ref("FollowSettings")).
+		JSON(200, "подписки", Ref("FollowStats")).
+		Problem(400, "некорректный запрос").
+		Problem(404, "пользователь не найден"))
+	d.Add(http.MethodGet, "/v2/users/{id}/followers", Op("listFollowers", "Подписчики пользователя", "v2").
+		Param("id", "", id).
+		JSON(200, "подписчики по возрастанию ID", Ref("UserPageV2")).
+		Problem(400, "некорректный запрос").
+		Problem(404, "пользователь не найден"))
+	d.Add(http.MethodGet, "/v2/users/{id}/following", Op("listFollowing", "На кого подписан пользователь", "v2").
+		Param("id", "", id).
+		JSON(200, "подписки по возрастанию ID", Ref("UserPageV2")).
+		Problem(400, "некорректный запрос").
+		Problem(404, "пользователь не найден"))
+	d.Add(http.MethodGet, "/v2/users/{id}/follow-requests", securedV2(Op("listFollowRequests", "Заявки на подписку, ждущие одобрения", "v2")).
+		Param("id", "", id).
+		JSON(200, "заявители по возрастанию ID", Ref("UserPageV2")).
+		Problem(400, "некорректный запрос").
+		Problem(404, "пользователь не найден"))
+	targetID := Integer().Min(1).Describe("на кого подписка")
+	d.Add(http.MethodPut, "/v2/users/{id}/following/{targetId}", securedV2(Op("follow", "Подписка от имени {id}", "v2")).
+		Param("id", "", id).
+		Param("targetId", "", targetID).
+		Empty(201, "подписка действует").
+		Empty(202, "заявка ждет одобрения {targetId}").
+		Empty(204, "подписка уже была").
+		Problem(404, "пользователь не найден").
+		Problem(409, "подписка на самого себя"))
+	d.Add(http.MethodDelete, "/v2/users/{id}/following/{targetId}", securedV2(Op("unfollow", "Отписка или отзыв заявки", "v2")).
+		Param("id", "", id).
+		Param("targetId", "", targetID).
+		Empty(204, "подписка прекращена").
+		Problem(404, "пользователь не найден или подписки нет"))
+	followerID := Integer().Min(1).Describe("ID подписчика")
+	d.Add(http.MethodPut, "/v2/users/{id}/followers/{followerId}", securedV2(Op("approveFollower", "Одобрение заявки на подписку", "v2")).
+		Param("id", "", id).
+		Param("followerId", "", followerID).
+		Empty(201, "подписка действует").
+		Problem(404, "пользователь или заявка не найдены"))
+	d.Add(http.MethodDelete, "/v2/users/{id}/followers/{followerId}", securedV2(Op("removeFollower", "Удаление подписчика или отклонение заявки", "v2")).
+		Param("id", "", id).
+		Param("followerId", "", followerID).
+		Empty(204, "подписка прекращена или заявка отклонена").
+		Problem(404, "пользователь не найден или подписки нет"))
+}
@@ -0,0 +1,64 @@
+package openapi
+
+import "net/http"
+
+// addWebhooks описывает управление вебхуками; общее для обоих сервисов
+func addWebhooks(d *Document) {
+	c := &d.Components
+	events := Array(String().OneOf("user.created", "user.deleted")).Describe("виды событий")
+	c.Schemas["Webhook"] = Object(map[string]*Schema{
+		"id":         String(),
+		"url":        String().Formatted("uri"),
+		"events":     events,
+		"owner":      String(),
+		"created_at": String().Formatted("date-time"),
+		"secret": String().Describe("ключ подписи X-Webhook-Signature (HMAC-SHA256 от " +
+			"\"<X-Webhook-Timestamp>.<тело>\"); только в ответе на создание"),
+	}, "id", "url", "events", "owner", "created_at")
+	c.Schemas["NewWebhook"] = Object(map[string]*Schema{
+		"url":    String().Formatted("uri").Describe("http или https"),
+		"events": events,
+		"secret": String().MinLen(16).Describe("без него секрет будет создан"),
+	}, "url", "events")
+	c.Schemas["WebhookDelivery"] = Object(map[string]*Schema{
+		"id":              String().Describe("заголовок X-Webhook-ID; не меняется между попытками"),
+		"subscription_id": String(),
+		"event":           String(),
+		"event_id":        Integer().Describe("номер изменения хранилища (поле id тела)"),
+		"state":           String().OneOf("pending", "delivered", "dead", "canceled"),
+		"created_at":      String().Formatted("date-time"),
+		"next_attempt_at": String().Formatted("date-time"),
+		"attempts": Array(Object(map[string]*Schema{
+			"at":          String().Formatted("date-time"),
+			"status":      Integer().Describe("код ответа получателя"),
+			"error":       String(),
+			"duration_ms": &Schema{Type: Types{"number"}},
+		}, "at", "duration_ms")),
+	}, "id", "subscription_id", "event", "event_id", "state", "created_at", "attempts")
+
+	id := String().Describe("ID вебхука")
+	d.Add(http.MethodGet, "/admin/webhooks", Op("listWebhooks", "Подписки на вебхуки", "admin").Secured().
+		JSON(200, "подписки", Array(Ref("Webhook"))))
+	d.Add(http.MethodPost, "/admin/webhooks", Op("createWebhook", "Подписка на вебхуки", "admin").Secured().
+		Body(Ref("NewWebhook")).
+		JSON(201, "подписка (поле secret показывается один раз)", Ref("Webhook")).
+		JSON(400, "некорректный запрос", Ref(SchemaError)))
+	d.Add(http.MethodGet, "/admin/webhooks/{webhookId}", Op("getWebhook", "Подписка", "admin").Secured().
+		Param("webhookId", "", id).
+		JSON(200, "подписка", Ref("Webhook")).
+		JSON(404, "вебхук не найден", Ref(SchemaError)))
+	d.Add(http.MethodDelete, "/admin/webhooks/{webhookId}", Op("deleteWebhook", "Удаление подписки с историей доставок", "admin").Secured().
+		Param("webhookId", "", id).
+		Empty(204, "подписка удалена").
+		JSON(404, "вебхук не найден", Ref(SchemaError)))
+	d.Add(http.MethodGet, "/admin/webhooks/{webhookId}/deliveries", Op("listWebhookDeliveries", "Последние доставки подписки", "admin").Secured().
+		Param("webhookId", "", id).
+		JSON(200, "доставки, последние - первыми", Array(Ref("WebhookDelivery"))).
+		JSON(404, "вебхук не найден", Ref(SchemaError)))
+	d.Add(http.MethodGet, "/admin/webhooks/dead", Op("listDeadWebhooks", "Недоставленные после всех попыток", "admin").Secured().
+		JSON(200, "доставки, последние - первыми", Array(Ref("WebhookDelivery"))))
+	d.Add(http.MethodPost, "/admin/webhooks/dead/{deliveryId}/redeliver", Op("redeliverWebhook", "Повторная отправка недоставленного", "admin").Secured().
+		Param("deliveryId", "", String().Describe("ID доставки")).
+		JSON(202, "доставка снова в очереди", Ref("WebhookDelivery")).
+		JSON(404, "доставка не найдена среди недоставленных", Ref(SchemaError)))
+}
@@ -0,0 +1,210 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// FieldError - ошибка в одном поле запроса или ответа
+// (те же ключи, что и в ответах проверки "Джин")
+type FieldError struct {
+	Field   string `json:"поле"`
+	Message string `json:"ошибка"`
+}
+
+func (e FieldError) String() string { return e.Field + ": " + e.Message }
+
+// скомпилированные регулярные выражения схем
+var patterns sync.Map
+
+func match(pattern, s string) bool {
+	re, ok := patterns.Load(pattern)
+	if !ok {
+		re, _ = patterns.LoadOrStore(pattern, regexp.MustCompile(pattern))
+	}
+	return re.(*regexp.Regexp).MatchString(s)
+}
+
+// DecodeValue разбирает JSON так, чтобы числа сохранили точность (json.Number)
+func DecodeValue(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("лишние данные после JSON")
+	}
+	return v, nil
+}
+
+// Validate проверяет значение v (результат DecodeValue) по схеме s;
+// field - имя проверяемого поля в сообщениях
+func (d *Document) Validate(s *Schema, v interface{}, field string) []FieldError {
+	var errs []FieldError
+	d.validate(s, v, field, &errs)
+	return errs
+}
+
+func (d *Document) validate(s *Schema, v interface{}, field string, errs *[]FieldError) {
+	s = d.Resolve(s)
+	if s == nil {
+		return
+	}
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+	if len(s.AnyOf) > 0 {
+		for _, alt := range s.AnyOf {
+			if len(d.Validate(alt, v, field)) == 0 {
+				return
+			}
+		}
+		fail("Значение не подходит ни под один из вариантов")
+		return
+	}
+	if len(s.Type) > 0 && !hasType(s.Type, v) {
+		fail("Должно быть типа %s", strings.Join(s.Type, " или "))
+		return
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		fail("Допустимые значения: %v", s.Enum)
+	}
+	switch v := v.(type) {
+	case json.Number:
+		if s.Minimum != nil {
+			if f, _ := v.Float64(); f < *s.Minimum {
+				fail("Должно быть не меньше %v", *s.Minimum)
+			}
+		}
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.MinLength != nil && n < *s.MinLength {
+			if *s.MinLength == 1 {
+				fail("Это поле обязательно для заполнения")
+			} else {
+				fail("Должно содержать не меньше %d символов", *s.MinLength)
+			}
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			fail("Должно содержать не больше %d символов", *s.MaxLength)
+		}
+		if s.Pattern != "" && !match(s.Pattern, v) {
+			fail("Не соответствует шаблону %s", s.Pattern)
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, v); err != nil {
+				fail("Должно быть датой и временем RFC 3339")
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			d.validate(s.Items, item, field+"["+strconv.Itoa(i)+"]", errs)
+		}
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				*errs = append(*errs, FieldError{Field: join(field, name), Message: "Это поле обязательно для заполнения"})
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys) //стабильный порядок ошибок
+		for _, k := range keys {
+			if s.PropertyNames != nil {
+				d.validate(s.PropertyNames, k, join(field, k), errs)
+			}
+			if prop, ok := s.Properties[k]; ok {
+				d.validate(prop, v[k], join(field, k), errs)
+			} else if s.Closed {
+				*errs = append(*errs, FieldError{Field: join(field, k), Message: "Неизвестное поле"})
+			} else if s.AdditionalProperties != nil {
+				d.validate(s.AdditionalProperties, v[k], join(field, k), errs)
+			}
+		}
+	}
+}
+
+func join(field, name string) string {
+	if field == "" {
+		return name
+	}
+	return field + "." + name
+}
+
+func hasType(types Types, v interface{}) bool {
+	for _, t := range types {
+		switch t {
+		case "null":
+			if v == nil {
+				return true
+			}
+		case "boolean":
+			if _, ok := v.(bool); ok {
+				return true
+			}
+		case "string":
+			if _, ok := v.(string); ok {
+				return true
+			}
+		case "number":
+			if _, ok := v.(json.Number); ok {
+				return true
+			}
+		case "integer":
+			if n, ok := v.(json.Number); ok {
+				if f, ok := new(big.Float).SetString(n.String()); ok && f.IsInt() {
+					return true
+				}
+			}
+		case "array":
+			if _, ok := v.([]interface{}); ok {
+				return true
+			}
+		case "object":
+			if _, ok := v.(map[string]interface{}); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func inEnum(enum []interface{}, v interface{}) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// paramValue переводит строку из пути или запроса в значение для проверки по схеме
+func (d *Document) paramValue(s *Schema, raw string) interface{} {
+	if s = d.Resolve(s); s != nil {
+		for _, t := range s.Type {
+			switch t {
+			case "integer", "number":
+				if _, err := strconv.ParseFloat(raw, 64); err == nil {
+					return json.Number(raw)
+				}
+			case "boolean":
+				if b, err := strconv.ParseBool(raw); err == nil {
+					return b
+				}
+			}
+		}
+	}
+	return raw
+}
@@ -0,0 +1,63 @@
+// Package problem - ответы с ошибками в формате application/problem+json (RFC 9457),
+// которые используются в /v2
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"Network-exchange/logging"
+)
+
+// ContentType - тип содержимого ответа с ошибкой
+const ContentType = "application/problem+json"
+
+// Типы ошибок (относительные URI; по ним клиенты различают ошибки)
+const (
+	Validation   = "/problems/validation"
+	NotFound     = "/problems/not-found"
+	Conflict     = "/problems/conflict"
+	Unauthorized = "/problems/unauthorized"
+	Forbidden    = "/problems/forbidden"
+	TooLarge     = "/problems/too-large"
+	Internal     = "/problems/internal"
+)
+
+var titles = map[string]string{
+	Validation:   "Запрос не соответствует описанию API",
+	NotFound:     "Не найдено",
+	Conflict:     "Конфликт с текущим состоянием",
+	Unauthorized: "Требуется вход",
+	Forbidden:    "Недостаточно прав",
+	TooLarge:     "Тело запроса слишком большое",
+	Internal:     "Внутренняя ошибка сервера",
+}
+
+// Details - тело ответа с ошибкой
+type Details struct {
+	Type      string      `json:"type"`
+	Title     string      `json:"title"`
+	Status    int         `json:"status"`
+	Detail    string      `json:"detail,omitempty"`
+	Instance  string      `json:"instance,omitempty"` //путь запроса
+	RequestID string      `json:"request_id,omitempty"`
+	Errors    interface{} `json:"errors,omitempty"` //ошибки полей при Validation
+}
+
+// Write отвечает ошибкой типа typ; errors - ошибки полей (или nil)
+func Write(w http.ResponseWriter, r *http.Request, status int, typ, detail string, errors interface{}) {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="network-exchange"`)
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Details{
+		Type:      typ,
+		Title:     titles[typ],
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: logging.RequestID(r.Context()),
+		Errors:    errors,
+	})
+}
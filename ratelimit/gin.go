@@ -0,0 +1,15 @@
+package ratelimit
+
+import "github.com/gin-gonic/gin"
+
+// Gin - промежуточный обработчик "Джин". Ставится после проверки токена;
+// IP клиента определяется с учетом доверенных прокси (router.SetTrustedProxies).
+func (l *Limiter) Gin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !l.allow(c.Writer, c.Request, c.FullPath(), c.ClientIP()) {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"strconv"
+
+	"Network-exchange/logging"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// MethodGRPC - "метод" вызовов gRPC в ключах Routes: "GRPC /netx.v1.UserService/CreateUser"
+const MethodGRPC = "GRPC"
+
+// grpcAllow расходует токен вызова; при отказе - ошибка ResourceExhausted
+// и метаданные retry-after (секунды)
+func (l *Limiter) grpcAllow(ctx context.Context, fullMethod string) error {
+	lim := l.limitFor(MethodGRPC, fullMethod)
+	if lim.Rate <= 0 {
+		return nil
+	}
+	ip := ""
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		if ip, _, _ = net.SplitHostPort(p.Addr.String()); ip == "" {
+			ip = p.Addr.String()
+		}
+	}
+	res, err := l.Backend.Take(ctx, clientKey(ctx, ip)+"|"+MethodGRPC+" "+fullMethod, lim)
+	if err != nil { //хранилище недоступно - вызов не блокируем
+		logging.FromContext(ctx).Error("ограничитель частоты недоступен", "error", err)
+		return nil
+	}
+	if res.Allowed {
+		return nil
+	}
+	grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(seconds(res.RetryAfter))))
+	return status.Error(codes.ResourceExhausted, "слишком много запросов, повторите позже")
+}
+
+// UnaryServer - перехватчик gRPC; ставится после проверки владельца запроса
+func (l *Limiter) UnaryServer() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := l.grpcAllow(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServer - перехватчик потоковых вызовов gRPC (токен расходуется при открытии потока)
+func (l *Limiter) StreamServer() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := l.grpcAllow(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,29 @@
+package ratelimit
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Mux - промежуточный обработчик "Гориллы" (router.Use). Ставится после проверки токена;
+// IP клиента - адрес соединения.
+func (l *Limiter) Mux(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := r.URL.Path
+		if cr := mux.CurrentRoute(r); cr != nil {
+			if tpl, err := cr.GetPathTemplate(); err == nil {
+				route = tpl
+			}
+		}
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		if !l.allow(w, r, route, ip) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,106 @@
+// Package ratelimit — ограничение частоты запросов по алгоритму "корзина токенов"
+// для клиентов (ключ API, вошедший пользователь или IP) с лимитами на каждый маршрут.
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"Network-exchange/auth"
+	"Network-exchange/logging"
+)
+
+// Limit - лимит: средняя скорость и допустимый всплеск запросов
+type Limit struct {
+	Rate  float64 //токенов (запросов) в секунду
+	Burst int     //емкость корзины
+}
+
+// PerMinute - лимит n запросов в минуту со всплеском до n
+func PerMinute(n int) Limit {
+	return Limit{Rate: float64(n) / 60, Burst: n}
+}
+
+// Result - решение по одному запросу
+type Result struct {
+	Allowed    bool
+	Remaining  int           //оставшиеся токены
+	Reset      time.Duration //через сколько корзина снова заполнится полностью
+	RetryAfter time.Duration //через сколько появится токен (для отказа)
+}
+
+// Backend - хранилище корзин; по умолчанию в памяти, для нескольких экземпляров
+// сервиса можно подключить общее (например, Redis)
+type Backend interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// Limiter выбирает лимит маршрута и ключ клиента
+type Limiter struct {
+	Backend Backend
+	Default Limit            //лимит маршрутов без собственного
+	Routes  map[string]Limit //"POST /users" -> лимит
+}
+
+// New создает ограничитель с корзинами в памяти
+func New(def Limit, routes map[string]Limit) *Limiter {
+	return &Limiter{Backend: NewMemory(), Default: def, Routes: routes}
+}
+
+// limitFor - лимит маршрута "METHOD route"
+func (l *Limiter) limitFor(method, route string) Limit {
+	if lim, ok := l.Routes[method+" "+route]; ok {
+		return lim
+	}
+	return l.Default
+}
+
+// clientKey - ключ клиента: ключ API или пользователь (после проверки токена), иначе IP
+func clientKey(ctx context.Context, ip string) string {
+	if id, ok := auth.FromContext(ctx); ok {
+		if id.Scope != "" {
+			return id.Subject //"apikey:<ID>"
+		}
+		return "user:" + id.Subject
+	}
+	return "ip:" + ip
+}
+
+// allow расходует токен и выставляет заголовки RateLimit-*; false - ответ 429 уже отправлен
+func (l *Limiter) allow(w http.ResponseWriter, r *http.Request, route, ip string) bool {
+	lim := l.limitFor(r.Method, route)
+	if lim.Rate <= 0 { //лимит не задан
+		return true
+	}
+	key := clientKey(r.Context(), ip) + "|" + r.Method + " " + route
+	res, err := l.Backend.Take(r.Context(), key, lim)
+	if err != nil { //хранилище недоступно - запрос не блокируем
+		logging.FromContext(r.Context()).Error("ограничитель частоты недоступен", "error", err)
+		return true
+	}
+
+	h := w.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(lim.Burst))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(seconds(res.Reset)))
+	if res.Allowed {
+		return true
+	}
+	h.Set("Retry-After", strconv.Itoa(seconds(res.RetryAfter)))
+	h.Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":      "слишком много запросов, повторите позже",
+		"request_id": logging.RequestID(r.Context()),
+	})
+	return false
+}
+
+// seconds округляет длительность вверх до целых секунд
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,114 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"Network-exchange/auth"
+
+	"github.com/gorilla/mux"
+)
+
+// newMemory - корзины в памяти с управляемыми часами
+func newMemory() (*Memory, *time.Time) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+	return m, &now
+}
+
+// Корзина на 2 запроса с пополнением токен в секунду
+func TestMemoryRefill(t *testing.T) {
+	m, now := newMemory()
+	lim := Limit{Rate: 1, Burst: 2}
+	tests := []struct {
+		name       string
+		wait       time.Duration //пауза перед запросом
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+	}{
+		{"полная корзина", 0, true, 1, 0},
+		{"последний токен", 0, true, 0, 0},
+		{"корзина пуста", 0, false, 0, time.Second},
+		{"полтокена", 500 * time.Millisecond, false, 0, 500 * time.Millisecond},
+		{"токен пополнился", 500 * time.Millisecond, true, 0, 0},
+		{"пополнение не больше емкости", time.Hour, true, 1, 0},
+	}
+	for _, tt := range tests {
+		*now = now.Add(tt.wait)
+		res, err := m.Take(context.Background(), "ip:1", lim)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Allowed != tt.allowed || res.Remaining != tt.remaining || res.RetryAfter != tt.retryAfter {
+			t.Errorf("%s: получено %+v, ожидалось allowed=%v remaining=%d retry=%v",
+				tt.name, res, tt.allowed, tt.remaining, tt.retryAfter)
+		}
+	}
+}
+
+// Заполнившиеся корзины удаляются не раньше чем через sweepInterval
+func TestMemorySweep(t *testing.T) {
+	m, now := newMemory()
+	lim := Limit{Rate: 1, Burst: 1}
+	m.Take(context.Background(), "ip:1", lim)
+	*now = now.Add(sweepInterval)
+	m.Take(context.Background(), "ip:2", lim)
+	if _, ok := m.buckets["ip:1"]; ok {
+		t.Error("заполнившаяся корзина не удалена")
+	}
+	if _, ok := m.buckets["ip:2"]; !ok {
+		t.Error("удалена корзина, которая еще пополняется")
+	}
+}
+
+// Отказ - 429 с Retry-After; у каждого клиента и маршрута своя корзина
+func TestMux(t *testing.T) {
+	m, _ := newMemory()
+	l := &Limiter{Backend: m, Routes: map[string]Limit{"POST /users": PerMinute(1)}}
+	router := mux.NewRouter()
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }
+	router.HandleFunc("/users", ok).Methods(http.MethodPost, http.MethodGet)
+	router.Use(l.Mux)
+
+	tests := []struct {
+		name, method, path, ip string
+		user                   string //владелец запроса; пусто - анонимный
+		status                 int
+	}{
+		{"первый запрос", http.MethodPost, "/users", "10.0.0.1", "", http.StatusNoContent},
+		{"второй запрос", http.MethodPost, "/users", "10.0.0.1", "", http.StatusTooManyRequests},
+		{"другой IP", http.MethodPost, "/users", "10.0.0.2", "", http.StatusNoContent},
+		{"вошедший пользователь с того же IP", http.MethodPost, "/users", "10.0.0.1", "alice", http.StatusNoContent},
+		{"тот же пользователь с другого IP", http.MethodPost, "/users", "10.0.0.3", "alice", http.StatusTooManyRequests},
+		{"маршрут без лимита", http.MethodGet, "/users", "10.0.0.1", "", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		r.RemoteAddr = tt.ip + ":1234"
+		if tt.user != "" {
+			r = r.WithContext(auth.WithIdentity(r.Context(), auth.Identity{Subject: tt.user, Role: auth.RoleUser}))
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != tt.status {
+			t.Errorf("%s: код %d, ожидался %d", tt.name, w.Code, tt.status)
+			continue
+		}
+		h := w.Header()
+		switch tt.status {
+		case http.StatusTooManyRequests:
+			if h.Get("Retry-After") != "60" || h.Get("RateLimit-Remaining") != "0" || h.Get("RateLimit-Limit") != "1" {
+				t.Errorf("%s: заголовки %v", tt.name, h)
+			}
+		case http.StatusNoContent:
+			if tt.method == http.MethodPost && (h.Get("RateLimit-Remaining") != "0" || h.Get("Retry-After") != "") {
+				t.Errorf("%s: заголовки %v", tt.name, h)
+			}
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package retention
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"Network-exchange/auth"
+	"Network-exchange/core"
+)
+
+// Handler - GET /admin/deleted-users: помеченные удаленными с друзьями на момент удаления
+// и сроком окончательного удаления
+func (s *Service) Handler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.List(r.Context()))
+}
+
+// ServeRestore - POST /admin/deleted-users/<id>/restore
+func (s *Service) ServeRestore(w http.ResponseWriter, r *http.Request, id string) {
+	n, ok := parseID(w, r, id)
+	if !ok {
+		return
+	}
+	u, err := s.Restore(r.Context(), n)
+	switch {
+	case errors.Is(err, core.ErrNotFound):
+		auth.WriteError(w, r, http.StatusNotFound, "удаленный пользователь не найден")
+	case errors.Is(err, ErrExpired):
+		auth.WriteError(w, r, http.StatusGone, err.Error())
+	case errors.Is(err, core.ErrNameTaken), errors.Is(err, core.ErrEmailTaken):
+		auth.WriteError(w, r, http.StatusConflict, err.Error())
+	case err != nil:
+		auth.WriteError(w, r, http.StatusInternalServerError, err.Error())
+	default:
+		writeJSON(w, http.StatusOK, u)
+	}
+}
+
+// ServeErase - DELETE /admin/deleted-users/<id>: окончательное удаление до срока
+func (s *Service) ServeErase(w http.ResponseWriter, r *http.Request, id string) {
+	n, ok := parseID(w, r, id)
+	if !ok {
+		return
+	}
+	if err := s.Erase(r.Context(), n); err != nil {
+		auth.WriteError(w, r, http.StatusNotFound, "удаленный пользователь не найден")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func parseID(w http.ResponseWriter, r *http.Request, id string) (int, bool) {
+	n, err := strconv.Atoi(id)
+	if err != nil || n <= 0 {
+		auth.WriteError(w, r, http.StatusBadRequest, "ID пользователя - целое положительное число")
+		return 0, false
+	}
+	return n, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
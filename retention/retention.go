@@ -0,0 +1,116 @@
+// Package retention - срок хранения удаленных пользователей: пока он не истек, пользователя
+// можно восстановить вместе с дружбой, после - фоновая очистка удаляет его окончательно
+// вместе с учетной записью. На время хранения учетная запись удаленного заблокирована.
+package retention
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+
+	"Network-exchange/auth"
+	"Network-exchange/core"
+)
+
+// Настройки по умолчанию
+const (
+	DefaultWindow   = 30 * 24 * time.Hour //срок, в течение которого удаленного можно восстановить
+	DefaultInterval = time.Hour           //период очистки
+)
+
+// ErrExpired - срок восстановления истек (пользователь ждет очистки)
+var ErrExpired = errors.New("срок восстановления истек")
+
+// Item - помеченный удаленным пользователь со сроком окончательного удаления
+type Item struct {
+	core.Removal
+	PurgeAt time.Time `json:"purge_at"`
+}
+
+// Service - восстановление и очистка удаленных
+type Service struct {
+	Window   time.Duration
+	Interval time.Duration
+
+	store    *core.Store
+	accounts *auth.Service
+	subject  func(core.User) string //логин учетной записи пользователя
+}
+
+// New запускает очистку удаленных. Настройки: DELETE_RETENTION - срок восстановления
+// (по умолчанию 720h), PURGE_INTERVAL - период очистки (1h)
+func New(store *core.Store, accounts *auth.Service, subject func(core.User) string) *Service {
+	s := &Service{Window: DefaultWindow, Interval: DefaultInterval, store: store, accounts: accounts, subject: subject}
+	if v, err := time.ParseDuration(os.Getenv("DELETE_RETENTION")); err == nil && v > 0 {
+		s.Window = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("PURGE_INTERVAL")); err == nil && v > 0 {
+		s.Interval = v
+	}
+	go s.run()
+	return s
+}
+
+func (s *Service) run() {
+	t := time.NewTicker(s.Interval)
+	defer t.Stop()
+	for range t.C {
+		s.Purge(context.Background())
+	}
+}
+
+// Purge окончательно удаляет тех, у кого истек срок восстановления, и их учетные записи
+func (s *Service) Purge(ctx context.Context) []core.User {
+	purged := s.store.PurgeBefore(ctx, time.Now().Add(-s.Window))
+	for _, u := range purged {
+		s.accounts.Remove(s.subject(u))
+	}
+	if len(purged) > 0 {
+		slog.Info("удаленные пользователи стерты окончательно", "users", len(purged))
+	}
+	return purged
+}
+
+// List - помеченные удаленными по времени удаления
+func (s *Service) List(ctx context.Context) []Item {
+	removed := s.store.Removed(ctx)
+	out := make([]Item, len(removed))
+	for i, d := range removed {
+		out[i] = Item{Removal: d, PurgeAt: d.DeletedAt.Add(s.Window)}
+	}
+	return out
+}
+
+// Restore восстанавливает пользователя с дружбой и снимает блокировку учетной записи.
+// Ошибки: core.ErrNotFound, ErrExpired, core.ErrNameTaken и core.ErrEmailTaken (имя или email
+// заняли, пока он был удален).
+func (s *Service) Restore(ctx context.Context, id int) (core.User, error) {
+	d, err := s.store.RemovedUser(ctx, id)
+	if err != nil {
+		return core.User{}, err
+	}
+	if !time.Now().Before(d.DeletedAt.Add(s.Window)) {
+		return core.User{}, ErrExpired
+	}
+	u, err := s.store.Restore(ctx, id)
+	if err != nil {
+		return core.User{}, err
+	}
+	s.accounts.Reinstate(s.subject(u))
+	return u, nil
+}
+
+// Erase удаляет помеченного удаленным окончательно, не дожидаясь срока
+func (s *Service) Erase(ctx context.Context, id int) error {
+	if _, err := s.store.RemovedUser(ctx, id); err != nil {
+		return err
+	}
+	u, err := s.store.Purge(ctx, id)
+	if err != nil {
+		return err
+	}
+	s.accounts.Remove(s.subject(u))
+	return nil
+}
@@ -0,0 +1,27 @@
+//go:build gin
+
+package main
+
+import (
+	"testing"
+
+	"Network-exchange/auth"
+	"Network-exchange/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+// маршруты "Джин" и описание API совпадают: go test -tags gin .
+func TestGinRoutesMatchSpec(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authService = auth.NewService([]byte("test-secret"), "test")
+	router, spec, _ := newRouter(nil)
+	if err := spec.CheckRoutes(openapi.GinRoutes(router)); err != nil {
+		t.Fatalf("описание API расходится с маршрутами: %v", err)
+	}
+	//маршрут без описания проверка замечает
+	router.GET("/undocumented", func(*gin.Context) {})
+	if err := spec.CheckRoutes(openapi.GinRoutes(router)); err == nil {
+		t.Error("маршрут без описания не обнаружен")
+	}
+}
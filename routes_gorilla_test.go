@@ -0,0 +1,25 @@
+//go:build gorilla
+
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"Network-exchange/auth"
+	"Network-exchange/openapi"
+)
+
+// маршруты "Гориллы" и описание API совпадают: go test -tags gorilla .
+func TestGorillaRoutesMatchSpec(t *testing.T) {
+	authService = auth.NewService([]byte("test-secret"), "test")
+	router, spec, _ := newRouter(nil)
+	if err := spec.CheckRoutes(openapi.MuxRoutes(router)); err != nil {
+		t.Fatalf("описание API расходится с маршрутами: %v", err)
+	}
+	//маршрут без описания проверка замечает
+	router.HandleFunc("/undocumented", func(http.ResponseWriter, *http.Request) {}).Methods("GET")
+	if err := spec.CheckRoutes(openapi.MuxRoutes(router)); err == nil {
+		t.Error("маршрут без описания не обнаружен")
+	}
+}
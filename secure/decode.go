@@ -0,0 +1,54 @@
+package secure
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"Network-exchange/logging"
+)
+
+// ErrBodyTooLarge - тело запроса больше допустимого
+var ErrBodyTooLarge = errors.New("тело запроса слишком большое")
+
+// DecodeJSON читает JSON из тела запроса: неизвестные поля и данные после
+// JSON-значения - ошибка, превышение размера тела - ErrBodyTooLarge
+func DecodeJSON(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return bodyError(err)
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		if err == nil {
+			return errors.New("лишние данные после JSON")
+		}
+		return bodyError(err)
+	}
+	return nil
+}
+
+// bodyError заменяет ошибку превышения размера на ErrBodyTooLarge
+func bodyError(err error) error {
+	if TooLarge(err) {
+		return ErrBodyTooLarge
+	}
+	return err
+}
+
+// TooLarge - вызвана ли ошибка чтения превышением размера тела
+func TooLarge(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr) || errors.Is(err, ErrBodyTooLarge)
+}
+
+// WriteTooLarge - ответ 413 в формате JSON с ID запроса
+func WriteTooLarge(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":      ErrBodyTooLarge.Error(),
+		"request_id": logging.RequestID(r.Context()),
+	})
+}
@@ -0,0 +1,20 @@
+package secure
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Gin - промежуточный обработчик "Джин": CORS, заголовки безопасности и размер тела.
+// Ставится первым, до проверки токена: предварительные запросы CORS идут без него.
+// Заодно запрещает неизвестные поля JSON в ShouldBindJSON.
+func (o Options) Gin() gin.HandlerFunc {
+	binding.EnableDecoderDisallowUnknownFields = true
+	return func(c *gin.Context) {
+		if !o.apply(c.Writer, c.Request) {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,15 @@
+package secure
+
+import "net/http"
+
+// Handler оборачивает весь маршрутизатор "Гориллы": CORS, заголовки безопасности
+// и размер тела. Предварительные запросы OPTIONS не доходят до маршрутов
+// (для них "Горилла" ответила бы 405).
+func (o Options) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !o.apply(w, r) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,151 @@
+// Package secure — защита HTTP-сервисов для вызова из браузера: CORS,
+// стандартные заголовки безопасности и ограничение размера тела запроса.
+package secure
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxBodyBytes - размер тела запроса по умолчанию (64 КБ)
+const DefaultMaxBodyBytes = 64 << 10
+
+// Options - настройки защиты
+type Options struct {
+	AllowedOrigins   []string      //разрешенные источники; "*" - любой (без учетных данных)
+	AllowedMethods   []string      //методы для предварительных запросов
+	AllowedHeaders   []string      //заголовки запроса, доступные из браузера
+	ExposedHeaders   []string      //заголовки ответа, доступные скриптам
+	AllowCredentials bool          //разрешить cookie и Authorization
+	MaxAge           time.Duration //кеширование ответа на предварительный запрос
+	MaxBodyBytes     int64         //ограничение размера тела запроса (0 - без ограничения)
+}
+
+// DefaultOptions - настройки по умолчанию: CORS выключен, тело до 64 КБ
+func DefaultOptions() Options {
+	return Options{
+		AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete},
+		AllowedHeaders: []string{"Authorization", "Content-Type", "X-API-Key", "X-Request-ID"},
+		ExposedHeaders: []string{"X-Request-ID", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After",
+			"Location", "Deprecation", "Sunset", "Link"},
+		MaxAge:       10 * time.Minute,
+		MaxBodyBytes: DefaultMaxBodyBytes,
+	}
+}
+
+// FromEnv - настройки по умолчанию, измененные переменными окружения:
+// CORS_ALLOWED_ORIGINS (через запятую), CORS_ALLOW_CREDENTIALS (true/false),
+// CORS_MAX_AGE (секунды), MAX_BODY_BYTES.
+func FromEnv() Options {
+	o := DefaultOptions()
+	if v := os.Getenv("CORS_ALLOWED_ORIGINS"); v != "" {
+		for _, origin := range strings.Split(v, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				o.AllowedOrigins = append(o.AllowedOrigins, origin)
+			}
+		}
+	}
+	if v, err := strconv.ParseBool(os.Getenv("CORS_ALLOW_CREDENTIALS")); err == nil {
+		o.AllowCredentials = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("CORS_MAX_AGE")); err == nil && v >= 0 {
+		o.MaxAge = time.Duration(v) * time.Second
+	}
+	if v, err := strconv.ParseInt(os.Getenv("MAX_BODY_BYTES"), 10, 64); err == nil && v >= 0 {
+		o.MaxBodyBytes = v
+	}
+	return o
+}
+
+// apply выставляет заголовки, ограничивает тело и отвечает на предварительный запрос CORS.
+// false - ответ уже отправлен и обработку нужно прекратить.
+func (o *Options) apply(w http.ResponseWriter, r *http.Request) bool {
+	setSecurityHeaders(w.Header())
+	if r.TLS != nil { //браузер будет обращаться только по HTTPS
+		w.Header().Set("Strict-Transport-Security", "max-age=31536000")
+	}
+	if o.MaxBodyBytes > 0 && r.Body != nil {
+		r.Body = http.MaxBytesReader(w, r.Body, o.MaxBodyBytes)
+	}
+	return o.cors(w, r)
+}
+
+// setSecurityHeaders - стандартные заголовки безопасности для JSON API
+func setSecurityHeaders(h http.Header) {
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("X-Frame-Options", "DENY")
+	h.Set("Referrer-Policy", "no-referrer")
+	h.Set("Content-Security-Policy", "default-src 'none'; frame-ancestors 'none'")
+	h.Set("Cross-Origin-Opener-Policy", "same-origin")
+	h.Set("Permissions-Policy", "camera=(), microphone=(), geolocation=()")
+}
+
+// cors обрабатывает заголовки CORS; на предварительный запрос отвечает сам (false)
+func (o *Options) cors(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	if origin == "" {
+		return true
+	}
+	allowed, wildcard := o.originAllowed(origin)
+	if !allowed {
+		if preflight { //без заголовков CORS браузер сам отклонит запрос
+			w.WriteHeader(http.StatusNoContent)
+			return false
+		}
+		return true
+	}
+
+	if wildcard && !o.AllowCredentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if o.AllowCredentials && !wildcard { //учетные данные - только для явно перечисленных источников
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if !preflight {
+		if len(o.ExposedHeaders) > 0 {
+			h.Set("Access-Control-Expose-Headers", strings.Join(o.ExposedHeaders, ", "))
+		}
+		return true
+	}
+
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+	if contains(o.AllowedMethods, r.Header.Get("Access-Control-Request-Method")) {
+		h.Set("Access-Control-Allow-Methods", strings.Join(o.AllowedMethods, ", "))
+		h.Set("Access-Control-Allow-Headers", strings.Join(o.AllowedHeaders, ", "))
+		if o.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", strconv.Itoa(int(o.MaxAge.Seconds())))
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return false
+}
+
+// originAllowed - разрешен ли источник и разрешен ли он через "*"
+func (o *Options) originAllowed(origin string) (allowed, wildcard bool) {
+	for _, a := range o.AllowedOrigins {
+		if a == "*" {
+			wildcard = true
+		} else if strings.EqualFold(a, origin) {
+			return true, false
+		}
+	}
+	return wildcard, wildcard
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package secure
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type user struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+// Тело разбирается за обработчиком с ограничением в 32 байта
+func TestDecodeJSON(t *testing.T) {
+	o := DefaultOptions()
+	o.MaxBodyBytes = 32
+	h := o.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var u user
+		if err := DecodeJSON(r, &u); err != nil {
+			if TooLarge(err) {
+				WriteTooLarge(w, r)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	tests := []struct {
+		name, body string
+		status     int
+	}{
+		{"корректный", `{"name":"Barby","age":30}`, http.StatusNoContent},
+		{"неизвестное поле", `{"name":"Barby","role":"admin"}`, http.StatusBadRequest},
+		{"данные после JSON", `{"name":"Barby"}{"age":1}`, http.StatusBadRequest},
+		{"не JSON", `name=Barby`, http.StatusBadRequest},
+		{"больше ограничения", `{"name":"` + strings.Repeat("x", 64) + `"}`, http.StatusRequestEntityTooLarge},
+		{"лишнее после JSON больше ограничения", `{"age":1}` + strings.Repeat(" ", 64), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(tt.body)))
+			if w.Code != tt.status {
+				t.Fatalf("код %d, ожидался %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status == http.StatusRequestEntityTooLarge {
+				var body map[string]string
+				if err := json.NewDecoder(w.Body).Decode(&body); err != nil || body["error"] != ErrBodyTooLarge.Error() {
+					t.Errorf("тело ответа 413 %v (%v)", body, err)
+				}
+			}
+		})
+	}
+}
+
+// "Джин": ShouldBindJSON отклоняет неизвестные поля, большое тело распознается TooLarge
+func TestGin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	o := DefaultOptions()
+	o.MaxBodyBytes = 32
+	router := gin.New()
+	router.Use(o.Gin())
+	router.POST("/users", func(c *gin.Context) {
+		var u user
+		if err := c.ShouldBindJSON(&u); err != nil {
+			if TooLarge(err) {
+				WriteTooLarge(c.Writer, c.Request)
+				return
+			}
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	tests := []struct {
+		name, body string
+		status     int
+	}{
+		{"корректный", `{"name":"Barby","age":30}`, http.StatusNoContent},
+		{"неизвестное поле", `{"name":"Barby","role":"admin"}`, http.StatusBadRequest},
+		{"больше ограничения", `{"name":"` + strings.Repeat("x", 64) + `"}`, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(tt.body)))
+		if w.Code != tt.status {
+			t.Errorf("%s: код %d, ожидался %d", tt.name, w.Code, tt.status)
+		}
+		if w.Header().Get("X-Content-Type-Options") != "nosniff" {
+			t.Errorf("%s: нет заголовков безопасности", tt.name)
+		}
+	}
+}
+
+func TestTooLarge(t *testing.T) {
+	if !TooLarge(&http.MaxBytesError{Limit: 1}) || !TooLarge(ErrBodyTooLarge) {
+		t.Error("превышение размера не распознано")
+	}
+	if TooLarge(errors.New("EOF")) {
+		t.Error("другая ошибка принята за превышение размера")
+	}
+}
+
+func TestCORS(t *testing.T) {
+	tests := []struct {
+		name        string
+		origins     []string
+		credentials bool
+		method      string
+		header      map[string]string
+		status      int
+		want        map[string]string //ожидаемые заголовки ответа; "" - заголовка нет
+	}{
+		{"без Origin", []string{"https://app.example.com"}, false, http.MethodGet, nil, http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": ""}},
+		{"разрешенный источник", []string{"https://app.example.com"}, true, http.MethodGet,
+			map[string]string{"Origin": "https://app.example.com"}, http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": "https://app.example.com", "Access-Control-Allow-Credentials": "true"}},
+		{"чужой источник", []string{"https://app.example.com"}, false, http.MethodGet,
+			map[string]string{"Origin": "https://evil.example.com"}, http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": ""}},
+		{"любой источник", []string{"*"}, false, http.MethodGet,
+			map[string]string{"Origin": "https://evil.example.com"}, http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": "*"}},
+		{"любой источник - без учетных данных", []string{"*"}, true, http.MethodGet,
+			map[string]string{"Origin": "https://evil.example.com"}, http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": "https://evil.example.com", "Access-Control-Allow-Credentials": ""}},
+		{"предварительный запрос", []string{"https://app.example.com"}, false, http.MethodOptions,
+			map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "DELETE"}, http.StatusNoContent,
+			map[string]string{"Access-Control-Allow-Origin": "https://app.example.com", "Access-Control-Max-Age": "600"}},
+		{"предварительный запрос, метод не разрешен", []string{"https://app.example.com"}, false, http.MethodOptions,
+			map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "TRACE"}, http.StatusNoContent,
+			map[string]string{"Access-Control-Allow-Methods": ""}},
+		{"предварительный запрос с чужого источника", []string{"https://app.example.com"}, false, http.MethodOptions,
+			map[string]string{"Origin": "https://evil.example.com", "Access-Control-Request-Method": "GET"}, http.StatusNoContent,
+			map[string]string{"Access-Control-Allow-Origin": "", "Access-Control-Allow-Methods": ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := DefaultOptions()
+			o.AllowedOrigins, o.AllowCredentials = tt.origins, tt.credentials
+			h := o.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			r := httptest.NewRequest(tt.method, "/users", nil)
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.status {
+				t.Errorf("код %d, ожидался %d", w.Code, tt.status)
+			}
+			for k, v := range tt.want {
+				if got := w.Header().Get(k); got != v {
+					t.Errorf("%s: %q, ожидалось %q", k, got, v)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+// Package tlsconf — запуск сервиса по HTTP или HTTPS: сертификат и ключ из
+// настроек, перечитывание файлов без перезапуска и проверка сертификатов клиентов (mTLS).
+package tlsconf
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultReloadInterval - как часто проверять изменение файлов сертификатов
+const DefaultReloadInterval = 10 * time.Second
+
+// Config - настройки TLS
+type Config struct {
+	CertFile       string             //сертификат сервера (PEM)
+	KeyFile        string             //ключ сервера (PEM)
+	ClientCAFile   string             //корневые сертификаты клиентов (PEM); пусто - mTLS выключен
+	ClientAuth     tls.ClientAuthType //требовать ли сертификат клиента
+	ReloadInterval time.Duration
+}
+
+// FromEnv читает настройки: TLS_CERT_FILE, TLS_KEY_FILE, TLS_CLIENT_CA_FILE,
+// TLS_CLIENT_AUTH ("require" - по умолчанию при заданном CA, "optional").
+// false - TLS не настроен, сервис работает по HTTP.
+func FromEnv() (Config, bool) {
+	c := Config{
+		CertFile:       os.Getenv("TLS_CERT_FILE"),
+		KeyFile:        os.Getenv("TLS_KEY_FILE"),
+		ClientCAFile:   os.Getenv("TLS_CLIENT_CA_FILE"),
+		ReloadInterval: DefaultReloadInterval,
+	}
+	if c.ClientCAFile != "" {
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+		if strings.EqualFold(os.Getenv("TLS_CLIENT_AUTH"), "optional") {
+			c.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+	return c, c.CertFile != "" && c.KeyFile != ""
+}
+
+// ListenAndServe запускает сервер: по HTTPS, если заданы сертификат и ключ, иначе по HTTP
+func ListenAndServe(addr string, handler http.Handler) error {
+	srv := &http.Server{Addr: addr, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	//HTTP/2 и без TLS (h2c): на нем приходят вызовы gRPC
+	srv.Protocols = new(http.Protocols)
+	srv.Protocols.SetHTTP1(true)
+	srv.Protocols.SetHTTP2(true)
+	srv.Protocols.SetUnencryptedHTTP2(true)
+	cfg, ok := FromEnv()
+	if !ok {
+		return srv.ListenAndServe()
+	}
+	tlsConfig, err := cfg.TLSConfig(context.Background())
+	if err != nil {
+		return err
+	}
+	srv.TLSConfig = tlsConfig
+	slog.Info("HTTPS включен", "cert", cfg.CertFile, "mtls", cfg.ClientCAFile != "")
+	return srv.ListenAndServeTLS("", "") //сертификат берется из TLSConfig
+}
+
+// TLSConfig загружает файлы и возвращает настройки сервера, которые подхватывают
+// измененные файлы до отмены ctx
+func (c Config) TLSConfig(ctx context.Context) (*tls.Config, error) {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, errors.New("tlsconf: не заданы сертификат и ключ")
+	}
+	r := &reloader{config: c}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	interval := c.ReloadInterval
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+	go r.watch(ctx, interval)
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		//на каждое соединение - действующие сертификат и CA клиентов
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.current(), nil
+		},
+	}, nil
+}
+
+// reloader хранит загруженные файлы и перечитывает их при изменении
+type reloader struct {
+	config Config
+
+	mu       sync.RWMutex
+	tls      *tls.Config
+	modTimes []time.Time
+}
+
+func (r *reloader) files() []string {
+	files := []string{r.config.CertFile, r.config.KeyFile}
+	if r.config.ClientCAFile != "" {
+		files = append(files, r.config.ClientCAFile)
+	}
+	return files
+}
+
+// load читает сертификат, ключ и CA клиентов
+func (r *reloader) load() error {
+	modTimes, err := modTimes(r.files())
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	if err != nil {
+		return err
+	}
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	if r.config.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.config.ClientCAFile)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("tlsconf: в " + r.config.ClientCAFile + " нет сертификатов")
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = r.config.ClientAuth
+	}
+
+	r.mu.Lock()
+	r.tls, r.modTimes = cfg, modTimes
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *reloader) current() *tls.Config {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.tls
+}
+
+// watch перечитывает файлы, если изменилось время их изменения; при ошибке
+// остаются прежние сертификаты
+func (r *reloader) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		times, err := modTimes(r.files())
+		if err != nil || !r.changed(times) {
+			continue
+		}
+		if err := r.load(); err != nil {
+			slog.Error("сертификаты не перечитаны", "error", err)
+			continue
+		}
+		slog.Info("сертификаты перечитаны", "cert", r.config.CertFile)
+	}
+}
+
+func (r *reloader) changed(times []time.Time) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for i := range times {
+		if !times[i].Equal(r.modTimes[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func modTimes(files []string) ([]time.Time, error) {
+	out := make([]time.Time, len(files))
+	for i, f := range files {
+		st, err := os.Stat(f)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = st.ModTime()
+	}
+	return out, nil
+}
@@ -0,0 +1,305 @@
+package tlsconf_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"Network-exchange/auth"
+	"Network-exchange/tlsconf"
+)
+
+// authority - самоподписанный удостоверяющий центр, созданный на время теста
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newAuthority(t *testing.T, cn string) *authority {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &authority{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue выпускает сертификат сервера (localhost) или клиента с CN и возвращает его и ключ в PEM
+func (a *authority) issue(t *testing.T, cn string, client bool) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	if client {
+		tmpl.ExtKeyUsage, tmpl.DNSNames, tmpl.IPAddresses = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, nil, nil
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, a.cert, &key.PublicKey, a.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// write записывает файл и сдвигает время его изменения на shift вперед (чтобы перечитывание
+// заметило изменение и на файловых системах с грубым временем)
+func write(t *testing.T, path string, data []byte, shift time.Duration) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	at := time.Now().Add(shift)
+	if err := os.Chtimes(path, at, at); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// servedCN - CN сертификата, который получит следующее соединение
+func servedCN(t *testing.T, cfg *tls.Config) string {
+	t.Helper()
+	c, err := cfg.GetConfigForClient(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(c.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newAuthority(t, "test-ca")
+	cfg := tlsconf.Config{
+		CertFile:       filepath.Join(dir, "cert.pem"),
+		KeyFile:        filepath.Join(dir, "key.pem"),
+		ReloadInterval: 10 * time.Millisecond,
+	}
+	cert, key := ca.issue(t, "server-1", false)
+	write(t, cfg.CertFile, cert, 0)
+	write(t, cfg.KeyFile, key, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tlsConfig, err := cfg.TLSConfig(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cn := servedCN(t, tlsConfig); cn != "server-1" {
+		t.Fatalf("сертификат %q, ожидался server-1", cn)
+	}
+
+	//новая пара подхватывается без перезапуска
+	cert, key = ca.issue(t, "server-2", false)
+	write(t, cfg.CertFile, cert, time.Hour)
+	write(t, cfg.KeyFile, key, time.Hour)
+	deadline := time.Now().Add(2 * time.Second)
+	for servedCN(t, tlsConfig) != "server-2" {
+		if time.Now().After(deadline) {
+			t.Fatal("новый сертификат не подхвачен")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	//испорченный файл не заменяет действующий сертификат
+	write(t, cfg.CertFile, []byte("не сертификат"), 2*time.Hour)
+	time.Sleep(100 * time.Millisecond)
+	if cn := servedCN(t, tlsConfig); cn != "server-2" {
+		t.Fatalf("после ошибки чтения сертификат %q, ожидался прежний server-2", cn)
+	}
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := (tlsconf.Config{}).TLSConfig(context.Background()); err == nil {
+		t.Error("без сертификата и ключа ожидалась ошибка")
+	}
+	missing := tlsconf.Config{CertFile: filepath.Join(dir, "nope.pem"), KeyFile: filepath.Join(dir, "nope.key")}
+	if _, err := missing.TLSConfig(context.Background()); err == nil {
+		t.Error("для несуществующих файлов ожидалась ошибка")
+	}
+	ca := newAuthority(t, "test-ca")
+	cert, key := ca.issue(t, "server", false)
+	cfg := tlsconf.Config{
+		CertFile:     filepath.Join(dir, "cert.pem"),
+		KeyFile:      filepath.Join(dir, "key.pem"),
+		ClientCAFile: filepath.Join(dir, "ca.pem"),
+	}
+	write(t, cfg.CertFile, cert, 0)
+	write(t, cfg.KeyFile, key, 0)
+	write(t, cfg.ClientCAFile, []byte("пусто"), 0)
+	if _, err := cfg.TLSConfig(context.Background()); err == nil {
+		t.Error("для файла CA без сертификатов ожидалась ошибка")
+	}
+}
+
+// identity - ответ тестового обработчика: кем сервис счел владельца сертификата
+type identity struct {
+	Subject string `json:"subject"`
+	Role    string `json:"role"`
+	OK      bool   `json:"ok"`
+	Error   string `json:"error"`
+}
+
+func TestClientCertificateIdentity(t *testing.T) {
+	dir := t.TempDir()
+	ca := newAuthority(t, "clients-ca")
+	cfg := tlsconf.Config{
+		CertFile:     filepath.Join(dir, "cert.pem"),
+		KeyFile:      filepath.Join(dir, "key.pem"),
+		ClientCAFile: filepath.Join(dir, "ca.pem"),
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+	}
+	cert, key := ca.issue(t, "server", false)
+	write(t, cfg.CertFile, cert, 0)
+	write(t, cfg.KeyFile, key, 0)
+	write(t, cfg.ClientCAFile, ca.pem, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tlsConfig, err := cfg.TLSConfig(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService([]byte("test-secret"), "test")
+	if err := svc.SetCertificateRole("batch-job", auth.RoleAuditor); err != nil {
+		t.Fatal(err)
+	}
+	for login, role := range map[string]auth.Role{"ops": auth.RoleAdmin, "mallory": auth.RoleUser} {
+		if err := svc.Register(login, "password1", role); err != nil {
+			t.Fatal(err)
+		}
+	}
+	svc.Suspend("mallory")
+
+	//отказ svc.Mux - JSON с полем error
+	srv := httptest.NewUnstartedServer(svc.Mux(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := auth.FromContext(r.Context())
+		json.NewEncoder(w).Encode(identity{Subject: id.Subject, Role: string(id.Role), OK: ok})
+	})))
+	srv.TLS = tlsConfig
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	//сертификат отправляется всегда, даже если сервер ждет другой CA
+	get := func(t *testing.T, certs ...tls.Certificate) (identity, error) {
+		t.Helper()
+		send := func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			if len(certs) == 0 {
+				return &tls.Certificate{}, nil
+			}
+			return &certs[0], nil
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, GetClientCertificate: send}}}
+		defer client.CloseIdleConnections()
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			return identity{}, err
+		}
+		defer resp.Body.Close()
+		var out identity
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+		return out, nil
+	}
+	clientCert := func(t *testing.T, a *authority, cn string) tls.Certificate {
+		t.Helper()
+		c, k := a.issue(t, cn, true)
+		pair, err := tls.X509KeyPair(c, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pair
+	}
+
+	tests := []struct {
+		cn   string
+		want identity
+	}{
+		{"batch-job", identity{Subject: "batch-job", Role: "auditor", OK: true}}, //роль из SetCertificateRole
+		{"ops", identity{Subject: "ops", Role: "admin", OK: true}},               //роль из учетной записи
+		{"stranger", identity{Subject: "stranger", Role: "user", OK: true}},      //обычный пользователь
+		{"mallory", identity{Error: auth.ErrSuspended.Error()}},                  //учетная запись заблокирована
+	}
+	for _, tt := range tests {
+		t.Run(tt.cn, func(t *testing.T) {
+			got, err := get(t, clientCert(t, ca, tt.cn))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("получено %+v, ожидалось %+v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("без сертификата", func(t *testing.T) {
+		got, err := get(t)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != (identity{}) {
+			t.Errorf("получено %+v, ожидался анонимный запрос", got)
+		}
+	})
+	t.Run("чужой CA", func(t *testing.T) {
+		if _, err := get(t, clientCert(t, newAuthority(t, "other-ca"), "ops")); err == nil {
+			t.Error("сертификат чужого CA принят")
+		}
+	})
+}
@@ -0,0 +1,104 @@
+// Package tracing — трассировка OpenTelemetry: настройка экспорта (OTLP или stdout),
+// спаны входящих запросов для "Джин" и "Гориллы" и дочерние спаны операций хранилища.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// имя инструментирующей библиотеки
+const instrumentation = "Network-exchange/tracing"
+
+// Переменные окружения:
+//
+//	OTEL_TRACES_EXPORTER        - "otlp", "stdout" (или "console"), "none" (по умолчанию)
+//	OTEL_EXPORTER_OTLP_ENDPOINT - адрес коллектора OTLP/HTTP, например http://localhost:4318
+//	OTEL_SERVICE_NAME           - имя сервиса (иначе берется переданное в Setup)
+const (
+	envExporter    = "OTEL_TRACES_EXPORTER"
+	envServiceName = "OTEL_SERVICE_NAME"
+)
+
+// Setup настраивает глобальный TracerProvider и распространение W3C traceparent/baggage.
+// Возвращает функцию для сброса и остановки экспорта при завершении сервиса.
+func Setup(ctx context.Context, service string) (func(context.Context) error, error) {
+	//W3C Trace Context распространяем всегда, даже без экспорта
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch strings.ToLower(os.Getenv(envExporter)) {
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx) //адрес берется из OTEL_EXPORTER_OTLP_ENDPOINT
+	case "stdout", "console":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	default:
+		return nil, errors.New("tracing: неизвестный экспортер " + os.Getenv(envExporter))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if name := os.Getenv(envServiceName); name != "" {
+		service = name
+	}
+	res := resource.NewSchemaless(semconv.ServiceName(service))
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// Store открывает дочерний спан операции хранилища ("find", "create", "befriend", "delete").
+// Спан нужно закрыть вызовом End.
+func Store(ctx context.Context, op string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, "store."+op,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(append(attrs, attribute.String("store.operation", op))...),
+	)
+}
+
+// Fail отмечает спан как завершившийся ошибкой
+func Fail(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// spanName - имя спана входящего запроса: "GET /users/{userId}"
+func spanName(method, route string) string {
+	if route == "" {
+		return method
+	}
+	return method + " " + route
+}
+
+// finish дописывает в спан итоговый код ответа
+func finish(span trace.Span, status int) {
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	if status >= 500 {
+		span.SetStatus(codes.Error, "")
+	}
+}
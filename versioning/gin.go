@@ -0,0 +1,11 @@
+package versioning
+
+import "github.com/gin-gonic/gin"
+
+// Gin - промежуточный обработчик "Джин" для группы маршрутов устаревшей версии
+func (p Policy) Gin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p.Apply(c.Writer.Header())
+		c.Next()
+	}
+}
@@ -0,0 +1,11 @@
+package versioning
+
+import "net/http"
+
+// Mux - промежуточный обработчик для подмаршрутизатора устаревшей версии: v1.Use(policy.Mux)
+func (p Policy) Mux(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.Apply(w.Header())
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,50 @@
+// Package versioning - сведения об устаревании версии API в заголовках ответа:
+// Deprecation (RFC 9745), Sunset (RFC 8594) и ссылка на версию-преемницу.
+package versioning
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Значения по умолчанию для /v1
+var (
+	DefaultDeprecated = time.Date(2026, time.November, 1, 0, 0, 0, 0, time.UTC)
+	DefaultSunset     = time.Date(2027, time.June, 30, 0, 0, 0, 0, time.UTC)
+)
+
+// Policy - устаревание версии API
+type Policy struct {
+	Deprecated time.Time //с какого момента версия устарела
+	Sunset     time.Time //после какого момента версия может перестать отвечать (нулевое - не задано)
+	Successor  string    //адрес версии-преемницы ("/v2/users")
+	Docs       string    //описание перехода ("/docs")
+}
+
+// FromEnv - политика для /v1: даты из API_V1_DEPRECATED и API_V1_SUNSET (ГГГГ-ММ-ДД)
+func FromEnv(successor, docs string) Policy {
+	p := Policy{Deprecated: DefaultDeprecated, Sunset: DefaultSunset, Successor: successor, Docs: docs}
+	if t, err := time.Parse(time.DateOnly, os.Getenv("API_V1_DEPRECATED")); err == nil {
+		p.Deprecated = t
+	}
+	if t, err := time.Parse(time.DateOnly, os.Getenv("API_V1_SUNSET")); err == nil {
+		p.Sunset = t
+	}
+	return p
+}
+
+// Apply добавляет заголовки устаревания к ответу
+func (p Policy) Apply(h http.Header) {
+	h.Set("Deprecation", fmt.Sprintf("@%d", p.Deprecated.Unix()))
+	if !p.Sunset.IsZero() {
+		h.Set("Sunset", p.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if p.Successor != "" {
+		h.Add("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", p.Successor))
+	}
+	if p.Docs != "" {
+		h.Add("Link", fmt.Sprintf("<%s>; rel=\"deprecation\"", p.Docs))
+	}
+}
@@ -0,0 +1,93 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"Network-exchange/auth"
+	"Network-exchange/secure"
+)
+
+// SubscriptionsHandler - управление подписками:
+// GET /admin/webhooks - список,
+// POST /admin/webhooks {"url":"https://...","events":["user.created"],"secret":"..."} - создание
+// (без secret он создается; секрет показывается один раз)
+func (s *Service) SubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, s.List())
+		return
+	}
+	var req struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+		Secret string   `json:"secret"`
+	}
+	if err := secure.DecodeJSON(r, &req); err != nil {
+		if secure.TooLarge(err) {
+			secure.WriteTooLarge(w, r)
+		} else {
+			auth.WriteError(w, r, http.StatusBadRequest, "неправильный, некорректный запрос")
+		}
+		return
+	}
+	owner, _ := auth.FromContext(r.Context())
+	sub, secret, err := s.Create(req.URL, req.Events, req.Secret, owner.Subject)
+	if err != nil {
+		auth.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusCreated, struct {
+		Subscription
+		Secret string `json:"secret"`
+	}{sub, secret})
+}
+
+// ServeSubscription - GET и DELETE /admin/webhooks/<id>
+func (s *Service) ServeSubscription(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method == http.MethodDelete {
+		if err := s.Delete(id); err != nil {
+			auth.WriteError(w, r, http.StatusNotFound, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	sub, err := s.Get(id)
+	if err != nil {
+		auth.WriteError(w, r, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, sub)
+}
+
+// ServeDeliveries - GET /admin/webhooks/<id>/deliveries: последние доставки с попытками
+func (s *Service) ServeDeliveries(w http.ResponseWriter, r *http.Request, id string) {
+	list, err := s.Deliveries(id)
+	if err != nil {
+		auth.WriteError(w, r, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// DeadHandler - GET /admin/webhooks/dead: недоставленные после всех попыток
+func (s *Service) DeadHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Dead())
+}
+
+// ServeRedeliver - POST /admin/webhooks/dead/<id>/redeliver: отправить заново
+func (s *Service) ServeRedeliver(w http.ResponseWriter, r *http.Request, id string) {
+	d, err := s.Redeliver(id)
+	if err != nil {
+		auth.WriteError(w, r, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, d)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,510 @@
+// Package webhook - исходящие уведомления внешних систем (вебхуки): о создании и удалении
+// пользователей. Подписки (адрес, виды событий, секрет) управляются через /admin/webhooks;
+// каждая доставка подписывается HMAC-SHA256 и повторяется с растущей паузой, а исчерпавшая
+// попытки попадает в список недоставленных, откуда ее можно отправить заново.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"Network-exchange/core"
+)
+
+// Виды событий
+const (
+	UserCreated = "user.created"
+	UserDeleted = "user.deleted"
+)
+
+// Types - все виды событий, на которые можно подписаться
+var Types = []string{UserCreated, UserDeleted}
+
+// Состояния доставки
+const (
+	StatePending   = "pending"   //ждет очередной попытки
+	StateDelivered = "delivered" //получатель ответил 2xx
+	StateDead      = "dead"      //попытки исчерпаны: в списке недоставленных
+	StateCanceled  = "canceled"  //подписка удалена до доставки
+)
+
+// Настройки по умолчанию
+const (
+	DefaultAttempts = 8                //попыток до списка недоставленных
+	DefaultBackoff  = 2 * time.Second  //пауза перед второй попыткой, дальше - вдвое больше
+	DefaultTimeout  = 10 * time.Second //ожидание ответа получателя
+	DefaultWorkers  = 4                //одновременных запросов к получателям
+	maxBackoff      = time.Hour
+	maxPending      = 10000 //ожидающих попытки; сверх этого доставка сразу недоставлена
+	catchUpPage     = 1000  //изменений журнала за раз при наверстывании
+	historySize     = 100   //последних доставок в истории подписки
+	deadSize        = 1000  //недоставленных в памяти; старые вытесняются
+	minSecret       = 16
+)
+
+// Ошибки управления подписками
+var (
+	ErrNotFound = errors.New("вебхук не найден")
+	ErrInvalid  = errors.New("нужен адрес http(s), виды событий из " + UserCreated + ", " + UserDeleted +
+		" и секрет не короче " + strconv.Itoa(minSecret) + " символов (или без секрета - он будет создан)")
+	ErrNotDead = errors.New("доставка не найдена среди недоставленных")
+)
+
+// Subscription - подписка внешней системы (секрет виден только при создании)
+type Subscription struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Owner     string    `json:"owner"` //кто создал подписку
+	CreatedAt time.Time `json:"created_at"`
+	secret    string
+}
+
+// Attempt - одна попытка доставки
+type Attempt struct {
+	At       time.Time `json:"at"`
+	Status   int       `json:"status,omitempty"` //код ответа получателя; 0 - ответа нет
+	Error    string    `json:"error,omitempty"`
+	Duration float64   `json:"duration_ms"`
+}
+
+// Delivery - доставка одного события одной подписке; ID не меняется между попытками,
+// по нему (заголовок X-Webhook-ID) получатель отбрасывает повторы
+type Delivery struct {
+	ID             string     `json:"id"`
+	SubscriptionID string     `json:"subscription_id"`
+	Event          string     `json:"event"`
+	EventID        uint64     `json:"event_id"`
+	State          string     `json:"state"`
+	CreatedAt      time.Time  `json:"created_at"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	Attempts       []Attempt  `json:"attempts"`
+	tries          int        //попыток в текущем круге (повторная отправка начинает новый)
+	user           int        //ID пользователя события
+	body           []byte     //nil - пользователь стерт (Purge), отправлять нечего
+}
+
+// Payload - тело запроса к получателю
+type Payload struct {
+	ID   uint64    `json:"id"` //номер изменения хранилища
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	User core.User `json:"user"` //без email и даты рождения (core.User.Public)
+}
+
+// Service - подписки и их доставки
+type Service struct {
+	Attempts int
+	Backoff  time.Duration
+	Client   *http.Client
+
+	store *core.Store
+	mu    sync.Mutex
+	subs  map[string]*Subscription
+	//history - доставки подписки, последняя - в конце
+	history map[string][]*Delivery
+	dead    []*Delivery
+	//waiting - доставки, ждущие NextAttemptAt; dispatch передает наступившие в queue
+	waiting []*Delivery
+	wake    chan struct{}
+	queue   chan *Delivery
+}
+
+// New запускает отправку вебхуков. Настройки: WEBHOOK_ATTEMPTS (по умолчанию 8),
+// WEBHOOK_BACKOFF - первая пауза между попытками (2s), WEBHOOK_TIMEOUT (10s),
+// WEBHOOK_WORKERS - одновременных запросов (4). Отправляются изменения после запуска.
+func New(store *core.Store) *Service {
+	s := &Service{Attempts: DefaultAttempts, Backoff: DefaultBackoff, Client: &http.Client{Timeout: DefaultTimeout},
+		store: store, subs: make(map[string]*Subscription), history: make(map[string][]*Delivery),
+		wake: make(chan struct{}, 1)}
+	if v, err := strconv.Atoi(os.Getenv("WEBHOOK_ATTEMPTS")); err == nil && v > 0 {
+		s.Attempts = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("WEBHOOK_BACKOFF")); err == nil && v > 0 {
+		s.Backoff = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("WEBHOOK_TIMEOUT")); err == nil && v > 0 {
+		s.Client.Timeout = v
+	}
+	workers := DefaultWorkers
+	if v, err := strconv.Atoi(os.Getenv("WEBHOOK_WORKERS")); err == nil && v > 0 {
+		workers = v
+	}
+	s.queue = make(chan *Delivery, workers)
+	for range workers {
+		go s.work()
+	}
+	go s.dispatch()
+	go s.run(store.LastSeq())
+	return s
+}
+
+// run превращает изменения хранилища после номера last в доставки. Отставшего подписчика
+// хранилище отключает - тогда подписка возобновляется, а пропущенное берется из журнала.
+func (s *Service) run(last uint64) {
+	for {
+		ch, cancel := s.store.Subscribe(core.DefaultBuffer * 4)
+		for { //подписка уже действует: все после журнала придет в ch
+			events := s.store.Events(last, catchUpPage)
+			for _, e := range events {
+				s.publish(e)
+				last = e.Seq
+			}
+			if len(events) < catchUpPage {
+				break
+			}
+		}
+		for e := range ch {
+			if e.Seq <= last { //уже взято из журнала
+				continue
+			}
+			s.publish(e)
+			last = e.Seq
+		}
+		cancel()
+	}
+}
+
+// names - вид события вебхука для изменения хранилища
+var names = map[core.EventType]string{
+	core.UserRegistered: UserCreated,
+	core.UserDeleted:    UserDeleted,
+}
+
+// publish создает доставки события всем подписанным на него
+func (s *Service) publish(e core.Event) {
+	if e.Type == core.UserPurged {
+		s.forget(e.User.ID)
+		return
+	}
+	typ, ok := names[e.Type]
+	if !ok {
+		return
+	}
+	body, err := json.Marshal(Payload{ID: e.Seq, Type: typ, Time: e.Time, User: e.User.Public()})
+	if err != nil {
+		slog.Error("вебхук: событие не кодируется", "error", err)
+		return
+	}
+	s.mu.Lock()
+	for _, sub := range s.subs {
+		if !slices.Contains(sub.Events, typ) {
+			continue
+		}
+		now := time.Now().UTC()
+		d := &Delivery{ID: newID(8), SubscriptionID: sub.ID, Event: typ, EventID: e.Seq, State: StatePending,
+			CreatedAt: now, NextAttemptAt: &now, Attempts: []Attempt{}, user: e.User.ID, body: body}
+		s.remember(d)
+		s.schedule(d)
+	}
+	s.mu.Unlock()
+}
+
+// forget - пользователь id стерт окончательно: его ожидающие и недоставленные доставки
+// отменяются, а тела всех его доставок (в них его данные) стираются
+func (s *Service) forget(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cancel := func(d *Delivery) bool {
+		if d.user != id {
+			return false
+		}
+		d.State, d.NextAttemptAt, d.body = StateCanceled, nil, nil
+		return true
+	}
+	s.waiting = slices.DeleteFunc(s.waiting, cancel)
+	s.dead = slices.DeleteFunc(s.dead, cancel)
+	for _, list := range s.history {
+		for _, d := range list {
+			if d.user == id {
+				d.body = nil
+			}
+		}
+	}
+}
+
+// remember добавляет доставку в историю подписки. Вызывается под s.mu.
+func (s *Service) remember(d *Delivery) {
+	h := append(s.history[d.SubscriptionID], d)
+	if len(h) > historySize {
+		h = slices.Clone(h[len(h)-historySize:])
+	}
+	s.history[d.SubscriptionID] = h
+}
+
+// schedule ставит доставку в ожидание NextAttemptAt; если ожидающих слишком много,
+// доставка сразу становится недоставленной. Вызывается под s.mu.
+func (s *Service) schedule(d *Delivery) {
+	if len(s.waiting) >= maxPending {
+		d.Attempts = append(d.Attempts, Attempt{At: time.Now().UTC(), Error: "очередь доставок переполнена"})
+		s.bury(d)
+		return
+	}
+	s.waiting = append(s.waiting, d)
+	select {
+	case s.wake <- struct{}{}:
+	default: //dispatch уже разбужен
+	}
+}
+
+// dispatch передает исполнителям доставки, время которых наступило; между ними спит
+// до ближайшего NextAttemptAt или новой доставки
+func (s *Service) dispatch() {
+	timer := time.NewTimer(maxBackoff)
+	for {
+		now := time.Now()
+		next := now.Add(maxBackoff)
+		var due []*Delivery
+		s.mu.Lock()
+		s.waiting = slices.DeleteFunc(s.waiting, func(d *Delivery) bool {
+			if !d.NextAttemptAt.After(now) {
+				due = append(due, d)
+				return true
+			}
+			if d.NextAttemptAt.Before(next) {
+				next = *d.NextAttemptAt
+			}
+			return false
+		})
+		s.mu.Unlock()
+		for _, d := range due {
+			s.queue <- d //все исполнители заняты - ждем
+		}
+		timer.Reset(time.Until(next))
+		select {
+		case <-timer.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// work - исполнитель: по одной попытке на доставку из очереди
+func (s *Service) work() {
+	for d := range s.queue {
+		s.deliver(d)
+	}
+}
+
+// deliver делает очередную попытку доставки; неудачная ставится в ожидание следующей,
+// пока не кончатся попытки. Доставка удаленной подписки или стертого пользователя отменяется.
+func (s *Service) deliver(d *Delivery) {
+	s.mu.Lock()
+	sub, ok := s.subs[d.SubscriptionID]
+	if !ok || d.body == nil {
+		d.State, d.NextAttemptAt = StateCanceled, nil
+		s.mu.Unlock()
+		return
+	}
+	target, secret, body := sub.URL, sub.secret, d.body
+	s.mu.Unlock()
+
+	a := s.attempt(target, secret, d, body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d.Attempts = append(d.Attempts, a)
+	d.tries++
+	switch {
+	case a.Status >= 200 && a.Status < 300:
+		d.State, d.NextAttemptAt = StateDelivered, nil
+	case d.body == nil: //пользователя стерли во время попытки
+		d.State, d.NextAttemptAt = StateCanceled, nil
+	case d.tries >= s.Attempts:
+		s.bury(d)
+		slog.Warn("вебхук не доставлен", "delivery", d.ID, "webhook", d.SubscriptionID, "url", target,
+			"attempts", d.tries, "status", a.Status, "error", a.Error)
+	default:
+		next := time.Now().UTC().Add(s.backoff(d.tries))
+		d.NextAttemptAt = &next
+		s.schedule(d)
+	}
+}
+
+// bury переносит доставку в недоставленные. Вызывается под s.mu.
+func (s *Service) bury(d *Delivery) {
+	d.State, d.NextAttemptAt = StateDead, nil
+	s.dead = append(s.dead, d)
+	if len(s.dead) > deadSize {
+		s.dead = slices.Clone(s.dead[len(s.dead)-deadSize:])
+	}
+}
+
+// backoff - пауза после попытки номер n (с 1): Backoff, 2*Backoff, 4*Backoff... не больше часа
+func (s *Service) backoff(n int) time.Duration {
+	d := s.Backoff
+	for i := 1; i < n && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
+
+// attempt - один запрос к получателю с телом body
+func (s *Service) attempt(target, secret string, d *Delivery, body []byte) (a Attempt) {
+	start := time.Now()
+	a.At = start.UTC()
+	defer func() { a.Duration = float64(time.Since(start).Microseconds()) / 1000 }()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	ts := strconv.FormatInt(start.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Network-exchange-Webhook/1.0")
+	req.Header.Set("X-Webhook-ID", d.ID)
+	req.Header.Set("X-Webhook-Event", d.Event)
+	req.Header.Set("X-Webhook-Timestamp", ts)
+	req.Header.Set("X-Webhook-Signature", Sign(secret, ts, body))
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) //тело не нужно, но соединение переиспользуется
+	resp.Body.Close()
+	a.Status = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		a.Error = resp.Status
+	}
+	return a
+}
+
+// Create добавляет подписку; без секрета он создается. Возвращает подписку и секрет.
+func (s *Service) Create(target string, events []string, secret, owner string) (Subscription, string, error) {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(events) == 0 {
+		return Subscription{}, "", ErrInvalid
+	}
+	for _, e := range events {
+		if !slices.Contains(Types, e) {
+			return Subscription{}, "", ErrInvalid
+		}
+	}
+	if secret == "" {
+		secret = "whsec_" + newID(24)
+	} else if len(secret) < minSecret {
+		return Subscription{}, "", ErrInvalid
+	}
+	sub := &Subscription{ID: newID(8), URL: target, Events: slices.Compact(slices.Sorted(slices.Values(events))),
+		Owner: owner, CreatedAt: time.Now().UTC(), secret: secret}
+	s.mu.Lock()
+	s.subs[sub.ID] = sub
+	s.mu.Unlock()
+	return *sub, secret, nil
+}
+
+// List возвращает подписки по времени создания
+func (s *Service) List() []Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Subscription, 0, len(s.subs))
+	for _, sub := range s.subs {
+		out = append(out, *sub)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+// Get возвращает подписку по ID
+func (s *Service) Get(id string) (Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subs[id]
+	if !ok {
+		return Subscription{}, ErrNotFound
+	}
+	return *sub, nil
+}
+
+// Delete удаляет подписку вместе с историей; ожидающие доставки отменяются
+func (s *Service) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subs[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.subs, id)
+	delete(s.history, id)
+	s.dead = slices.DeleteFunc(s.dead, func(d *Delivery) bool { return d.SubscriptionID == id })
+	s.waiting = slices.DeleteFunc(s.waiting, func(d *Delivery) bool {
+		if d.SubscriptionID != id {
+			return false
+		}
+		d.State, d.NextAttemptAt = StateCanceled, nil
+		return true
+	})
+	return nil
+}
+
+// Deliveries - история доставок подписки, последние - первыми
+func (s *Service) Deliveries(id string) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subs[id]; !ok {
+		return nil, ErrNotFound
+	}
+	return copyNewestFirst(s.history[id]), nil
+}
+
+// Dead - недоставленные, последние - первыми
+func (s *Service) Dead() []Delivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyNewestFirst(s.dead)
+}
+
+// Redeliver убирает доставку из недоставленных и начинает новый круг попыток
+func (s *Service) Redeliver(id string) (Delivery, error) {
+	s.mu.Lock()
+	i := slices.IndexFunc(s.dead, func(d *Delivery) bool { return d.ID == id })
+	if i < 0 {
+		s.mu.Unlock()
+		return Delivery{}, ErrNotDead
+	}
+	d := s.dead[i]
+	s.dead = slices.Delete(s.dead, i, i+1)
+	d.State, d.tries = StatePending, 0
+	now := time.Now().UTC()
+	d.NextAttemptAt = &now
+	s.schedule(d)
+	out := copyDelivery(d)
+	s.mu.Unlock()
+	return out, nil
+}
+
+// copyNewestFirst копирует доставки в обратном порядке. Вызывается под s.mu.
+func copyNewestFirst(list []*Delivery) []Delivery {
+	out := make([]Delivery, 0, len(list))
+	for i := len(list) - 1; i >= 0; i-- {
+		out = append(out, copyDelivery(list[i]))
+	}
+	return out
+}
+
+// copyDelivery - снимок доставки, которую еще может менять deliver. Вызывается под s.mu.
+func copyDelivery(d *Delivery) Delivery {
+	c := *d
+	c.Attempts = slices.Clone(d.Attempts)
+	return c
+}
+
+func newID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b) //crypto/rand не возвращает ошибок
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,334 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"Network-exchange/core"
+)
+
+const secret = "test-secret-0123456789"
+
+func TestMain(m *testing.M) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil))) //предупреждения о недоставленных не нужны
+	os.Exit(m.Run())
+}
+
+// receiver - получатель вебхуков: проверяет подпись и отвечает кодом из status
+// (по номеру запроса с 1); полученные тела сохраняются
+type receiver struct {
+	mu       sync.Mutex
+	payloads []Payload
+	times    []time.Time
+	bad      int //запросов с неверной подписью
+	status   func(n int) int
+}
+
+func newReceiver(t *testing.T, status func(n int) int) (*receiver, string) {
+	t.Helper()
+	r := &receiver{status: status}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if !Verify(secret, req.Header.Get("X-Webhook-Signature"), req.Header.Get("X-Webhook-Timestamp"), body, time.Minute, time.Now()) {
+			r.bad++
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var p Payload
+		if err := json.Unmarshal(body, &p); err != nil || req.Header.Get("X-Webhook-Event") != p.Type {
+			r.bad++
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.payloads = append(r.payloads, p)
+		r.times = append(r.times, time.Now())
+		w.WriteHeader(r.status(len(r.payloads)))
+	}))
+	t.Cleanup(srv.Close)
+	return r, srv.URL
+}
+
+func (r *receiver) received() ([]Payload, []time.Time, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Payload(nil), r.payloads...), append([]time.Time(nil), r.times...), r.bad
+}
+
+// eventually ждет выполнения условия до 5 секунд
+func eventually(t *testing.T, what string, ok func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !ok() {
+		if time.Now().After(deadline) {
+			t.Fatal("не дождались: " + what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func newService(t *testing.T, attempts int, backoff time.Duration) (*Service, *core.Store) {
+	t.Helper()
+	store := core.NewStore(false)
+	s := New(store)
+	s.Attempts, s.Backoff = attempts, backoff
+	return s, store
+}
+
+func TestSignedDelivery(t *testing.T) {
+	s, store := newService(t, 3, time.Millisecond)
+	r, url := newReceiver(t, func(int) int { return http.StatusNoContent })
+	sub, _, err := s.Create(url, []string{UserCreated}, secret, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := store.Create(context.Background(), "Alice", 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "доставка", func() bool {
+		list, _ := s.Deliveries(sub.ID)
+		return len(list) == 1 && list[0].State == StateDelivered
+	})
+	payloads, _, bad := r.received()
+	if bad != 0 || len(payloads) != 1 {
+		t.Fatalf("получено %d, с неверной подписью %d", len(payloads), bad)
+	}
+	if p := payloads[0]; p.Type != UserCreated || p.User.ID != u.ID || p.ID != store.LastSeq() {
+		t.Errorf("тело %+v, ожидался user.created пользователя %d", p, u.ID)
+	}
+
+	//подпись другим секретом получатель отвергает
+	other, _, err := s.Create(url, []string{UserCreated}, "another-secret-0123456789", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Attempts = 1
+	if _, err := store.Create(context.Background(), "Bob", 40); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "недоставленная с чужой подписью", func() bool {
+		list, _ := s.Deliveries(other.ID)
+		return len(list) == 1 && list[0].State == StateDead && list[0].Attempts[0].Status == http.StatusUnauthorized
+	})
+}
+
+// email и дата рождения получателю не передаются
+func TestPayloadIsPublic(t *testing.T) {
+	s, store := newService(t, 3, time.Millisecond)
+	r, url := newReceiver(t, func(int) int { return http.StatusOK })
+	if _, _, err := s.Create(url, []string{UserCreated}, secret, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	p := core.Profile{Email: "alice@example.com", BirthDate: "1990-01-01", City: "Рига"}
+	if _, err := store.CreateWithProfile(context.Background(), "Alice", 0, p); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "доставка", func() bool {
+		payloads, _, _ := r.received()
+		return len(payloads) == 1
+	})
+	payloads, _, _ := r.received()
+	if u := payloads[0].User; u.Email != "" || u.BirthDate != "" || u.City != "Рига" || u.Age == 0 {
+		t.Errorf("пользователь в теле %+v, ожидался без email и даты рождения", u)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	const backoff = 20 * time.Millisecond
+	s, store := newService(t, 5, backoff)
+	r, url := newReceiver(t, func(n int) int {
+		if n <= 2 {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	})
+	sub, _, err := s.Create(url, []string{UserCreated}, secret, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Create(context.Background(), "Alice", 30); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "доставка с третьей попытки", func() bool {
+		list, _ := s.Deliveries(sub.ID)
+		return len(list) == 1 && list[0].State == StateDelivered
+	})
+	list, _ := s.Deliveries(sub.ID)
+	if n := len(list[0].Attempts); n != 3 {
+		t.Fatalf("попыток %d, ожидалось 3", n)
+	}
+	payloads, times, _ := r.received()
+	if payloads[0].ID != payloads[2].ID {
+		t.Errorf("повторы с разными номерами изменений: %+v", payloads)
+	}
+	//пауза растет вдвое: Backoff, затем 2*Backoff
+	if gap := times[1].Sub(times[0]); gap < backoff {
+		t.Errorf("первая пауза %v, ожидалось не меньше %v", gap, backoff)
+	}
+	if gap := times[2].Sub(times[1]); gap < 2*backoff {
+		t.Errorf("вторая пауза %v, ожидалось не меньше %v", gap, 2*backoff)
+	}
+}
+
+func TestDeadAndRedeliver(t *testing.T) {
+	s, store := newService(t, 3, time.Millisecond)
+	var healthy atomic.Bool
+	_, url := newReceiver(t, func(int) int {
+		if healthy.Load() {
+			return http.StatusOK
+		}
+		return http.StatusInternalServerError
+	})
+	sub, _, err := s.Create(url, []string{UserCreated}, secret, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Create(context.Background(), "Alice", 30); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "недоставленная", func() bool { return len(s.Dead()) == 1 })
+	d := s.Dead()[0]
+	if d.SubscriptionID != sub.ID || d.State != StateDead || len(d.Attempts) != 3 || d.Attempts[2].Status != http.StatusInternalServerError {
+		t.Fatalf("недоставленная %+v, ожидалось 3 попытки с кодом 500", d)
+	}
+	if _, err := s.Redeliver("nope"); err != ErrNotDead {
+		t.Errorf("повтор неизвестной: %v, ожидалась ErrNotDead", err)
+	}
+
+	healthy.Store(true)
+	if _, err := s.Redeliver(d.ID); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "повторная доставка", func() bool {
+		list, _ := s.Deliveries(sub.ID)
+		return len(list) == 1 && list[0].State == StateDelivered
+	})
+	if dead := s.Dead(); len(dead) != 0 {
+		t.Errorf("после повтора недоставленных %d", len(dead))
+	}
+	list, _ := s.Deliveries(sub.ID)
+	if n := len(list[0].Attempts); n != 4 {
+		t.Errorf("попыток %d, ожидалось 3 прежних и одна новая", n)
+	}
+}
+
+// Стертый окончательно пользователь пропадает из недоставленных и ожидающих доставок
+func TestPurgeCancelsDeliveries(t *testing.T) {
+	s, store := newService(t, 2, time.Millisecond)
+	_, url := newReceiver(t, func(int) int { return http.StatusInternalServerError })
+	sub, _, err := s.Create(url, []string{UserCreated}, secret, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	alice, _ := store.Create(ctx, "Alice", 30)
+	store.Create(ctx, "Carol", 35)
+	eventually(t, "недоставленные", func() bool { return len(s.Dead()) == 2 })
+	s.Backoff = time.Hour //следующая доставка после первой попытки ждет
+	bob, _ := store.Create(ctx, "Bob", 40)
+	eventually(t, "первая попытка", func() bool {
+		list, _ := s.Deliveries(sub.ID)
+		return len(list) == 3 && len(list[0].Attempts) == 1
+	})
+
+	for _, id := range []int{alice.ID, bob.ID} {
+		if _, err := store.Purge(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	eventually(t, "отмена доставок стертых", func() bool { return len(s.Dead()) == 1 })
+	list, _ := s.Deliveries(sub.ID) //последние - первыми: Bob, Carol, Alice
+	if dead := s.Dead(); dead[0].ID != list[1].ID {
+		t.Errorf("недоставленная %+v, ожидалась доставка Carol", dead[0])
+	}
+	for i, want := range []string{StateCanceled, StateDead, StateCanceled} {
+		if list[i].State != want {
+			t.Errorf("доставка %d: состояние %s, ожидалось %s", i, list[i].State, want)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, d := range s.history[sub.ID] {
+		if (d.user == alice.ID || d.user == bob.ID) && d.body != nil {
+			t.Errorf("тело доставки стертого %d осталось", d.user)
+		}
+	}
+}
+
+// Хранилище отключает отставшего подписчика; пропущенное за это время берется из журнала
+func TestResumeAfterDrop(t *testing.T) {
+	s, store := newService(t, 3, time.Millisecond)
+	r, url := newReceiver(t, func(int) int { return http.StatusOK })
+	if _, _, err := s.Create(url, []string{UserCreated}, secret, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	const n = core.DefaultBuffer*4 + 50 //больше очереди подписчика
+	s.mu.Lock()                         //publish ждет - очередь переполняется
+	for i := range n {
+		if _, err := store.Create(context.Background(), "user"+strconv.Itoa(i), 20); err != nil {
+			s.mu.Unlock()
+			t.Fatal(err)
+		}
+	}
+	s.mu.Unlock()
+	eventually(t, "доставка всех изменений", func() bool {
+		payloads, _, _ := r.received()
+		return len(payloads) >= n
+	})
+	payloads, _, _ := r.received()
+	seen := make(map[uint64]bool)
+	for _, p := range payloads {
+		if seen[p.ID] {
+			t.Errorf("изменение %d доставлено дважды", p.ID)
+		}
+		seen[p.ID] = true
+	}
+	if len(seen) != n {
+		t.Errorf("доставлено %d изменений, ожидалось %d", len(seen), n)
+	}
+}
+
+// Одновременных запросов не больше WEBHOOK_WORKERS, сколько бы доставок ни ждало
+func TestWorkersBounded(t *testing.T) {
+	t.Setenv("WEBHOOK_WORKERS", "2")
+	s, store := newService(t, 3, time.Millisecond)
+	var active, peak, done atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := active.Add(1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		<-release
+		active.Add(-1)
+		done.Add(1)
+	}))
+	defer srv.Close()
+	if _, _, err := s.Create(srv.URL, []string{UserCreated}, secret, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	const n = 10
+	for i := range n {
+		if _, err := store.Create(context.Background(), "user"+strconv.Itoa(i), 20); err != nil {
+			t.Fatal(err)
+		}
+	}
+	eventually(t, "заняты оба исполнителя", func() bool { return active.Load() == 2 })
+	time.Sleep(50 * time.Millisecond) //третьему запросу было бы достаточно времени
+	if p := peak.Load(); p != 2 {
+		t.Errorf("одновременных запросов %d, ожидалось 2", p)
+	}
+	close(release)
+	eventually(t, "все доставки", func() bool { return done.Load() == n })
+}